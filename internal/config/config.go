@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 )
@@ -11,6 +12,16 @@ type Config struct {
 	InMemory            bool
 	GetRecipeTimeout    time.Duration
 	CreateRecipeTimeout time.Duration
+	UpdateRecipeTimeout time.Duration
+	TombstoneRetention  time.Duration
+	PurgeInterval       time.Duration
+	MemoryDataDir       string
+	CompactInterval     time.Duration
+	// CuisinesFile seeds the cuisine registry instead of the defaults
+	CuisinesFile string
+	// IngredientTypesFile seeds the ingredient taxonomy instead of the
+	// defaults
+	IngredientTypesFile string
 }
 
 func NewConfig(getEnv func(string) string) (Config, error) {
@@ -50,11 +61,60 @@ func NewConfig(getEnv func(string) string) (Config, error) {
 		}
 	}
 
+	var updateRecipeTimeout = 1000 * time.Millisecond
+	if v := getEnv("UPDATE_RECIPE_TIMEOUT"); v != "" {
+		updateRecipeTimeout, err = time.ParseDuration(v)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	var tombstoneRetention = 30 * 24 * time.Hour
+	if v := getEnv("TOMBSTONE_RETENTION"); v != "" {
+		tombstoneRetention, err = time.ParseDuration(v)
+		if err != nil {
+			return Config{}, err
+		}
+		if tombstoneRetention < 0 {
+			return Config{}, fmt.Errorf("TOMBSTONE_RETENTION must not be negative, got %s", v)
+		}
+	}
+
+	var purgeInterval = time.Hour
+	if v := getEnv("PURGE_INTERVAL"); v != "" {
+		purgeInterval, err = time.ParseDuration(v)
+		if err != nil {
+			return Config{}, err
+		}
+		if purgeInterval <= 0 {
+			return Config{}, fmt.Errorf("PURGE_INTERVAL must be positive, got %s", v)
+		}
+	}
+
+	var compactInterval = 5 * time.Minute
+	if v := getEnv("MEMORY_COMPACT_INTERVAL"); v != "" {
+		compactInterval, err = time.ParseDuration(v)
+		if err != nil {
+			return Config{}, err
+		}
+		// zero turns periodic compaction off
+		if compactInterval < 0 {
+			return Config{}, fmt.Errorf("MEMORY_COMPACT_INTERVAL must not be negative, got %s", v)
+		}
+	}
+
 	return Config{
 		Host:                host,
 		Port:                port,
 		InMemory:            inMemory,
 		GetRecipeTimeout:    getRecipeTimeout,
 		CreateRecipeTimeout: createRecipeTimeout,
+		UpdateRecipeTimeout: updateRecipeTimeout,
+		TombstoneRetention:  tombstoneRetention,
+		PurgeInterval:       purgeInterval,
+		MemoryDataDir:       getEnv("MEMORY_DATA_DIR"),
+		CompactInterval:     compactInterval,
+		CuisinesFile:        getEnv("CUISINES_FILE"),
+		IngredientTypesFile: getEnv("INGREDIENT_TYPES_FILE"),
 	}, err
 }
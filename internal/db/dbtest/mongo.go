@@ -0,0 +1,52 @@
+// Package dbtest sets up databases for the tests of the stores.
+package dbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// skipWithoutDocker skips the test when no docker daemon can be found.
+// testcontainers panics rather than erroring when it cannot locate a
+// docker host at all, so that is treated as a reason to skip as well.
+func skipWithoutDocker(t *testing.T) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("docker is not available: %v", r)
+		}
+	}()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+}
+
+// MongoClient starts a mongo container for the test and connects to
+// it, skipping the test when docker is not available. Both are cleaned
+// up when the test ends.
+func MongoClient(t *testing.T) *mongo.Client {
+	t.Helper()
+	skipWithoutDocker(t)
+
+	ctx := context.Background()
+	container, err := mongodb.Run(ctx, "mongo:8")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Log("issue in stopping mongo:", err.Error())
+		}
+	})
+
+	url, err := container.ConnectionString(ctx)
+	require.NoError(t, err)
+	client, err := mongo.Connect(options.Client().ApplyURI(url))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Disconnect(ctx)
+	})
+	return client
+}
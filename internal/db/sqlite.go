@@ -0,0 +1,57 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+var (
+	sqliteOnce sync.Once
+	sqliteDB   *sql.DB
+	sqliteErr  error
+)
+
+// SQLiteDB opens the database file at SQLITE_PATH, defaulting to
+// cookbook.db in the working directory.
+func SQLiteDB(getEnv func(string) string) (*sql.DB, error) {
+	sqliteOnce.Do(func() {
+		path := "cookbook.db"
+		if v := getEnv("SQLITE_PATH"); v != "" {
+			path = v
+		}
+		sqliteDB, sqliteErr = OpenSQLite(path)
+	})
+	return sqliteDB, sqliteErr
+}
+
+// OpenSQLite opens the database with a single connection, which keeps
+// writers from tripping over each other's locks and lets ":memory:"
+// databases behave as one database.
+func OpenSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func CloseSQLite() error {
+	if sqliteDB == nil {
+		return nil
+	}
+	return sqliteDB.Close()
+}
+
+// IsUniqueViolation spots the error sqlite reports for a duplicate key,
+// without depending on the driver's error type.
+func IsUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
@@ -0,0 +1,210 @@
+package catalogue
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/search"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrEntryNotFound = errors.New("ingredient not found in catalogue")
+	ErrEntryExists   = errors.New("ingredient name is already in the catalogue")
+	ErrEntryInUse    = errors.New("ingredient is still used by a recipe")
+	ErrInvalidQuery  = errors.New("invalid ingredient query")
+)
+
+// Entry is an ingredient in the catalogue. Recipes refer to it by the
+// id of its ingredient and keep a copy of the rest, which the recipe
+// service refreshes whenever the entry changes. Aliases are the other
+// names it goes by, such as cilantro for coriander; looking up any of
+// them finds the entry.
+type Entry struct {
+	ingredient domain.Ingredient
+	aliases    []string
+	createdAt  time.Time
+	updatedAt  time.Time
+}
+
+// NewEntry creates an entry with a new id.
+func NewEntry(name string, description string, t domain.IngredientType, density float64, aliases []string) (Entry, error) {
+	e, err := FromIngredient(domain.Ingredient{
+		ID:          uuid.New(),
+		Name:        name,
+		Description: description,
+		Type:        t,
+		Density:     density,
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	if e.aliases, err = cleanAliases(e.ingredient.Name, aliases); err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// FromIngredient creates an entry for an ingredient that already has
+// an id, such as one found in a recipe written before the catalogue.
+func FromIngredient(i domain.Ingredient) (Entry, error) {
+	i.Name = strings.TrimSpace(i.Name)
+	i.Description = strings.TrimSpace(i.Description)
+	if err := validate(i); err != nil {
+		return Entry{}, err
+	}
+	return Entry{ingredient: i, createdAt: now()}, nil
+}
+
+func validate(i domain.Ingredient) error {
+	if i.ID == uuid.Nil || Key(i.Name) == "" {
+		return domain.ErrInvalidIngredient
+	}
+	// whether the type is in the taxonomy is up to the service
+	if i.Type < domain.UnknownIngredient {
+		return domain.ErrInvalidIngredient
+	}
+	if i.Density < 0 {
+		return domain.ErrInvalidQuantity
+	}
+	return nil
+}
+
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// cleanAliases trims aliases and drops the ones that add nothing: those
+// the name or an earlier alias already canonicalises to.
+func cleanAliases(name string, aliases []string) ([]string, error) {
+	keys := []string{Key(name)}
+	var cleaned []string
+	for _, a := range aliases {
+		a = strings.TrimSpace(a)
+		key := Key(a)
+		if key == "" {
+			return nil, domain.ErrInvalidIngredient
+		}
+		if slices.Contains(keys, key) {
+			continue
+		}
+		keys = append(keys, key)
+		cleaned = append(cleaned, a)
+	}
+	slices.Sort(cleaned)
+	return cleaned, nil
+}
+
+// Key is the canonical form of a name, what names are compared by: two
+// entries cannot share one and an ingredient added to a recipe by name
+// is looked up by it. Names are folded, so case and accents do not
+// count, and reduced to the stems of their words, which folds plurals:
+// "Tomatoes" and "tomato" share a key. Keys are not meant to be shown.
+func Key(name string) string {
+	return strings.Join(search.Stems(name), " ")
+}
+
+// label is a name as it is matched against a prefix: folded, but with
+// its words whole, as a prefix usually ends mid-word.
+func label(name string) string {
+	return strings.Join(strings.Fields(search.Fold(name)), " ")
+}
+
+func (e Entry) ID() uuid.UUID {
+	return e.ingredient.ID
+}
+
+func (e Entry) Name() string {
+	return e.ingredient.Name
+}
+
+func (e Entry) key() string {
+	return Key(e.ingredient.Name)
+}
+
+func (e Entry) Aliases() []string {
+	return slices.Clone(e.aliases)
+}
+
+// keys are the keys of the name and of every alias.
+func (e Entry) keys() []string {
+	keys := []string{e.key()}
+	for _, a := range e.aliases {
+		keys = append(keys, Key(a))
+	}
+	return keys
+}
+
+// labels are the labels of the name and of every alias.
+func (e Entry) labels() []string {
+	labels := []string{label(e.ingredient.Name)}
+	for _, a := range e.aliases {
+		labels = append(labels, label(a))
+	}
+	return labels
+}
+
+// Ingredient is the entry as recipes hold it.
+func (e Entry) Ingredient() domain.Ingredient {
+	return e.ingredient
+}
+
+func (e Entry) CreatedAt() time.Time {
+	return e.createdAt
+}
+
+// UpdatedAt is zero until the entry is first changed.
+func (e Entry) UpdatedAt() time.Time {
+	return e.updatedAt
+}
+
+// Patch is a partial update of an entry. Nil fields are left as they
+// are; Aliases replaces every alias.
+type Patch struct {
+	Name        *string
+	Description *string
+	Type        *domain.IngredientType
+	Density     *float64
+	Aliases     *[]string
+}
+
+// Apply validates the patched entry before changing anything, so a
+// failed patch leaves the entry untouched.
+func (e *Entry) Apply(p Patch) error {
+	i := e.ingredient
+	if p.Name != nil {
+		i.Name = strings.TrimSpace(*p.Name)
+	}
+	if p.Description != nil {
+		i.Description = strings.TrimSpace(*p.Description)
+	}
+	if p.Type != nil {
+		i.Type = *p.Type
+	}
+	if p.Density != nil {
+		i.Density = *p.Density
+	}
+	if err := validate(i); err != nil {
+		return err
+	}
+	aliases := e.aliases
+	if p.Aliases != nil {
+		aliases = *p.Aliases
+	}
+	aliases, err := cleanAliases(i.Name, aliases)
+	if err != nil {
+		return err
+	}
+	e.ingredient = i
+	e.aliases = aliases
+	e.updatedAt = now()
+	return nil
+}
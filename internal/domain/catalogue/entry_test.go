@@ -0,0 +1,74 @@
+package catalogue
+
+import (
+	"testing"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEntry(t *testing.T) {
+	e, err := NewEntry("  Plain Flour ", "", domain.Grains, 0.59, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Plain Flour", e.Name())
+	assert.Equal(t, "plain flour", e.key())
+	assert.False(t, e.CreatedAt().IsZero())
+	assert.True(t, e.UpdatedAt().IsZero())
+
+	_, err = NewEntry(" ", "", domain.Grains, 0, nil)
+	assert.ErrorIs(t, err, domain.ErrInvalidIngredient)
+	_, err = NewEntry("flour", "", domain.IngredientType(-1), 0, nil)
+	assert.ErrorIs(t, err, domain.ErrInvalidIngredient)
+	_, err = NewEntry("flour", "", domain.Grains, -1, nil)
+	assert.ErrorIs(t, err, domain.ErrInvalidQuantity)
+}
+
+func TestKey(t *testing.T) {
+	for _, names := range [][]string{
+		{"tomato", "Tomatoes", " TOMATO "},
+		{"berry", "berries"},
+		{"Crème Fraîche", "creme fraiche", "crème fraîches"},
+		{"jalapeño pepper", "Jalapeno Peppers"},
+		{"ﬁg", "figs"},
+		{"salt & pepper", "salt pepper"},
+	} {
+		for _, n := range names[1:] {
+			assert.Equal(t, Key(names[0]), Key(n), "%q and %q", names[0], n)
+		}
+	}
+	assert.NotEqual(t, Key("pea"), Key("peach"))
+	assert.Empty(t, Key(" & "))
+}
+
+func TestAliases(t *testing.T) {
+	e, err := NewEntry("coriander", "", domain.HerbsAndSpices, 0, []string{" cilantro", "Coriander", "fresh coriander leaves", "Cilantro"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cilantro", "fresh coriander leaves"}, e.Aliases())
+	assert.Equal(t, []string{Key("coriander"), Key("cilantro"), Key("fresh coriander leaves")}, e.keys())
+
+	_, err = NewEntry("coriander", "", domain.HerbsAndSpices, 0, []string{"!"})
+	assert.ErrorIs(t, err, domain.ErrInvalidIngredient)
+
+	// renaming to an alias drops it
+	name := "Cilantro"
+	require.NoError(t, e.Apply(Patch{Name: &name}))
+	assert.Equal(t, []string{"fresh coriander leaves"}, e.Aliases())
+	aliases := []string{"coriander"}
+	require.NoError(t, e.Apply(Patch{Aliases: &aliases}))
+	assert.Equal(t, []string{"coriander"}, e.Aliases())
+}
+
+func TestApply(t *testing.T) {
+	e, err := NewEntry("flour", "", domain.Grains, 0.59, nil)
+	require.NoError(t, err)
+
+	name, density := "Bread Flour", -1.0
+	assert.ErrorIs(t, e.Apply(Patch{Name: &name, Density: &density}), domain.ErrInvalidQuantity)
+	assert.Equal(t, "flour", e.Name(), "a failed patch changes nothing")
+
+	require.NoError(t, e.Apply(Patch{Name: &name}))
+	assert.Equal(t, "Bread Flour", e.Name())
+	assert.Equal(t, 0.59, e.Ingredient().Density)
+	assert.False(t, e.UpdatedAt().IsZero())
+}
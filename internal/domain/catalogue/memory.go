@@ -0,0 +1,150 @@
+package catalogue
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/google/uuid"
+)
+
+type MemoryRepository struct {
+	entries map[uuid.UUID]Entry
+	// names maps the key of every entry's name and alias to its id
+	names map[string]uuid.UUID
+	mu    sync.Mutex
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		entries: make(map[uuid.UUID]Entry),
+		names:   make(map[string]uuid.UUID),
+	}
+}
+
+func (mr *MemoryRepository) Get(_ context.Context, id uuid.UUID) (Entry, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	e, ok := mr.entries[id]
+	if !ok {
+		return Entry{}, ErrEntryNotFound
+	}
+	return e, nil
+}
+
+func (mr *MemoryRepository) FindByName(_ context.Context, name string) (Entry, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	id, ok := mr.names[Key(name)]
+	if !ok {
+		return Entry{}, ErrEntryNotFound
+	}
+	return mr.entries[id], nil
+}
+
+// List finds entries by a prefix of their name or of an alias.
+func (mr *MemoryRepository) List(_ context.Context, prefix string, limit int) ([]Entry, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	prefix = label(prefix)
+	var entries []Entry
+	for _, e := range mr.entries {
+		if slices.ContainsFunc(e.labels(), func(l string) bool {
+			return strings.HasPrefix(l, prefix)
+		}) {
+			entries = append(entries, e)
+		}
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return strings.Compare(a.key(), b.key())
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// All returns every entry, in no particular order.
+func (mr *MemoryRepository) All(_ context.Context) ([]Entry, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	entries := make([]Entry, 0, len(mr.entries))
+	for _, e := range mr.entries {
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (mr *MemoryRepository) Add(_ context.Context, e Entry) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if _, ok := mr.entries[e.ID()]; ok {
+		return ErrEntryExists
+	}
+	if !mr.free(e) {
+		return ErrEntryExists
+	}
+	mr.entries[e.ID()] = e
+	for _, key := range e.keys() {
+		mr.names[key] = e.ID()
+	}
+	return nil
+}
+
+// free reports whether no other entry has any of e's keys.
+func (mr *MemoryRepository) free(e Entry) bool {
+	for _, key := range e.keys() {
+		if id, ok := mr.names[key]; ok && id != e.ID() {
+			return false
+		}
+	}
+	return true
+}
+
+func (mr *MemoryRepository) Update(_ context.Context, e Entry) (Entry, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	old, ok := mr.entries[e.ID()]
+	if !ok {
+		return Entry{}, ErrEntryNotFound
+	}
+	if !mr.free(e) {
+		return Entry{}, ErrEntryExists
+	}
+	for _, key := range old.keys() {
+		delete(mr.names, key)
+	}
+	mr.entries[e.ID()] = e
+	for _, key := range e.keys() {
+		mr.names[key] = e.ID()
+	}
+	return e, nil
+}
+
+// UsesType reports whether any entry is of the given type.
+func (mr *MemoryRepository) UsesType(_ context.Context, t domain.IngredientType) (bool, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	for _, e := range mr.entries {
+		if e.ingredient.Type == t {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (mr *MemoryRepository) Delete(_ context.Context, id uuid.UUID) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	e, ok := mr.entries[id]
+	if !ok {
+		return ErrEntryNotFound
+	}
+	for _, key := range e.keys() {
+		delete(mr.names, key)
+	}
+	delete(mr.entries, id)
+	return nil
+}
@@ -0,0 +1,242 @@
+package catalogue
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// entry is how an entry is stored. key holds the name as it is
+// compared and sorted, keys the name and aliases, so uniqueness can
+// use an index, and labels the same for prefix listing.
+type entry struct {
+	ID          uuid.UUID  `bson:"_id"`
+	Name        string     `bson:"name"`
+	Key         string     `bson:"key"`
+	Aliases     []string   `bson:"aliases,omitempty"`
+	Keys        []string   `bson:"keys"`
+	Labels      []string   `bson:"labels"`
+	Description string     `bson:"description,omitempty"`
+	Type        int        `bson:"type"`
+	Density     float64    `bson:"density,omitempty"`
+	CreatedAt   time.Time  `bson:"created_at"`
+	UpdatedAt   *time.Time `bson:"updated_at,omitempty"`
+}
+
+func entryFromEntry(e Entry) entry {
+	doc := entry{
+		ID:          e.ID(),
+		Name:        e.ingredient.Name,
+		Key:         e.key(),
+		Aliases:     e.aliases,
+		Keys:        e.keys(),
+		Labels:      e.labels(),
+		Description: e.ingredient.Description,
+		Type:        int(e.ingredient.Type),
+		Density:     e.ingredient.Density,
+		CreatedAt:   e.createdAt,
+	}
+	if !e.updatedAt.IsZero() {
+		updatedAt := e.updatedAt
+		doc.UpdatedAt = &updatedAt
+	}
+	return doc
+}
+
+func (doc entry) toEntry() Entry {
+	e := Entry{
+		ingredient: domain.Ingredient{
+			ID:          doc.ID,
+			Name:        doc.Name,
+			Description: doc.Description,
+			Type:        domain.IngredientType(doc.Type),
+			Density:     doc.Density,
+		},
+		aliases:   doc.Aliases,
+		createdAt: doc.CreatedAt.UTC(),
+	}
+	if doc.UpdatedAt != nil {
+		e.updatedAt = doc.UpdatedAt.UTC()
+	}
+	return e
+}
+
+type MongoRepository struct {
+	client         *mongo.Client
+	databaseName   string
+	collectionName string
+}
+
+func NewMongoRepository(client *mongo.Client, databaseName, collectionName string) *MongoRepository {
+	return &MongoRepository{
+		client:         client,
+		databaseName:   databaseName,
+		collectionName: collectionName,
+	}
+}
+
+func (mr *MongoRepository) collection() *mongo.Collection {
+	return mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+}
+
+// EnsureIndexes creates the unique indexes on names and aliases and the
+// one prefix listing uses. Entries stored before names were
+// canonicalised are given their current keys first. It is safe to
+// call on every start.
+func (mr *MongoRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := mr.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetName("catalogue_key").SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	if err := mr.rekey(ctx); err != nil {
+		return err
+	}
+	_, err = mr.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "keys", Value: 1}},
+			Options: options.Index().SetName("catalogue_keys").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "labels", Value: 1}},
+			Options: options.Index().SetName("catalogue_labels"),
+		},
+	})
+	return err
+}
+
+// rekey brings the stored keys and labels of every entry up to date.
+// An entry whose name now canonicalises like another's keeps the key
+// it was stored with; renaming one of them resolves it.
+func (mr *MongoRepository) rekey(ctx context.Context) error {
+	cur, err := mr.collection().Find(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	var docs []entry
+	if err := cur.All(ctx, &docs); err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		current := entryFromEntry(doc.toEntry())
+		if slices.Equal(doc.Keys, current.Keys) && slices.Equal(doc.Labels, current.Labels) {
+			continue
+		}
+		update := bson.M{"key": current.Key, "keys": current.Keys, "labels": current.Labels}
+		_, err := mr.collection().UpdateByID(ctx, doc.ID, bson.M{"$set": update})
+		if mongo.IsDuplicateKeyError(err) {
+			update = bson.M{"keys": []string{doc.Key}, "labels": current.Labels}
+			_, err = mr.collection().UpdateByID(ctx, doc.ID, bson.M{"$set": update})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mr *MongoRepository) findOne(ctx context.Context, filter bson.M) (Entry, error) {
+	var doc entry
+	if err := mr.collection().FindOne(ctx, filter).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return Entry{}, ErrEntryNotFound
+		}
+		return Entry{}, err
+	}
+	return doc.toEntry(), nil
+}
+
+func (mr *MongoRepository) Get(ctx context.Context, id uuid.UUID) (Entry, error) {
+	return mr.findOne(ctx, bson.M{"_id": id})
+}
+
+func (mr *MongoRepository) FindByName(ctx context.Context, name string) (Entry, error) {
+	return mr.findOne(ctx, bson.M{"keys": Key(name)})
+}
+
+func (mr *MongoRepository) List(ctx context.Context, prefix string, limit int) ([]Entry, error) {
+	filter := bson.M{}
+	if prefix := label(prefix); prefix != "" {
+		filter["labels"] = bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}
+	}
+	cur, err := mr.collection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "key", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	var docs []entry
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(docs))
+	for _, doc := range docs {
+		entries = append(entries, doc.toEntry())
+	}
+	return entries, nil
+}
+
+func (mr *MongoRepository) All(ctx context.Context) ([]Entry, error) {
+	cur, err := mr.collection().Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	var docs []entry
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(docs))
+	for _, doc := range docs {
+		entries = append(entries, doc.toEntry())
+	}
+	return entries, nil
+}
+
+func (mr *MongoRepository) Add(ctx context.Context, e Entry) error {
+	_, err := mr.collection().InsertOne(ctx, entryFromEntry(e))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrEntryExists
+	}
+	return err
+}
+
+func (mr *MongoRepository) Update(ctx context.Context, e Entry) (Entry, error) {
+	res, err := mr.collection().ReplaceOne(ctx, bson.M{"_id": e.ID()}, entryFromEntry(e))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Entry{}, ErrEntryExists
+		}
+		return Entry{}, err
+	}
+	if res.MatchedCount == 0 {
+		return Entry{}, ErrEntryNotFound
+	}
+	return e, nil
+}
+
+func (mr *MongoRepository) UsesType(ctx context.Context, t domain.IngredientType) (bool, error) {
+	n, err := mr.collection().CountDocuments(ctx, bson.M{"type": int(t)}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (mr *MongoRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := mr.collection().DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrEntryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package catalogue
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bento01dev/cookbook/internal/db"
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// repository mirrors the catalogueRepository interface the recipe
+// service uses.
+type repository interface {
+	Get(context.Context, uuid.UUID) (Entry, error)
+	FindByName(context.Context, string) (Entry, error)
+	List(context.Context, string, int) ([]Entry, error)
+	All(context.Context) ([]Entry, error)
+	Add(context.Context, Entry) error
+	Update(context.Context, Entry) (Entry, error)
+	Delete(context.Context, uuid.UUID) error
+	UsesType(context.Context, domain.IngredientType) (bool, error)
+}
+
+func newEntry(t *testing.T, name string) Entry {
+	t.Helper()
+	e, err := NewEntry(name, "", domain.UnknownIngredient, 0, nil)
+	require.NoError(t, err)
+	return e
+}
+
+func names(entries []Entry) []string {
+	res := make([]string, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.Name())
+	}
+	return res
+}
+
+// testRepository runs the repository contract against a fresh, empty
+// repository for every case.
+func testRepository(t *testing.T, newRepo func(t *testing.T) repository) {
+	ctx := context.Background()
+
+	t.Run("add, get and find by name", func(t *testing.T) {
+		repo := newRepo(t)
+		flour := newEntry(t, "Flour")
+		require.NoError(t, repo.Add(ctx, flour))
+		assert.ErrorIs(t, repo.Add(ctx, newEntry(t, " flour")), ErrEntryExists)
+
+		got, err := repo.Get(ctx, flour.ID())
+		require.NoError(t, err)
+		assert.Equal(t, flour, got)
+		got, err = repo.FindByName(ctx, "FLOUR")
+		require.NoError(t, err)
+		assert.Equal(t, flour.ID(), got.ID())
+
+		_, err = repo.Get(ctx, uuid.New())
+		assert.ErrorIs(t, err, ErrEntryNotFound)
+		_, err = repo.FindByName(ctx, "sugar")
+		assert.ErrorIs(t, err, ErrEntryNotFound)
+	})
+
+	t.Run("lists by name from a prefix", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, n := range []string{"sugar", "Butter", "salt", "Saffron"} {
+			require.NoError(t, repo.Add(ctx, newEntry(t, n)))
+		}
+
+		got, err := repo.List(ctx, "", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Butter", "Saffron", "salt", "sugar"}, names(got))
+		got, err = repo.List(ctx, "S", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Saffron", "salt"}, names(got))
+		got, err = repo.List(ctx, "sa.", 10)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+
+		got, err = repo.All(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"sugar", "Butter", "salt", "Saffron"}, names(got))
+	})
+
+	t.Run("aliases find the entry and are unique", func(t *testing.T) {
+		repo := newRepo(t)
+		coriander, err := NewEntry("coriander", "", domain.HerbsAndSpices, 0, []string{"cilantro", "fresh coriander leaves"})
+		require.NoError(t, err)
+		require.NoError(t, repo.Add(ctx, coriander))
+
+		for _, name := range []string{"Cilantro", "fresh coriander leaf", "CORIANDER"} {
+			got, err := repo.FindByName(ctx, name)
+			require.NoError(t, err, name)
+			assert.Equal(t, coriander, got)
+		}
+		got, err := repo.List(ctx, "cil", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"coriander"}, names(got))
+
+		assert.ErrorIs(t, repo.Add(ctx, newEntry(t, "Cilantro")), ErrEntryExists)
+		parsley := newEntry(t, "parsley")
+		require.NoError(t, repo.Add(ctx, parsley))
+		aliases := []string{"cilantro"}
+		require.NoError(t, parsley.Apply(Patch{Aliases: &aliases}))
+		_, err = repo.Update(ctx, parsley)
+		assert.ErrorIs(t, err, ErrEntryExists)
+
+		// dropping an alias frees it
+		aliases = nil
+		require.NoError(t, coriander.Apply(Patch{Aliases: &aliases}))
+		_, err = repo.Update(ctx, coriander)
+		require.NoError(t, err)
+		_, err = repo.FindByName(ctx, "cilantro")
+		assert.ErrorIs(t, err, ErrEntryNotFound)
+		require.NoError(t, repo.Add(ctx, newEntry(t, "Cilantro")))
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		repo := newRepo(t)
+		flour, sugar := newEntry(t, "flour"), newEntry(t, "sugar")
+		require.NoError(t, repo.Add(ctx, flour))
+		require.NoError(t, repo.Add(ctx, sugar))
+
+		name := "Sugar"
+		require.NoError(t, flour.Apply(Patch{Name: &name}))
+		_, err := repo.Update(ctx, flour)
+		assert.ErrorIs(t, err, ErrEntryExists)
+
+		name = "bread flour"
+		require.NoError(t, flour.Apply(Patch{Name: &name}))
+		updated, err := repo.Update(ctx, flour)
+		require.NoError(t, err)
+		assert.Equal(t, flour, updated)
+		_, err = repo.FindByName(ctx, "flour")
+		assert.ErrorIs(t, err, ErrEntryNotFound)
+		got, err := repo.FindByName(ctx, "Bread Flour")
+		require.NoError(t, err)
+		assert.Equal(t, flour.ID(), got.ID())
+
+		fish := domain.IngredientType(domain.Fish)
+		require.NoError(t, flour.Apply(Patch{Type: &fish}))
+		_, err = repo.Update(ctx, flour)
+		require.NoError(t, err)
+		used, err := repo.UsesType(ctx, domain.Fish)
+		require.NoError(t, err)
+		assert.True(t, used)
+
+		require.NoError(t, repo.Delete(ctx, flour.ID()))
+		used, err = repo.UsesType(ctx, domain.Fish)
+		require.NoError(t, err)
+		assert.False(t, used)
+		assert.ErrorIs(t, repo.Delete(ctx, flour.ID()), ErrEntryNotFound)
+		_, err = repo.Update(ctx, flour)
+		assert.ErrorIs(t, err, ErrEntryNotFound)
+	})
+}
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) repository {
+		return NewMemoryRepository()
+	})
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) repository {
+		sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "cookbook.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			sqlDB.Close()
+		})
+
+		repo := NewSQLiteRepository(sqlDB)
+		require.NoError(t, repo.Migrate(context.Background()))
+		return repo
+	})
+}
@@ -0,0 +1,259 @@
+package catalogue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/db"
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/google/uuid"
+)
+
+// SQLiteRepository keeps the catalogue in its own tables next to the
+// recipes. Aliases are stored as a JSON array; the keys of names and
+// aliases have a table of their own, which keeps them unique, and so
+// do their labels for prefix listing.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{db: db}
+}
+
+var migrations = []db.Migration{
+	db.Exec(`CREATE TABLE ingredients (
+		id          TEXT PRIMARY KEY,
+		name        TEXT NOT NULL,
+		key         TEXT NOT NULL,
+		aliases     TEXT NOT NULL,
+		description TEXT NOT NULL,
+		type        INTEGER NOT NULL,
+		density     REAL NOT NULL,
+		created_at  INTEGER NOT NULL,
+		updated_at  INTEGER
+	)`),
+	db.Exec(`CREATE INDEX ingredients_key ON ingredients (key)`),
+	db.Exec(`CREATE INDEX ingredients_type ON ingredients (type)`),
+	db.Exec(`CREATE TABLE ingredient_keys (
+		key           TEXT PRIMARY KEY,
+		ingredient_id TEXT NOT NULL REFERENCES ingredients (id) ON DELETE CASCADE
+	)`),
+	db.Exec(`CREATE INDEX ingredient_keys_ingredient_id ON ingredient_keys (ingredient_id)`),
+	db.Exec(`CREATE TABLE ingredient_labels (
+		label         TEXT NOT NULL,
+		ingredient_id TEXT NOT NULL REFERENCES ingredients (id) ON DELETE CASCADE
+	)`),
+	db.Exec(`CREATE INDEX ingredient_labels_label ON ingredient_labels (label)`),
+	db.Exec(`CREATE INDEX ingredient_labels_ingredient_id ON ingredient_labels (ingredient_id)`),
+}
+
+// Migrate brings the schema up to date.
+func (sr *SQLiteRepository) Migrate(ctx context.Context) error {
+	return db.Migrate(ctx, sr.db, "catalogue_migrations", migrations)
+}
+
+const entryColumns = `id, name, aliases, description, type, density, created_at, updated_at`
+
+type scanner interface {
+	Scan(...any) error
+}
+
+func scanEntry(row scanner) (Entry, error) {
+	var (
+		id, name, aliases, description string
+		t                              int
+		density                        float64
+		createdAt                      int64
+		updatedAt                      sql.NullInt64
+	)
+	if err := row.Scan(&id, &name, &aliases, &description, &t, &density, &createdAt, &updatedAt); err != nil {
+		return Entry{}, err
+	}
+	entryID, err := uuid.Parse(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	e := Entry{
+		ingredient: domain.Ingredient{
+			ID:          entryID,
+			Name:        name,
+			Description: description,
+			Type:        domain.IngredientType(t),
+			Density:     density,
+		},
+		createdAt: time.UnixMilli(createdAt).UTC(),
+	}
+	if err := json.Unmarshal([]byte(aliases), &e.aliases); err != nil {
+		return Entry{}, err
+	}
+	if updatedAt.Valid {
+		e.updatedAt = time.UnixMilli(updatedAt.Int64).UTC()
+	}
+	return e, nil
+}
+
+func (sr *SQLiteRepository) Get(ctx context.Context, id uuid.UUID) (Entry, error) {
+	e, err := scanEntry(sr.db.QueryRowContext(ctx, `SELECT `+entryColumns+` FROM ingredients WHERE id = ?`, id.String()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, ErrEntryNotFound
+	}
+	return e, err
+}
+
+func (sr *SQLiteRepository) FindByName(ctx context.Context, name string) (Entry, error) {
+	e, err := scanEntry(sr.db.QueryRowContext(
+		ctx,
+		`SELECT `+entryColumns+` FROM ingredients WHERE id = (SELECT ingredient_id FROM ingredient_keys WHERE key = ?)`,
+		Key(name),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, ErrEntryNotFound
+	}
+	return e, err
+}
+
+func (sr *SQLiteRepository) List(ctx context.Context, prefix string, limit int) ([]Entry, error) {
+	query := `SELECT ` + entryColumns + ` FROM ingredients`
+	var args []any
+	if prefix := label(prefix); prefix != "" {
+		query += ` WHERE id IN (SELECT ingredient_id FROM ingredient_labels WHERE substr(label, 1, length(?)) = ?)`
+		args = append(args, prefix, prefix)
+	}
+	query += ` ORDER BY key LIMIT ?`
+	args = append(args, limit)
+	return sr.query(ctx, query, args...)
+}
+
+func (sr *SQLiteRepository) All(ctx context.Context) ([]Entry, error) {
+	return sr.query(ctx, `SELECT `+entryColumns+` FROM ingredients`)
+}
+
+func (sr *SQLiteRepository) query(ctx context.Context, query string, args ...any) ([]Entry, error) {
+	rows, err := sr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// write stores the entry's row with the statement given and indexes
+// its keys and labels afresh.
+func write(ctx context.Context, tx *sql.Tx, stmt string, e Entry) (sql.Result, error) {
+	aliases, err := json.Marshal(e.Aliases())
+	if err != nil {
+		return nil, err
+	}
+	var updatedAt sql.NullInt64
+	if !e.updatedAt.IsZero() {
+		updatedAt = sql.NullInt64{Int64: e.updatedAt.UnixMilli(), Valid: true}
+	}
+	res, err := tx.ExecContext(
+		ctx, stmt,
+		e.ingredient.Name, e.key(), string(aliases), e.ingredient.Description, int(e.ingredient.Type),
+		e.ingredient.Density, e.createdAt.UnixMilli(), updatedAt, e.ID().String(),
+	)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return nil, ErrEntryExists
+		}
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return res, err
+	}
+
+	id := e.ID().String()
+	for _, stmt := range []string{
+		`DELETE FROM ingredient_keys WHERE ingredient_id = ?`,
+		`DELETE FROM ingredient_labels WHERE ingredient_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range e.keys() {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO ingredient_keys (key, ingredient_id) VALUES (?, ?)`, key, id); err != nil {
+			if db.IsUniqueViolation(err) {
+				return nil, ErrEntryExists
+			}
+			return nil, err
+		}
+	}
+	for _, l := range e.labels() {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO ingredient_labels (label, ingredient_id) VALUES (?, ?)`, l, id); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (sr *SQLiteRepository) Add(ctx context.Context, e Entry) error {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = write(ctx, tx, `INSERT INTO ingredients
+		(name, key, aliases, description, type, density, created_at, updated_at, id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, e)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (sr *SQLiteRepository) Update(ctx context.Context, e Entry) (Entry, error) {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer tx.Rollback()
+	res, err := write(ctx, tx, `UPDATE ingredients SET
+		name = ?, key = ?, aliases = ?, description = ?, type = ?, density = ?, created_at = ?, updated_at = ?
+		WHERE id = ?`, e)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := affected(res); err != nil {
+		return Entry{}, err
+	}
+	return e, tx.Commit()
+}
+
+func (sr *SQLiteRepository) UsesType(ctx context.Context, t domain.IngredientType) (bool, error) {
+	var used bool
+	err := sr.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM ingredients WHERE type = ?)`, int(t)).Scan(&used)
+	return used, err
+}
+
+func (sr *SQLiteRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := sr.db.ExecContext(ctx, `DELETE FROM ingredients WHERE id = ?`, id.String())
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrEntryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package cuisine
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/registry"
+)
+
+var (
+	ErrInvalidCuisine   = errors.New("invalid cuisine")
+	ErrCuisineNotFound  = errors.New("cuisine not found")
+	ErrCuisineExists    = errors.New("cuisine name or alias is already taken")
+	ErrParentNotFound   = errors.New("parent cuisine not found")
+	ErrCuisineCycle     = errors.New("cuisine cannot be a region of itself")
+	ErrCuisineHasRegion = errors.New("cuisine still has regional cuisines")
+	ErrCuisineInUse     = errors.New("cuisine is still used by a recipe")
+)
+
+// firstID is the lowest id handed out to a cuisine added at runtime,
+// clear of the built-in ones.
+const firstID = domain.CuisineType(domain.Western + 1)
+
+// Cuisine is an entry of the cuisine registry. Recipes refer to it by
+// id; its name and aliases are what users filter and create recipes
+// with. A regional cuisine names the cuisine it belongs to as parent.
+type Cuisine struct {
+	id      domain.CuisineType
+	name    string
+	aliases []string
+	parent  domain.CuisineType
+}
+
+// NewCuisine creates a cuisine. Names and aliases are matched without
+// regard to case, so they are kept lower cased.
+func NewCuisine(id domain.CuisineType, name string, parent domain.CuisineType, aliases []string) (Cuisine, error) {
+	if id <= domain.UnknownCuisine || parent < domain.UnknownCuisine {
+		return Cuisine{}, ErrInvalidCuisine
+	}
+	if id == parent {
+		return Cuisine{}, ErrCuisineCycle
+	}
+	name, ok := registry.Normalize(name)
+	if !ok {
+		return Cuisine{}, ErrInvalidCuisine
+	}
+	c := Cuisine{id: id, name: name, parent: parent}
+	for _, a := range aliases {
+		a, ok := registry.Normalize(a)
+		if !ok {
+			return Cuisine{}, ErrInvalidCuisine
+		}
+		if a == name || slices.Contains(c.aliases, a) {
+			continue
+		}
+		c.aliases = append(c.aliases, a)
+	}
+	slices.Sort(c.aliases)
+	return c, nil
+}
+
+func (c Cuisine) ID() domain.CuisineType {
+	return c.id
+}
+
+func (c Cuisine) Name() string {
+	return c.name
+}
+
+func (c Cuisine) Aliases() []string {
+	return slices.Clone(c.aliases)
+}
+
+// Parent is domain.UnknownCuisine for a cuisine that is not a region
+// of another.
+func (c Cuisine) Parent() domain.CuisineType {
+	return c.parent
+}
+
+// names lists the name and every alias the cuisine can be looked up by.
+func (c Cuisine) names() []string {
+	return append([]string{c.name}, c.aliases...)
+}
+
+// Defaults are the cuisines a registry is seeded with when nothing
+// else is configured.
+func Defaults() []Cuisine {
+	return []Cuisine{
+		{id: domain.Japanese, name: "japanese"},
+		{id: domain.French, name: "french"},
+		{id: domain.Spanish, name: "spanish"},
+		{id: domain.Indian, name: "indian"},
+		{id: domain.Chinese, name: "chinese"},
+		{id: domain.Western, name: "western"},
+	}
+}
@@ -0,0 +1,55 @@
+package cuisine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+)
+
+// document is how a cuisine is stored and how it is written in a
+// cuisines file. Parents are referred to by id.
+type document struct {
+	ID      int      `bson:"_id" json:"id"`
+	Name    string   `bson:"name" json:"name"`
+	Aliases []string `bson:"aliases,omitempty" json:"aliases,omitempty"`
+	Parent  int      `bson:"parent,omitempty" json:"parent,omitempty"`
+	// Names holds the name and the aliases together, for the index
+	// that keeps them unique across cuisines
+	Names []string `bson:"names,omitempty" json:"-"`
+}
+
+func documentFromCuisine(c Cuisine) document {
+	return document{ID: int(c.id), Name: c.name, Aliases: c.aliases, Parent: int(c.parent), Names: c.names()}
+}
+
+func (d document) toCuisine() (Cuisine, error) {
+	return NewCuisine(domain.CuisineType(d.ID), d.Name, domain.CuisineType(d.Parent), d.Aliases)
+}
+
+// LoadFile reads the cuisines a registry is seeded with from a JSON
+// array of {"id", "name", "aliases", "parent"} objects. The ids of the
+// default cuisines should be kept, as stored recipes refer to them.
+func LoadFile(path string) ([]Cuisine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var docs []document
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return nil, fmt.Errorf("parsing cuisines file failed: %w", err)
+	}
+	cuisines := make([]Cuisine, 0, len(docs))
+	for _, d := range docs {
+		c, err := d.toCuisine()
+		if err != nil {
+			return nil, fmt.Errorf("cuisine %q: %w", d.Name, err)
+		}
+		cuisines = append(cuisines, c)
+	}
+	if _, err := NewRegistry(cuisines...); err != nil {
+		return nil, err
+	}
+	return cuisines, nil
+}
@@ -0,0 +1,96 @@
+package cuisine
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+)
+
+// MemoryRepository keeps cuisines for as long as the process runs.
+type MemoryRepository struct {
+	cuisines map[domain.CuisineType]Cuisine
+	next     domain.CuisineType
+	mu       sync.Mutex
+}
+
+func NewMemoryRepository(seed ...Cuisine) *MemoryRepository {
+	mr := &MemoryRepository{
+		cuisines: make(map[domain.CuisineType]Cuisine, len(seed)),
+		next:     firstID,
+	}
+	for _, c := range seed {
+		mr.cuisines[c.id] = c
+		mr.next = max(mr.next, c.id+1)
+	}
+	return mr
+}
+
+func (mr *MemoryRepository) NextID(_ context.Context) (domain.CuisineType, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	id := mr.next
+	mr.next++
+	return id, nil
+}
+
+func (mr *MemoryRepository) List(_ context.Context) ([]Cuisine, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	cuisines := make([]Cuisine, 0, len(mr.cuisines))
+	for _, c := range mr.cuisines {
+		cuisines = append(cuisines, c)
+	}
+	return cuisines, nil
+}
+
+func (mr *MemoryRepository) Add(_ context.Context, c Cuisine) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if _, ok := mr.cuisines[c.id]; ok || mr.taken(c) {
+		return ErrCuisineExists
+	}
+	mr.cuisines[c.id] = c
+	mr.next = max(mr.next, c.id+1)
+	return nil
+}
+
+func (mr *MemoryRepository) Update(_ context.Context, c Cuisine) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if _, ok := mr.cuisines[c.id]; !ok {
+		return ErrCuisineNotFound
+	}
+	if mr.taken(c) {
+		return ErrCuisineExists
+	}
+	mr.cuisines[c.id] = c
+	return nil
+}
+
+// taken reports whether another cuisine has one of c's names or
+// aliases already.
+func (mr *MemoryRepository) taken(c Cuisine) bool {
+	for id, other := range mr.cuisines {
+		if id == c.id {
+			continue
+		}
+		for _, n := range other.names() {
+			if slices.Contains(c.names(), n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (mr *MemoryRepository) Delete(_ context.Context, id domain.CuisineType) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if _, ok := mr.cuisines[id]; !ok {
+		return ErrCuisineNotFound
+	}
+	delete(mr.cuisines, id)
+	return nil
+}
@@ -0,0 +1,210 @@
+package cuisine
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type MongoRepository struct {
+	client         *mongo.Client
+	databaseName   string
+	collectionName string
+}
+
+func NewMongoRepository(client *mongo.Client, databaseName, collectionName string) *MongoRepository {
+	return &MongoRepository{
+		client:         client,
+		databaseName:   databaseName,
+		collectionName: collectionName,
+	}
+}
+
+func (mr *MongoRepository) collection() *mongo.Collection {
+	return mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+}
+
+// ids holds the counter new cuisine ids are taken from, next to the
+// cuisines themselves.
+func (mr *MongoRepository) ids() *mongo.Collection {
+	return mr.client.Database(mr.databaseName).Collection(mr.collectionName + "_ids")
+}
+
+type counter struct {
+	Next int `bson:"next"`
+}
+
+// reserve makes sure the id counter is past id.
+func (mr *MongoRepository) reserve(ctx context.Context, id domain.CuisineType) error {
+	_, err := mr.ids().UpdateOne(
+		ctx,
+		bson.M{"_id": "cuisine"},
+		bson.M{"$max": bson.M{"next": int(id) + 1}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// Seed creates the unique name indexes, stores seed when the
+// collection is still empty and sets up the id counter. It is safe to
+// call on every start.
+func (mr *MongoRepository) Seed(ctx context.Context, seed []Cuisine) error {
+	collection := mr.collection()
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetName("cuisine_name").SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	if err := mr.indexNames(ctx); err != nil {
+		return err
+	}
+	n, err := collection.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	if n == 0 && len(seed) > 0 {
+		docs := make([]any, 0, len(seed))
+		for _, c := range seed {
+			docs = append(docs, documentFromCuisine(c))
+		}
+		// another instance seeding at the same time is not an error
+		_, err = collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+
+	last := firstID - 1
+	var d document
+	err = collection.FindOne(ctx, bson.D{}, options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})).Decode(&d)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	if err == nil {
+		last = max(last, domain.CuisineType(d.ID))
+	}
+	return mr.reserve(ctx, last)
+}
+
+// indexNames fills in the names of cuisines stored before they had
+// them and makes them unique across cuisines. Where stored cuisines
+// share a name or alias already, the cuisine with the lower id keeps
+// it.
+func (mr *MongoRepository) indexNames(ctx context.Context) error {
+	collection := mr.collection()
+	cur, err := collection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	var docs []document
+	if err := cur.All(ctx, &docs); err != nil {
+		return err
+	}
+	taken := make(map[string]bool)
+	for _, d := range docs {
+		for _, n := range d.Names {
+			taken[n] = true
+		}
+	}
+	for _, d := range docs {
+		if d.Names != nil {
+			continue
+		}
+		names := make([]string, 0, len(d.Aliases)+1)
+		for _, n := range append([]string{d.Name}, d.Aliases...) {
+			if !taken[n] {
+				taken[n] = true
+				names = append(names, n)
+			}
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": d.ID}, bson.M{"$set": bson.M{"names": names}}); err != nil {
+			return err
+		}
+	}
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "names", Value: 1}},
+		Options: options.Index().SetName("cuisine_names").SetUnique(true),
+	})
+	return err
+}
+
+func (mr *MongoRepository) NextID(ctx context.Context) (domain.CuisineType, error) {
+	var c counter
+	err := mr.ids().FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": "cuisine"},
+		bson.M{"$inc": bson.M{"next": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, errors.New("cuisine id counter is missing, cuisines were not seeded")
+	}
+	return domain.CuisineType(c.Next), err
+}
+
+func (mr *MongoRepository) List(ctx context.Context) ([]Cuisine, error) {
+	cur, err := mr.collection().Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var cuisines []Cuisine
+	for cur.Next(ctx) {
+		var d document
+		if err := cur.Decode(&d); err != nil {
+			slog.WarnContext(ctx, "skipping unreadable stored cuisine", "id", cur.Current.Lookup("_id").String(), "err", err.Error())
+			continue
+		}
+		c, err := d.toCuisine()
+		if err != nil {
+			slog.WarnContext(ctx, "skipping unreadable stored cuisine", "id", d.ID, "err", err.Error())
+			continue
+		}
+		cuisines = append(cuisines, c)
+	}
+	return cuisines, cur.Err()
+}
+
+func (mr *MongoRepository) Add(ctx context.Context, c Cuisine) error {
+	_, err := mr.collection().InsertOne(ctx, documentFromCuisine(c))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCuisineExists
+	}
+	if err != nil {
+		return err
+	}
+	return mr.reserve(ctx, c.id)
+}
+
+func (mr *MongoRepository) Update(ctx context.Context, c Cuisine) error {
+	res, err := mr.collection().ReplaceOne(ctx, bson.M{"_id": int(c.id)}, documentFromCuisine(c))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrCuisineExists
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrCuisineNotFound
+	}
+	return nil
+}
+
+func (mr *MongoRepository) Delete(ctx context.Context, id domain.CuisineType) error {
+	res, err := mr.collection().DeleteOne(ctx, bson.M{"_id": int(id)})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrCuisineNotFound
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package cuisine
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/registry"
+)
+
+// Registry is a consistent view of every cuisine: names and aliases
+// are unique and the regional hierarchy is a forest. It is never
+// changed in place; With and Without return a new registry, so one can
+// be shared between goroutines.
+type Registry struct {
+	cuisines map[domain.CuisineType]Cuisine
+	// names maps every name and alias to the cuisine it belongs to
+	names    map[string]domain.CuisineType
+	children map[domain.CuisineType][]domain.CuisineType
+}
+
+// NewRegistry checks the cuisines against each other and indexes them.
+func NewRegistry(cuisines ...Cuisine) (Registry, error) {
+	reg := Registry{
+		cuisines: make(map[domain.CuisineType]Cuisine, len(cuisines)),
+		names:    make(map[string]domain.CuisineType, len(cuisines)),
+		children: make(map[domain.CuisineType][]domain.CuisineType),
+	}
+	for _, c := range cuisines {
+		if _, ok := reg.cuisines[c.id]; ok {
+			return Registry{}, ErrCuisineExists
+		}
+		reg.cuisines[c.id] = c
+		for _, n := range c.names() {
+			if _, ok := reg.names[n]; ok {
+				return Registry{}, ErrCuisineExists
+			}
+			reg.names[n] = c.id
+		}
+	}
+	for _, c := range cuisines {
+		if c.parent == domain.UnknownCuisine {
+			continue
+		}
+		if _, ok := reg.cuisines[c.parent]; !ok {
+			return Registry{}, ErrParentNotFound
+		}
+		reg.children[c.parent] = append(reg.children[c.parent], c.id)
+	}
+	for id := range reg.cuisines {
+		if reg.cyclic(id) {
+			return Registry{}, ErrCuisineCycle
+		}
+		slices.Sort(reg.children[id])
+	}
+	return reg, nil
+}
+
+// LoadRegistry builds a registry from stored cuisines, leaving out the
+// ones that do not fit rather than failing: a cuisine whose id, name
+// or aliases are taken by one with a lower id already, and cuisines
+// whose parent is missing or part of a cycle. It returns the cuisines
+// it left out, so they can be reported and fixed.
+func LoadRegistry(cuisines ...Cuisine) (Registry, []Cuisine) {
+	sorted := slices.Clone(cuisines)
+	slices.SortStableFunc(sorted, func(a, b Cuisine) int {
+		return int(a.id - b.id)
+	})
+
+	var skipped []Cuisine
+	kept := Registry{cuisines: make(map[domain.CuisineType]Cuisine, len(sorted))}
+	names := make(map[string]bool, len(sorted))
+	for _, c := range sorted {
+		_, taken := kept.cuisines[c.id]
+		for _, n := range c.names() {
+			taken = taken || names[n]
+		}
+		if taken {
+			skipped = append(skipped, c)
+			continue
+		}
+		kept.cuisines[c.id] = c
+		for _, n := range c.names() {
+			names[n] = true
+		}
+	}
+	// leaving out a cuisine orphans its regions, so keep going until
+	// every parent is there
+	for changed := true; changed; {
+		changed = false
+		for id, c := range kept.cuisines {
+			if c.parent == domain.UnknownCuisine {
+				continue
+			}
+			if _, ok := kept.cuisines[c.parent]; ok && !kept.cyclic(id) {
+				continue
+			}
+			delete(kept.cuisines, id)
+			skipped = append(skipped, c)
+			changed = true
+		}
+	}
+
+	kept.names = make(map[string]domain.CuisineType, len(names))
+	kept.children = make(map[domain.CuisineType][]domain.CuisineType)
+	for id, c := range kept.cuisines {
+		for _, n := range c.names() {
+			kept.names[n] = id
+		}
+		if c.parent != domain.UnknownCuisine {
+			kept.children[c.parent] = append(kept.children[c.parent], id)
+		}
+	}
+	for id := range kept.children {
+		slices.Sort(kept.children[id])
+	}
+	return kept, skipped
+}
+
+// parent reports the parent of the cuisine with the given id and
+// whether the registry knows it.
+func (reg Registry) parent(id domain.CuisineType) (domain.CuisineType, bool) {
+	c, ok := reg.cuisines[id]
+	return c.parent, ok
+}
+
+// cyclic reports whether walking up from id comes back round.
+func (reg Registry) cyclic(id domain.CuisineType) bool {
+	return registry.Cyclic(id, reg.parent)
+}
+
+// Get returns the cuisine with the given id.
+func (reg Registry) Get(id domain.CuisineType) (Cuisine, bool) {
+	c, ok := reg.cuisines[id]
+	return c, ok
+}
+
+// Lookup finds a cuisine by its name or one of its aliases, ignoring
+// case.
+func (reg Registry) Lookup(name string) (Cuisine, bool) {
+	id, ok := reg.names[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Cuisine{}, false
+	}
+	return reg.cuisines[id], true
+}
+
+// Name is the name of the cuisine with the given id, or "" when the
+// registry does not know it.
+func (reg Registry) Name(id domain.CuisineType) string {
+	return reg.cuisines[id].name
+}
+
+// All returns every cuisine ordered by id.
+func (reg Registry) All() []Cuisine {
+	all := make([]Cuisine, 0, len(reg.cuisines))
+	for _, c := range reg.cuisines {
+		all = append(all, c)
+	}
+	slices.SortFunc(all, func(a, b Cuisine) int {
+		return int(a.id - b.id)
+	})
+	return all
+}
+
+// Regions returns the cuisines directly under the given one.
+func (reg Registry) Regions(id domain.CuisineType) []Cuisine {
+	regions := make([]Cuisine, 0, len(reg.children[id]))
+	for _, child := range reg.children[id] {
+		regions = append(regions, reg.cuisines[child])
+	}
+	return regions
+}
+
+// Expand returns id followed by every cuisine below it at any depth,
+// which is what a recipe has to be to count as of that cuisine:
+// asking for chinese recipes finds sichuan ones too.
+func (reg Registry) Expand(id domain.CuisineType) []domain.CuisineType {
+	return registry.Expand(id, reg.children)
+}
+
+// Ancestors returns id followed by every cuisine it is a region of,
+// nearest first, which are the cuisines a recipe of id counts towards.
+// It is empty when the registry does not know id.
+func (reg Registry) Ancestors(id domain.CuisineType) []domain.CuisineType {
+	return registry.Ancestors(id, reg.parent)
+}
+
+// With adds c, or replaces the cuisine with its id, checking that the
+// registry stays consistent.
+func (reg Registry) With(c Cuisine) (Registry, error) {
+	cuisines := make([]Cuisine, 0, len(reg.cuisines)+1)
+	for _, existing := range reg.cuisines {
+		if existing.id != c.id {
+			cuisines = append(cuisines, existing)
+		}
+	}
+	return NewRegistry(append(cuisines, c)...)
+}
+
+// Without removes the cuisine with the given id. Regional cuisines
+// have to be removed or moved first.
+func (reg Registry) Without(id domain.CuisineType) (Registry, error) {
+	if _, ok := reg.cuisines[id]; !ok {
+		return Registry{}, ErrCuisineNotFound
+	}
+	if len(reg.children[id]) > 0 {
+		return Registry{}, ErrCuisineHasRegion
+	}
+	cuisines := make([]Cuisine, 0, len(reg.cuisines))
+	for _, c := range reg.cuisines {
+		if c.id != id {
+			cuisines = append(cuisines, c)
+		}
+	}
+	return NewRegistry(cuisines...)
+}
@@ -0,0 +1,125 @@
+package cuisine
+
+import (
+	"testing"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCuisine(t *testing.T, id domain.CuisineType, name string, parent domain.CuisineType, aliases ...string) Cuisine {
+	t.Helper()
+	c, err := NewCuisine(id, name, parent, aliases)
+	require.NoError(t, err)
+	return c
+}
+
+func TestNewCuisine(t *testing.T) {
+	c, err := NewCuisine(8, " Sichuan ", domain.Chinese, []string{"Szechuan", "sichuan", "szechuan"})
+	require.NoError(t, err)
+	assert.Equal(t, "sichuan", c.Name())
+	assert.Equal(t, []string{"szechuan"}, c.Aliases())
+	assert.Equal(t, domain.CuisineType(domain.Chinese), c.Parent())
+
+	for name, fn := range map[string]func() (Cuisine, error){
+		"no id":         func() (Cuisine, error) { return NewCuisine(domain.UnknownCuisine, "thai", 0, nil) },
+		"empty name":    func() (Cuisine, error) { return NewCuisine(8, " ", 0, nil) },
+		"unsafe name":   func() (Cuisine, error) { return NewCuisine(8, "thai&lao", 0, nil) },
+		"unsafe alias":  func() (Cuisine, error) { return NewCuisine(8, "thai", 0, []string{"th/ai"}) },
+		"own parent":    func() (Cuisine, error) { return NewCuisine(8, "thai", 8, nil) },
+		"negative root": func() (Cuisine, error) { return NewCuisine(8, "thai", -1, nil) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := fn()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	reg, err := NewRegistry(append(Defaults(),
+		newCuisine(t, 8, "sichuan", domain.Chinese, "szechuan"),
+		newCuisine(t, 9, "chengdu", 8),
+		newCuisine(t, 10, "cantonese", domain.Chinese),
+	)...)
+	require.NoError(t, err)
+
+	t.Run("lookup by name or alias ignoring case", func(t *testing.T) {
+		c, ok := reg.Lookup("Szechuan")
+		require.True(t, ok)
+		assert.Equal(t, domain.CuisineType(8), c.ID())
+		_, ok = reg.Lookup("thai")
+		assert.False(t, ok)
+		assert.Equal(t, "sichuan", reg.Name(8))
+		assert.Equal(t, "", reg.Name(42))
+	})
+
+	t.Run("regions and expansion follow the hierarchy", func(t *testing.T) {
+		var regions []string
+		for _, c := range reg.Regions(domain.Chinese) {
+			regions = append(regions, c.Name())
+		}
+		assert.Equal(t, []string{"sichuan", "cantonese"}, regions)
+		assert.Equal(t, []domain.CuisineType{domain.Chinese, 8, 10, 9}, reg.Expand(domain.Chinese))
+		assert.Equal(t, []domain.CuisineType{domain.Japanese}, reg.Expand(domain.Japanese))
+		assert.Equal(t, []domain.CuisineType{9, 8, domain.Chinese}, reg.Ancestors(9))
+		assert.Empty(t, reg.Ancestors(42))
+	})
+
+	t.Run("names and aliases are unique", func(t *testing.T) {
+		_, err := reg.With(newCuisine(t, 11, "thai", 0, "szechuan"))
+		assert.ErrorIs(t, err, ErrCuisineExists)
+		_, err = reg.With(newCuisine(t, 11, "Chinese", 0))
+		assert.ErrorIs(t, err, ErrCuisineExists)
+		// renaming a cuisine may keep its own aliases
+		renamed, err := reg.With(newCuisine(t, 8, "szechuan", domain.Chinese, "sichuan"))
+		require.NoError(t, err)
+		c, ok := renamed.Lookup("sichuan")
+		require.True(t, ok)
+		assert.Equal(t, "szechuan", c.Name())
+	})
+
+	t.Run("parents exist and never loop", func(t *testing.T) {
+		_, err := reg.With(newCuisine(t, 11, "thai", 42))
+		assert.ErrorIs(t, err, ErrParentNotFound)
+		_, err = reg.With(newCuisine(t, 8, "sichuan", 9))
+		assert.ErrorIs(t, err, ErrCuisineCycle)
+	})
+
+	t.Run("only leaves can be removed", func(t *testing.T) {
+		_, err := reg.Without(8)
+		assert.ErrorIs(t, err, ErrCuisineHasRegion)
+		_, err = reg.Without(42)
+		assert.ErrorIs(t, err, ErrCuisineNotFound)
+		smaller, err := reg.Without(9)
+		require.NoError(t, err)
+		assert.Empty(t, smaller.Regions(8))
+		assert.Len(t, smaller.All(), 8)
+	})
+}
+
+func TestLoadRegistry(t *testing.T) {
+	reg, skipped := LoadRegistry(append(Defaults(),
+		newCuisine(t, 8, "sichuan", domain.Chinese, "szechuan"),
+		newCuisine(t, 9, "chengdu", 8),
+		// takes an alias of sichuan
+		newCuisine(t, 10, "szechuan", 0),
+		// its parent is missing, and so is that of its region
+		newCuisine(t, 11, "lao", 42),
+		newCuisine(t, 12, "luang prabang", 11),
+		newCuisine(t, 13, "north", 14),
+		newCuisine(t, 14, "south", 13),
+	)...)
+
+	var names []string
+	for _, c := range skipped {
+		names = append(names, c.Name())
+	}
+	assert.ElementsMatch(t, []string{"szechuan", "lao", "luang prabang", "north", "south"}, names)
+	assert.Len(t, reg.All(), len(Defaults())+2)
+	c, ok := reg.Lookup("szechuan")
+	require.True(t, ok)
+	assert.Equal(t, domain.CuisineType(8), c.ID())
+	assert.Equal(t, []domain.CuisineType{domain.Chinese, 8, 9}, reg.Expand(domain.Chinese))
+}
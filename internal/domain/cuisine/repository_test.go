@@ -0,0 +1,148 @@
+package cuisine
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bento01dev/cookbook/internal/db"
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// repository mirrors the cuisineRepository interface the recipe
+// service uses.
+type repository interface {
+	List(context.Context) ([]Cuisine, error)
+	NextID(context.Context) (domain.CuisineType, error)
+	Add(context.Context, Cuisine) error
+	Update(context.Context, Cuisine) error
+	Delete(context.Context, domain.CuisineType) error
+}
+
+// testRepository runs the repository contract against a fresh
+// repository seeded with the defaults for every case.
+func testRepository(t *testing.T, newRepo func(t *testing.T) repository) {
+	ctx := context.Background()
+
+	t.Run("starts out with the seed", func(t *testing.T) {
+		got, err := newRepo(t).List(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, Defaults(), got)
+	})
+
+	t.Run("add, update and delete", func(t *testing.T) {
+		repo := newRepo(t)
+		c := newCuisine(t, 8, "sichuan", domain.Chinese, "szechuan")
+		require.NoError(t, repo.Add(ctx, c))
+		assert.ErrorIs(t, repo.Add(ctx, c), ErrCuisineExists)
+
+		renamed := newCuisine(t, 8, "szechuan", domain.Chinese, "sichuan", "chuan")
+		require.NoError(t, repo.Update(ctx, renamed))
+		got, err := repo.List(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, got, renamed)
+
+		require.NoError(t, repo.Delete(ctx, 8))
+		assert.ErrorIs(t, repo.Delete(ctx, 8), ErrCuisineNotFound)
+		assert.ErrorIs(t, repo.Update(ctx, renamed), ErrCuisineNotFound)
+	})
+
+	t.Run("names and aliases are unique across cuisines", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.Add(ctx, newCuisine(t, 8, "sichuan", domain.Chinese, "szechuan")))
+		assert.ErrorIs(t, repo.Add(ctx, newCuisine(t, 9, "szechuan", domain.Chinese)), ErrCuisineExists)
+		assert.ErrorIs(t, repo.Add(ctx, newCuisine(t, 9, "chuan", domain.Chinese, "sichuan")), ErrCuisineExists)
+		assert.ErrorIs(t, repo.Update(ctx, newCuisine(t, domain.French, "french", 0, "szechuan")), ErrCuisineExists)
+
+		// a cuisine keeps its own names when it is renamed
+		require.NoError(t, repo.Update(ctx, newCuisine(t, 8, "szechuan", domain.Chinese, "sichuan")))
+		require.NoError(t, repo.Add(ctx, newCuisine(t, 9, "chengdu", 8)))
+		got, err := repo.List(ctx)
+		require.NoError(t, err)
+		assert.Len(t, got, len(Defaults())+2)
+	})
+
+	t.Run("ids are never handed out twice", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.NextID(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, domain.CuisineType(domain.Western+1), id)
+
+		require.NoError(t, repo.Add(ctx, newCuisine(t, id, "sichuan", domain.Chinese)))
+		require.NoError(t, repo.Delete(ctx, id))
+		next, err := repo.NextID(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, id+1, next)
+
+		// a cuisine added with an id of its own moves the counter past it
+		require.NoError(t, repo.Add(ctx, newCuisine(t, 20, "thai", 0)))
+		next, err = repo.NextID(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, domain.CuisineType(21), next)
+	})
+}
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) repository {
+		return NewMemoryRepository(Defaults()...)
+	})
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) repository {
+		sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "cookbook.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			sqlDB.Close()
+		})
+
+		repo := NewSQLiteRepository(sqlDB)
+		require.NoError(t, repo.Migrate(context.Background()))
+		// migrating an up to date schema is a no-op
+		require.NoError(t, repo.Migrate(context.Background()))
+		require.NoError(t, repo.Seed(context.Background(), Defaults()))
+		// seeding a registry that has cuisines is a no-op
+		require.NoError(t, repo.Seed(context.Background(), []Cuisine{newCuisine(t, 8, "thai", 0)}))
+		return repo
+	})
+}
+
+func TestSQLiteRepositoryUpgrade(t *testing.T) {
+	ctx := context.Background()
+	sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "cookbook.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+	// the table as it was created before there were migrations, with
+	// an alias taken twice and a row that does not read
+	_, err = sqlDB.ExecContext(ctx, `CREATE TABLE cuisines (
+		id      INTEGER PRIMARY KEY,
+		name    TEXT NOT NULL UNIQUE,
+		aliases TEXT NOT NULL,
+		parent  INTEGER NOT NULL
+	)`)
+	require.NoError(t, err)
+	_, err = sqlDB.ExecContext(ctx, `INSERT INTO cuisines (id, name, aliases, parent) VALUES
+		(8, 'sichuan', '["szechuan"]', 5),
+		(9, 'chuan', '["szechuan"]', 5),
+		(10, 'thai', 'not json', 0)`)
+	require.NoError(t, err)
+
+	repo := NewSQLiteRepository(sqlDB)
+	require.NoError(t, repo.Migrate(ctx))
+	got, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Cuisine{
+		newCuisine(t, 8, "sichuan", domain.Chinese, "szechuan"),
+		newCuisine(t, 9, "chuan", domain.Chinese, "szechuan"),
+	}, got)
+
+	// the lower id kept the alias
+	assert.ErrorIs(t, repo.Update(ctx, newCuisine(t, 9, "chuan", domain.Chinese, "szechuan")), ErrCuisineExists)
+	id, err := repo.NextID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CuisineType(11), id)
+}
@@ -0,0 +1,245 @@
+package cuisine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/bento01dev/cookbook/internal/db"
+	"github.com/bento01dev/cookbook/internal/domain"
+)
+
+// SQLiteRepository keeps cuisines in their own table next to the
+// recipes. Aliases are stored as a JSON array and, together with the
+// names, in an index that keeps them unique.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{db: db}
+}
+
+var migrations = []db.Migration{
+	// cuisines used to be created on every start, so the table may
+	// predate the migrations
+	db.Exec(`CREATE TABLE IF NOT EXISTS cuisines (
+		id      INTEGER PRIMARY KEY,
+		name    TEXT NOT NULL UNIQUE,
+		aliases TEXT NOT NULL,
+		parent  INTEGER NOT NULL
+	)`),
+	migrateIDs,
+	migrateNames,
+}
+
+// migrateIDs keeps the next id to hand out in a table of its own, so
+// ids are not handed out twice, not even once their cuisine is gone.
+func migrateIDs(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE cuisine_ids (next INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO cuisine_ids (next) SELECT MAX(COALESCE(MAX(id) + 1, 0), ?) FROM cuisines`,
+		int(firstID),
+	)
+	return err
+}
+
+// migrateNames indexes every name and alias in a table of their own,
+// which keeps them unique across cuisines. Where stored cuisines share
+// one already, the cuisine with the lower id keeps it.
+func migrateNames(ctx context.Context, tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`CREATE TABLE cuisine_names (
+			name       TEXT PRIMARY KEY,
+			cuisine_id INTEGER NOT NULL REFERENCES cuisines (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX cuisine_names_cuisine_id ON cuisine_names (cuisine_id)`,
+		`INSERT OR IGNORE INTO cuisine_names (name, cuisine_id) SELECT name, id FROM cuisines ORDER BY id`,
+		`INSERT OR IGNORE INTO cuisine_names (name, cuisine_id)
+			SELECT a.value, c.id FROM cuisines c, json_each(c.aliases) a
+			WHERE json_valid(c.aliases) ORDER BY c.id`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migrate brings the schema up to date.
+func (sr *SQLiteRepository) Migrate(ctx context.Context) error {
+	return db.Migrate(ctx, sr.db, "cuisine_migrations", migrations)
+}
+
+// Seed stores seed when the cuisines table is still empty. It is safe
+// to call on every start, after Migrate.
+func (sr *SQLiteRepository) Seed(ctx context.Context, seed []Cuisine) error {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM cuisines`).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	for _, c := range seed {
+		if err := insertCuisine(ctx, tx, c); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type execer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}
+
+func insertCuisine(ctx context.Context, ex execer, c Cuisine) error {
+	aliases, err := json.Marshal(c.names()[1:])
+	if err != nil {
+		return err
+	}
+	_, err = ex.ExecContext(
+		ctx,
+		`INSERT INTO cuisines (id, name, aliases, parent) VALUES (?, ?, ?, ?)`,
+		int(c.id), c.name, string(aliases), int(c.parent),
+	)
+	if db.IsUniqueViolation(err) {
+		return ErrCuisineExists
+	}
+	if err != nil {
+		return err
+	}
+	if err := insertNames(ctx, ex, c); err != nil {
+		return err
+	}
+	// cuisines added with an id of their own, the seed among them,
+	// must not be handed theirs again
+	_, err = ex.ExecContext(ctx, `UPDATE cuisine_ids SET next = MAX(next, ?)`, int(c.id)+1)
+	return err
+}
+
+// insertNames claims c's name and aliases, failing with
+// ErrCuisineExists when another cuisine has one of them.
+func insertNames(ctx context.Context, ex execer, c Cuisine) error {
+	for _, n := range c.names() {
+		_, err := ex.ExecContext(ctx, `INSERT INTO cuisine_names (name, cuisine_id) VALUES (?, ?)`, n, int(c.id))
+		if db.IsUniqueViolation(err) {
+			return ErrCuisineExists
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns the stored cuisines, leaving out and logging the ones
+// that cannot be read rather than failing on them.
+func (sr *SQLiteRepository) List(ctx context.Context) ([]Cuisine, error) {
+	rows, err := sr.db.QueryContext(ctx, `SELECT id, name, aliases, parent FROM cuisines`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cuisines []Cuisine
+	for rows.Next() {
+		var d document
+		var aliases string
+		if err := rows.Scan(&d.ID, &d.Name, &aliases, &d.Parent); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(aliases), &d.Aliases); err != nil {
+			slog.WarnContext(ctx, "skipping unreadable stored cuisine", "id", d.ID, "err", err.Error())
+			continue
+		}
+		c, err := d.toCuisine()
+		if err != nil {
+			slog.WarnContext(ctx, "skipping unreadable stored cuisine", "id", d.ID, "err", err.Error())
+			continue
+		}
+		cuisines = append(cuisines, c)
+	}
+	return cuisines, rows.Err()
+}
+
+func (sr *SQLiteRepository) NextID(ctx context.Context) (domain.CuisineType, error) {
+	var id int
+	err := sr.db.QueryRowContext(ctx, `UPDATE cuisine_ids SET next = next + 1 RETURNING next - 1`).Scan(&id)
+	return domain.CuisineType(id), err
+}
+
+func (sr *SQLiteRepository) Add(ctx context.Context, c Cuisine) error {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := insertCuisine(ctx, tx, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (sr *SQLiteRepository) Update(ctx context.Context, c Cuisine) error {
+	aliases, err := json.Marshal(c.names()[1:])
+	if err != nil {
+		return err
+	}
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE cuisines SET name = ?, aliases = ?, parent = ? WHERE id = ?`,
+		c.name, string(aliases), int(c.parent), int(c.id),
+	)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return ErrCuisineExists
+		}
+		return err
+	}
+	if err := affected(res); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM cuisine_names WHERE cuisine_id = ?`, int(c.id)); err != nil {
+		return err
+	}
+	if err := insertNames(ctx, tx, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (sr *SQLiteRepository) Delete(ctx context.Context, id domain.CuisineType) error {
+	res, err := sr.db.ExecContext(ctx, `DELETE FROM cuisines WHERE id = ?`, int(id))
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCuisineNotFound
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package domain
+
+// DietaryTag marks a recipe as suitable for a diet.
+type DietaryTag int
+
+const (
+	UnknownDiet = iota
+	Vegetarian
+	Vegan
+	GlutenFree
+	DairyFree
+	NutFree
+	Halal
+	Kosher
+)
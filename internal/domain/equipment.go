@@ -0,0 +1,11 @@
+package domain
+
+// Equipment is what a cooking step occupies while it runs, besides the
+// cook.
+type Equipment int
+
+const (
+	NoEquipment = iota
+	Oven
+	Hob
+)
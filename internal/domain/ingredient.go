@@ -2,8 +2,13 @@ package domain
 
 import "github.com/google/uuid"
 
+// IngredientType identifies a category in the ingredient taxonomy.
+// Ingredients store only the id, so categories can be renamed or moved
+// around the tree without touching them.
 type IngredientType int
 
+// The categories every taxonomy starts out with. The first five keep
+// the ids they had while the types were a fixed list.
 const (
 	UnknownIngredient = iota
 	Vegetable
@@ -11,6 +16,17 @@ const (
 	Poultry
 	Fish
 	Condiments
+	Protein
+	Meat
+	RedMeat
+	Shellfish
+	Eggs
+	Legumes
+	Dairy
+	Cheese
+	Grains
+	HerbsAndSpices
+	NutsAndSeeds
 )
 
 type Ingredient struct {
@@ -18,4 +34,7 @@ type Ingredient struct {
 	Name        string
 	Description string
 	Type        IngredientType
+	// Density in grams per millilitre, used to convert between volume
+	// and mass. Zero when unknown.
+	Density float64
 }
@@ -2,8 +2,13 @@ package domain
 
 import "github.com/google/uuid"
 
+// CuisineType identifies a cuisine in the cuisine registry. Recipes
+// store only the id, so a cuisine can be renamed or given aliases
+// without touching them.
 type CuisineType int
 
+// The cuisines every registry starts out with. Their ids are fixed as
+// recipes were stored with them before cuisines became data.
 const (
 	UnknownCuisine = iota
 	Japanese
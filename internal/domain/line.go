@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bento01dev/cookbook/internal/domain/units"
+)
+
+var (
+	ErrInvalidIngredient = errors.New("invalid ingredient")
+	ErrInvalidQuantity   = errors.New("invalid quantity for ingredient")
+)
+
+// IngredientLine is a single entry in a recipe's ingredient list,
+// e.g. "200 g flour, sifted".
+type IngredientLine struct {
+	ingredient Ingredient
+	quantity   units.Quantity
+	note       string
+}
+
+func NewIngredientLine(ingredient Ingredient, amount float64, unit string, note string) (IngredientLine, error) {
+	if strings.TrimSpace(ingredient.Name) == "" {
+		return IngredientLine{}, ErrInvalidIngredient
+	}
+	if amount < 0 || ingredient.Density < 0 {
+		return IngredientLine{}, ErrInvalidQuantity
+	}
+	return IngredientLine{
+		ingredient: ingredient,
+		quantity:   units.Quantity{Amount: amount, Unit: units.Parse(unit)},
+		note:       strings.TrimSpace(note),
+	}, nil
+}
+
+func (l IngredientLine) Ingredient() Ingredient {
+	return l.ingredient
+}
+
+func (l IngredientLine) Quantity() units.Quantity {
+	return l.quantity
+}
+
+func (l IngredientLine) Note() string {
+	return l.note
+}
+
+// WithIngredient returns the line for an updated version of its
+// ingredient, keeping the quantity and note.
+func (l IngredientLine) WithIngredient(ingredient Ingredient) IngredientLine {
+	l.ingredient = ingredient
+	return l
+}
+
+// Scale returns the line with its quantity multiplied by factor.
+func (l IngredientLine) Scale(factor float64) IngredientLine {
+	if l.quantity.Amount == 0 {
+		return l
+	}
+	l.quantity = l.quantity.Scale(factor)
+	return l
+}
+
+// InSystem returns the line with its quantity rendered in the given
+// measurement system, using the ingredient's density where needed.
+func (l IngredientLine) InSystem(sys units.System) IngredientLine {
+	l.quantity = l.quantity.InSystem(sys, l.ingredient.Density)
+	return l
+}
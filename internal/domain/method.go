@@ -0,0 +1,53 @@
+package domain
+
+import "github.com/bento01dev/cookbook/internal/domain/units"
+
+// CookingMethod is how a step cooks.
+type CookingMethod int
+
+const (
+	UnknownMethod = iota
+	Bake
+	Roast
+	Grill
+	Fry
+	DeepFry
+	Saute
+	Boil
+	Simmer
+	Poach
+	Steam
+	Braise
+	SousVide
+)
+
+// plausible holds the range of temperatures, in Celsius, each method
+// is carried out at. Grilling goes above what any oven dial shows and
+// boiling and steaming allow for altitude and pressure.
+var plausible = map[CookingMethod]struct{ min, max float64 }{
+	Bake:     {100, 260},
+	Roast:    {120, 260},
+	Grill:    {150, 350},
+	Fry:      {120, 230},
+	DeepFry:  {150, 200},
+	Saute:    {120, 230},
+	Boil:     {90, 120},
+	Simmer:   {75, 100},
+	Poach:    {60, 95},
+	Steam:    {95, 120},
+	Braise:   {90, 180},
+	SousVide: {40, 95},
+}
+
+// Plausible reports whether t is a temperature the method is carried
+// out at. No temperature, and any temperature for an unknown method,
+// is plausible.
+func (m CookingMethod) Plausible(t units.Temperature) bool {
+	r, ok := plausible[m]
+	if !ok || t.IsZero() {
+		return true
+	}
+	c := t.Celsius()
+	// conversions to and from gas marks are not exact
+	return c >= r.min-5 && c <= r.max+5
+}
@@ -1,9 +1,39 @@
 package domain
 
-import "github.com/google/uuid"
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidPairing = errors.New("pairing needs two different items")
 
 type Pairing struct {
 	base        uuid.UUID
 	with        uuid.UUID
 	description string
 }
+
+func NewPairing(base uuid.UUID, with uuid.UUID, description string) (Pairing, error) {
+	if base == uuid.Nil || with == uuid.Nil || base == with {
+		return Pairing{}, ErrInvalidPairing
+	}
+	return Pairing{
+		base:        base,
+		with:        with,
+		description: strings.TrimSpace(description),
+	}, nil
+}
+
+func (p Pairing) Base() uuid.UUID {
+	return p.base
+}
+
+func (p Pairing) With() uuid.UUID {
+	return p.with
+}
+
+func (p Pairing) Description() string {
+	return p.description
+}
@@ -1,6 +1,13 @@
 package domain
 
-import "github.com/google/uuid"
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidPrep = errors.New("prep needs an ingredient and an action")
 
 type Prep struct {
 	ingredient uuid.UUID
@@ -8,10 +15,35 @@ type Prep struct {
 	index      int
 }
 
+func NewPrep(ingredient uuid.UUID, action string) (Prep, error) {
+	action = strings.TrimSpace(action)
+	if ingredient == uuid.Nil || action == "" {
+		return Prep{}, ErrInvalidPrep
+	}
+	return Prep{
+		ingredient: ingredient,
+		action:     action,
+	}, nil
+}
+
 func (p Prep) Ingredient() string {
 	return p.ingredient.String()
 }
 
+func (p Prep) IngredientID() uuid.UUID {
+	return p.ingredient
+}
+
 func (p Prep) Action() string {
 	return p.action
 }
+
+func (p Prep) Index() int {
+	return p.index
+}
+
+// WithIndex returns the prep step placed at the given position.
+func (p Prep) WithIndex(index int) Prep {
+	p.index = index
+	return p
+}
@@ -0,0 +1,401 @@
+package recipe
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/units"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// schemaVersion is bumped whenever the stored layout of a recipe
+// changes. Documents written before versioning was introduced have no
+// schema_version and are read as version 0.
+//
+// Version 2 added the sort_id and modified_at fields, which duplicate
+// the id and timestamps in a form that sorts and filters correctly.
+// They are derived on every write, so version 1 documents read as is.
+//
+// Version 3 added prep and cook times, in seconds, with their derived
+// total_time, and dietary tags. Older documents read as having no
+// known times and no tags.
+//
+// Version 4 added parent_id and overrides for variations, which are
+// recipes of their own deriving from a parent. Older documents are
+// never variations.
+//
+// Version 5 added the index of cooking steps. Steps are read back in
+// index order, and older documents, where every step reads as index 0,
+// keep the order they were stored in.
+//
+// Version 6 added the active and passive time of cooking steps, in
+// seconds, and the positions of the steps each one depends on. Older
+// steps read as taking no known time and depending on nothing.
+//
+// Version 7 added the equipment a cooking step uses. Older steps read
+// as using none.
+//
+// Version 8 added the unit of step temperatures and the cooking
+// method. Older temperatures were always given in Celsius and older
+// steps read as having no known method.
+//
+// Version 9 added the version of the recipe, which repositories
+// compare on every update. Older documents read as version 0.
+//
+// Version 10 added the ingredient_keys and techniques similar recipes
+// are found by. They are derived on every write, and older documents
+// are rewritten when the Mongo repository starts.
+//
+// Version 11 left common knife work such as chopping out of the
+// techniques. The layout is unchanged; older documents are rewritten
+// the same way to drop it.
+const schemaVersion = 11
+
+// recipe is the stored form of the Recipe aggregate. Every field of
+// the aggregate is mapped so that a recipe reads back exactly as it
+// was written, whatever the repository.
+type recipe struct {
+	SchemaVersion int              `bson:"schema_version"`
+	ID            uuid.UUID        `bson:"id"`
+	Name          string           `bson:"name"`
+	Description   string           `bson:"description"`
+	Cuisine       int              `bson:"cuisine"`
+	Servings      int              `bson:"servings"`
+	PrepTime      int64            `bson:"prep_time,omitempty"`
+	CookTime      int64            `bson:"cook_time,omitempty"`
+	TotalTime     int64            `bson:"total_time"`
+	DietaryTags   []int            `bson:"dietary_tags"`
+	Ingredients   []ingredientLine `bson:"ingredients"`
+	PrepSteps     []prep           `bson:"prep_steps"`
+	Steps         []step           `bson:"steps"`
+	Variations    []variation      `bson:"variations"`
+	Pairings      []pairing        `bson:"pairings"`
+	ParentID      *uuid.UUID       `bson:"parent_id,omitempty"`
+	Overrides     []override       `bson:"overrides,omitempty"`
+	CreatedAt     time.Time        `bson:"created_at"`
+	UpdatedAt     *time.Time       `bson:"updated_at,omitempty"`
+	DeletedAt     *time.Time       `bson:"deleted_at,omitempty"`
+	SortID        string           `bson:"sort_id"`
+	ModifiedAt    time.Time        `bson:"modified_at"`
+	Version       int              `bson:"version"`
+	// IngredientKeys and Techniques are derived, see Traits
+	IngredientKeys []string `bson:"ingredient_keys"`
+	Techniques     []string `bson:"techniques"`
+}
+
+type ingredientLine struct {
+	IngredientID uuid.UUID `bson:"ingredient_id"`
+	Name         string    `bson:"name"`
+	Description  string    `bson:"description"`
+	Type         int       `bson:"type"`
+	Density      float64   `bson:"density,omitempty"`
+	Quantity     float64   `bson:"quantity"`
+	Unit         string    `bson:"unit"`
+	Note         string    `bson:"note,omitempty"`
+}
+
+type prep struct {
+	IngredientID uuid.UUID `bson:"ingredient_id"`
+	Action       string    `bson:"action"`
+	Index        int       `bson:"index"`
+}
+
+type step struct {
+	IngredientID uuid.UUID `bson:"ingredient_id"`
+	Action       string    `bson:"action"`
+	Temperature  float64   `bson:"temperature"`
+	Unit         int       `bson:"temperature_unit,omitempty"`
+	Method       int       `bson:"method,omitempty"`
+	Index        int       `bson:"index"`
+	Active       int64     `bson:"active,omitempty"`
+	Passive      int64     `bson:"passive,omitempty"`
+	After        []int     `bson:"after,omitempty"`
+	Equipment    int       `bson:"equipment,omitempty"`
+}
+
+type variation struct {
+	Item        uuid.UUID `bson:"item"`
+	Description string    `bson:"description"`
+}
+
+// override leaves Line out when the parent ingredient is omitted.
+type override struct {
+	IngredientID uuid.UUID       `bson:"ingredient_id"`
+	Line         *ingredientLine `bson:"line,omitempty"`
+}
+
+type pairing struct {
+	Base        uuid.UUID `bson:"base"`
+	With        uuid.UUID `bson:"with"`
+	Description string    `bson:"description"`
+}
+
+// recipeV0 is the layout written before schema versioning, when only
+// the item fields were stored and created_at was a bson timestamp.
+type recipeV0 struct {
+	ID          uuid.UUID      `bson:"id"`
+	Name        string         `bson:"name"`
+	Description string         `bson:"description"`
+	CreatedAt   bson.Timestamp `bson:"created_at"`
+}
+
+// decodeRecipe reads a stored recipe of any known schema version and
+// upgrades it to the current layout.
+func decodeRecipe(raw bson.Raw) (recipe, error) {
+	var version int
+	if v, err := raw.LookupErr("schema_version"); err == nil {
+		version = int(v.AsInt64())
+	}
+
+	switch version {
+	case 0:
+		var old recipeV0
+		if err := bson.Unmarshal(raw, &old); err != nil {
+			return recipe{}, err
+		}
+		return recipe{
+			SchemaVersion: schemaVersion,
+			ID:            old.ID,
+			Name:          old.Name,
+			Description:   old.Description,
+			CreatedAt:     time.Unix(int64(old.CreatedAt.T), 0).UTC(),
+		}, nil
+	case 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, schemaVersion:
+		var doc recipe
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return recipe{}, err
+		}
+		return doc, nil
+	default:
+		return recipe{}, fmt.Errorf("unsupported recipe schema version %d", version)
+	}
+}
+
+func (l ingredientLine) toLine() (domain.IngredientLine, error) {
+	ingredient := domain.Ingredient{
+		ID:          l.IngredientID,
+		Name:        l.Name,
+		Description: l.Description,
+		Type:        domain.IngredientType(l.Type),
+		Density:     l.Density,
+	}
+	return domain.NewIngredientLine(ingredient, l.Quantity, l.Unit, l.Note)
+}
+
+func lineFromLine(l domain.IngredientLine) ingredientLine {
+	ingredient := l.Ingredient()
+	return ingredientLine{
+		IngredientID: ingredient.ID,
+		Name:         ingredient.Name,
+		Description:  ingredient.Description,
+		Type:         int(ingredient.Type),
+		Density:      ingredient.Density,
+		Quantity:     l.Quantity().Amount,
+		Unit:         l.Quantity().Unit.String(),
+		Note:         l.Note(),
+	}
+}
+
+func (r recipe) ToRecipe() (Recipe, error) {
+	ingredients := make([]domain.IngredientLine, 0, len(r.Ingredients))
+	for _, v := range r.Ingredients {
+		line, err := v.toLine()
+		if err != nil {
+			return Recipe{}, fmt.Errorf("invalid ingredient line for recipe %s: %w", r.ID, err)
+		}
+		ingredients = append(ingredients, line)
+	}
+	prepSteps := make([]domain.Prep, 0, len(r.PrepSteps))
+	for _, v := range r.PrepSteps {
+		p, err := domain.NewPrep(v.IngredientID, v.Action)
+		if err != nil {
+			return Recipe{}, fmt.Errorf("invalid prep step for recipe %s: %w", r.ID, err)
+		}
+		prepSteps = append(prepSteps, p.WithIndex(v.Index))
+	}
+	steps := make([]domain.Step, 0, len(r.Steps))
+	for _, v := range r.Steps {
+		temperature := units.Temperature{Value: v.Temperature, Unit: units.TemperatureUnit(v.Unit)}
+		if temperature.Value != 0 && temperature.Unit == units.UnknownTemperatureUnit {
+			temperature.Unit = units.Celsius
+		}
+		s, err := domain.StoredStep(v.IngredientID, v.Action, temperature, domain.CookingMethod(v.Method))
+		if err != nil {
+			return Recipe{}, fmt.Errorf("invalid step for recipe %s: %w", r.ID, err)
+		}
+		if s, err = s.WithDurations(time.Duration(v.Active)*time.Second, time.Duration(v.Passive)*time.Second); err != nil {
+			return Recipe{}, fmt.Errorf("invalid step for recipe %s: %w", r.ID, err)
+		}
+		if s, err = s.WithDependencies(v.After); err != nil {
+			return Recipe{}, fmt.Errorf("invalid step for recipe %s: %w", r.ID, err)
+		}
+		// steps only wait for earlier ones, which scheduling relies on
+		for _, after := range s.After() {
+			if after >= len(steps) {
+				return Recipe{}, fmt.Errorf("invalid step for recipe %s: %w", r.ID, ErrStepOrder)
+			}
+		}
+		if s, err = s.WithEquipment(domain.Equipment(v.Equipment)); err != nil {
+			return Recipe{}, fmt.Errorf("invalid step for recipe %s: %w", r.ID, err)
+		}
+		steps = append(steps, s.WithIndex(v.Index))
+	}
+	variations := make([]domain.Variation, 0, len(r.Variations))
+	for _, v := range r.Variations {
+		vr, err := domain.NewVariation(v.Item, v.Description)
+		if err != nil {
+			return Recipe{}, fmt.Errorf("invalid variation for recipe %s: %w", r.ID, err)
+		}
+		variations = append(variations, vr)
+	}
+	pairings := make([]domain.Pairing, 0, len(r.Pairings))
+	for _, v := range r.Pairings {
+		p, err := domain.NewPairing(v.Base, v.With, v.Description)
+		if err != nil {
+			return Recipe{}, fmt.Errorf("invalid pairing for recipe %s: %w", r.ID, err)
+		}
+		pairings = append(pairings, p)
+	}
+	var parent uuid.UUID
+	if r.ParentID != nil {
+		parent = *r.ParentID
+	}
+	overrides := make([]Override, 0, len(r.Overrides))
+	for _, v := range r.Overrides {
+		if v.Line == nil {
+			overrides = append(overrides, Omit(v.IngredientID))
+			continue
+		}
+		line, err := v.Line.toLine()
+		if err != nil {
+			return Recipe{}, fmt.Errorf("invalid override for recipe %s: %w", r.ID, err)
+		}
+		overrides = append(overrides, Swap(v.IngredientID, line))
+	}
+	tags := make([]domain.DietaryTag, 0, len(r.DietaryTags))
+	for _, v := range r.DietaryTags {
+		tags = append(tags, domain.DietaryTag(v))
+	}
+	tags, err := dietaryTags(tags)
+	if err != nil {
+		return Recipe{}, fmt.Errorf("invalid dietary tags for recipe %s: %w", r.ID, err)
+	}
+	var updatedAt, deletedAt time.Time
+	if r.UpdatedAt != nil {
+		updatedAt = r.UpdatedAt.UTC()
+	}
+	if r.DeletedAt != nil {
+		deletedAt = r.DeletedAt.UTC()
+	}
+	return Recipe{
+		item: &domain.Item{
+			ID:          r.ID,
+			Name:        r.Name,
+			Description: r.Description,
+			Cuisine:     domain.CuisineType(r.Cuisine),
+		},
+		ingredients: ingredients,
+		prepSteps:   sortPrep(prepSteps),
+		steps:       sortSteps(steps),
+		variations:  variations,
+		pairings:    pairings,
+		parent:      parent,
+		overrides:   overrides,
+		servings:    r.Servings,
+		prepTime:    time.Duration(r.PrepTime) * time.Second,
+		cookTime:    time.Duration(r.CookTime) * time.Second,
+		dietaryTags: tags,
+		createdAt:   r.CreatedAt.UTC(),
+		updatedAt:   updatedAt,
+		deletedAt:   deletedAt,
+		version:     r.Version,
+	}, nil
+}
+
+func recipeFromRecipe(r Recipe) recipe {
+	ingredients := make([]ingredientLine, 0, len(r.ingredients))
+	for _, v := range r.ingredients {
+		ingredients = append(ingredients, lineFromLine(v))
+	}
+	prepSteps := make([]prep, 0, len(r.prepSteps))
+	for _, v := range r.prepSteps {
+		prepSteps = append(prepSteps, prep{IngredientID: v.IngredientID(), Action: v.Action(), Index: v.Index()})
+	}
+	steps := make([]step, 0, len(r.steps))
+	for _, v := range r.steps {
+		steps = append(steps, step{
+			IngredientID: v.IngredientID(),
+			Action:       v.Action(),
+			Temperature:  v.Temperature().Value,
+			Unit:         int(v.Temperature().Unit),
+			Method:       int(v.Method()),
+			Index:        v.Index(),
+			Active:       int64(v.Active() / time.Second),
+			Passive:      int64(v.Passive() / time.Second),
+			After:        v.After(),
+			Equipment:    int(v.Equipment()),
+		})
+	}
+	variations := make([]variation, 0, len(r.variations))
+	for _, v := range r.variations {
+		variations = append(variations, variation{Item: v.Item(), Description: v.Variation()})
+	}
+	pairings := make([]pairing, 0, len(r.pairings))
+	for _, v := range r.pairings {
+		pairings = append(pairings, pairing{Base: v.Base(), With: v.With(), Description: v.Description()})
+	}
+	var parent *uuid.UUID
+	if r.IsVariation() {
+		parent = &r.parent
+	}
+	overrides := make([]override, 0, len(r.overrides))
+	for _, v := range r.overrides {
+		o := override{IngredientID: v.ingredientID}
+		if v.line != nil {
+			line := lineFromLine(*v.line)
+			o.Line = &line
+		}
+		overrides = append(overrides, o)
+	}
+	tags := make([]int, 0, len(r.dietaryTags))
+	for _, v := range r.dietaryTags {
+		tags = append(tags, int(v))
+	}
+	var updatedAt, deletedAt *time.Time
+	if !r.updatedAt.IsZero() {
+		updatedAt = &r.updatedAt
+	}
+	if r.Deleted() {
+		deletedAt = &r.deletedAt
+	}
+	traits := r.Traits()
+	return recipe{
+		SchemaVersion:  schemaVersion,
+		ID:             r.item.ID,
+		Name:           r.item.Name,
+		Description:    r.item.Description,
+		Cuisine:        int(r.item.Cuisine),
+		Servings:       r.servings,
+		PrepTime:       int64(r.prepTime / time.Second),
+		CookTime:       int64(r.cookTime / time.Second),
+		TotalTime:      int64(r.TotalTime() / time.Second),
+		DietaryTags:    tags,
+		Ingredients:    ingredients,
+		PrepSteps:      prepSteps,
+		Steps:          steps,
+		Variations:     variations,
+		Pairings:       pairings,
+		ParentID:       parent,
+		Overrides:      overrides,
+		CreatedAt:      r.createdAt,
+		UpdatedAt:      updatedAt,
+		DeletedAt:      deletedAt,
+		SortID:         r.item.ID.String(),
+		ModifiedAt:     r.modifiedAt(),
+		Version:        r.version,
+		IngredientKeys: traits.Ingredients,
+		Techniques:     traits.Techniques,
+	}
+}
@@ -0,0 +1,164 @@
+package recipe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/units"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func fullRecipe(t *testing.T) Recipe {
+	t.Helper()
+	r, err := NewRecipe("pancakes", "fluffy", domain.Western, 4)
+	require.NoError(t, err)
+	egg := newLine(t, "egg", 3, "")
+	r.AddIngredient(egg)
+	r.AddIngredient(newLine(t, "flour", 2, "cup"))
+	p, err := domain.NewPrep(egg.Ingredient().ID, "beat")
+	require.NoError(t, err)
+	require.NoError(t, r.AddPrep(p))
+	s, err := domain.NewStep(egg.Ingredient().ID, "fry", units.Temperature{Value: 180, Unit: units.Celsius})
+	require.NoError(t, err)
+	s, err = s.WithDurations(2*time.Minute, 3*time.Minute)
+	require.NoError(t, err)
+	s, err = s.WithEquipment(domain.Hob)
+	require.NoError(t, err)
+	s, err = s.WithMethod(domain.Fry)
+	require.NoError(t, err)
+	require.NoError(t, r.AddStep(s))
+	s, err = domain.NewStep(uuid.Nil, "serve", units.Temperature{})
+	require.NoError(t, err)
+	s, err = s.WithDependencies([]int{0})
+	require.NoError(t, err)
+	require.NoError(t, r.AddStep(s))
+	v, err := domain.NewVariation(r.ID(), "blueberry")
+	require.NoError(t, err)
+	r.AddVariation(v)
+	pairing, err := domain.NewPairing(r.ID(), uuid.New(), "maple syrup")
+	require.NoError(t, err)
+	require.NoError(t, r.AddPairing(pairing))
+	prep, cook := 10*time.Minute, 20*time.Minute
+	require.NoError(t, r.Apply(Patch{
+		PrepTime:    &prep,
+		CookTime:    &cook,
+		DietaryTags: &[]domain.DietaryTag{domain.Vegetarian, domain.NutFree},
+	}))
+	require.NoError(t, r.Delete())
+	return r
+}
+
+func TestDocumentRoundTrip(t *testing.T) {
+	r := fullRecipe(t)
+
+	raw, err := bson.Marshal(recipeFromRecipe(r))
+	require.NoError(t, err)
+	doc, err := decodeRecipe(raw)
+	require.NoError(t, err)
+	got, err := doc.ToRecipe()
+	require.NoError(t, err)
+
+	assert.Equal(t, r, got)
+}
+
+func TestDecodeLegacyDocument(t *testing.T) {
+	id := uuid.New()
+	created := time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)
+	raw, err := bson.Marshal(recipeV0{
+		ID:          id,
+		Name:        "ramen",
+		Description: "tonkotsu",
+		CreatedAt:   bson.Timestamp{T: uint32(created.Unix())},
+	})
+	require.NoError(t, err)
+
+	doc, err := decodeRecipe(raw)
+	require.NoError(t, err)
+	got, err := doc.ToRecipe()
+	require.NoError(t, err)
+
+	assert.Equal(t, id, got.ID())
+	assert.Equal(t, "ramen", got.Name())
+	assert.Equal(t, created.Format(time.RFC3339), got.CreatedAt())
+	assert.Empty(t, got.Ingredients())
+}
+
+func TestVariationDocumentRoundTrip(t *testing.T) {
+	parent := fullRecipe(t)
+	child, err := NewVariation(parent, "vegan pancakes", "")
+	require.NoError(t, err)
+	lines := parent.Ingredients()
+	require.NoError(t, child.Override(parent, Swap(lines[0].Ingredient().ID, newLine(t, "flax egg", 1, ""))))
+	require.NoError(t, child.Override(parent, Omit(lines[1].Ingredient().ID)))
+
+	raw, err := bson.Marshal(recipeFromRecipe(child))
+	require.NoError(t, err)
+	doc, err := decodeRecipe(raw)
+	require.NoError(t, err)
+	got, err := doc.ToRecipe()
+	require.NoError(t, err)
+
+	assert.Equal(t, child, got)
+}
+
+func TestDecodeStepsWithoutIndex(t *testing.T) {
+	doc := recipeFromRecipe(newRecipe(t, "stir fry"))
+	doc.SchemaVersion = 4
+	doc.Steps = []step{{Action: "heat wok"}, {Action: "sear"}, {Action: "toss"}}
+	raw, err := bson.Marshal(doc)
+	require.NoError(t, err)
+
+	decoded, err := decodeRecipe(raw)
+	require.NoError(t, err)
+	got, err := decoded.ToRecipe()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"heat wok", "sear", "toss"}, stepActions(got))
+}
+
+func TestDecodeStepTemperatureWithoutUnit(t *testing.T) {
+	doc := recipeFromRecipe(newRecipe(t, "pancakes"))
+	doc.SchemaVersion = 7
+	doc.Steps = []step{{Action: "fry", Temperature: 180}, {Action: "serve"}}
+	raw, err := bson.Marshal(doc)
+	require.NoError(t, err)
+
+	decoded, err := decodeRecipe(raw)
+	require.NoError(t, err)
+	got, err := decoded.ToRecipe()
+	require.NoError(t, err)
+	assert.Equal(t, units.Temperature{Value: 180, Unit: units.Celsius}, got.Steps()[0].Temperature())
+	assert.True(t, got.Steps()[1].Temperature().IsZero())
+}
+
+func TestDecodeStepWaitingForLaterStep(t *testing.T) {
+	for _, after := range []int{1, 2} {
+		doc := recipeFromRecipe(newRecipe(t, "stir fry"))
+		doc.Steps = []step{{Action: "heat wok"}, {Action: "sear", After: []int{after}}}
+		raw, err := bson.Marshal(doc)
+		require.NoError(t, err)
+
+		decoded, err := decodeRecipe(raw)
+		require.NoError(t, err)
+		_, err = decoded.ToRecipe()
+		assert.ErrorIs(t, err, ErrStepOrder, "after %d", after)
+	}
+}
+
+func TestDecodeStepTrustsStoredMethod(t *testing.T) {
+	doc := recipeFromRecipe(newRecipe(t, "steak"))
+	// plausible once, or written before the check was added
+	doc.Steps = []step{{Action: "sear", Temperature: 450, Unit: int(units.Celsius), Method: int(domain.SousVide)}}
+	raw, err := bson.Marshal(doc)
+	require.NoError(t, err)
+
+	decoded, err := decodeRecipe(raw)
+	require.NoError(t, err)
+	got, err := decoded.ToRecipe()
+	require.NoError(t, err)
+	assert.EqualValues(t, domain.SousVide, got.Steps()[0].Method())
+	assert.Equal(t, units.Temperature{Value: 450, Unit: units.Celsius}, got.Steps()[0].Temperature())
+}
@@ -0,0 +1,181 @@
+package recipe
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+)
+
+// TimeBucket groups recipes by how long they take in total.
+type TimeBucket int
+
+const (
+	// UnknownTime is for recipes without prep or cook times. They are
+	// not counted in facets.
+	UnknownTime TimeBucket = iota
+	Under15Minutes
+	Under30Minutes
+	Under1Hour
+	Under2Hours
+	Over2Hours
+)
+
+// timeBucketBounds are the exclusive upper bounds of every bucket but
+// the last, in order.
+var timeBucketBounds = []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour}
+
+func BucketOf(total time.Duration) TimeBucket {
+	if total <= 0 {
+		return UnknownTime
+	}
+	for i, bound := range timeBucketBounds {
+		if total < bound {
+			return TimeBucket(i + 1)
+		}
+	}
+	return Over2Hours
+}
+
+// bounds returns the total times in the bucket, from inclusive and to
+// exclusive. to is zero for the open ended last bucket.
+func (b TimeBucket) bounds() (from, to time.Duration) {
+	if b > Under15Minutes {
+		from = timeBucketBounds[b-2]
+	} else {
+		// zero means unknown, so the first bucket starts above it
+		from = time.Second
+	}
+	if b < Over2Hours {
+		to = timeBucketBounds[b-1]
+	}
+	return from, to
+}
+
+// Facets counts the recipes matching a query by each of the values
+// they can be filtered on. A recipe with several ingredients of a type
+// counts once for it. Values no recipe has are left out.
+type Facets struct {
+	Cuisines        map[domain.CuisineType]int
+	IngredientTypes map[domain.IngredientType]int
+	TotalTimes      map[TimeBucket]int
+	DietaryTags     map[domain.DietaryTag]int
+	// typeSets counts the recipes by the set of ingredient types they
+	// have, keyed by typeSetKey, which is what RollUp needs to count a
+	// recipe once for a type it has several narrower ones of
+	typeSets map[string]int
+}
+
+func newFacets() Facets {
+	return Facets{
+		Cuisines:        make(map[domain.CuisineType]int),
+		IngredientTypes: make(map[domain.IngredientType]int),
+		TotalTimes:      make(map[TimeBucket]int),
+		DietaryTags:     make(map[domain.DietaryTag]int),
+		typeSets:        make(map[string]int),
+	}
+}
+
+func (f Facets) add(r Recipe) {
+	f.Cuisines[r.Cuisine()]++
+	var types []domain.IngredientType
+	for _, l := range r.ingredients {
+		if t := l.Ingredient().Type; !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	f.addTypes(types, 1)
+	if b := BucketOf(r.TotalTime()); b != UnknownTime {
+		f.TotalTimes[b]++
+	}
+	for _, t := range r.dietaryTags {
+		f.DietaryTags[t]++
+	}
+}
+
+// addTypes counts n recipes having exactly the given ingredient types,
+// each of them once.
+func (f Facets) addTypes(types []domain.IngredientType, n int) {
+	if len(types) == 0 {
+		return
+	}
+	for _, t := range types {
+		f.IngredientTypes[t] += n
+	}
+	f.typeSets[typeSetKey(types)] += n
+}
+
+// typeSetKey is the sorted ids of types joined by commas.
+func typeSetKey(types []domain.IngredientType) string {
+	sorted := slices.Clone(types)
+	slices.Sort(sorted)
+	ids := make([]string, 0, len(sorted))
+	for _, t := range sorted {
+		ids = append(ids, strconv.Itoa(int(t)))
+	}
+	return strings.Join(ids, ",")
+}
+
+// parseTypeSet reads the ingredient types back from a typeSetKey.
+func parseTypeSet(key string) ([]domain.IngredientType, error) {
+	var types []domain.IngredientType
+	for _, id := range strings.Split(key, ",") {
+		t, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, domain.IngredientType(t))
+	}
+	return types, nil
+}
+
+// RollUp counts every recipe for the cuisines and ingredient types
+// above its own as well, given functions returning an id followed by
+// every id above it. A recipe counts once for a type however many
+// narrower types it has, so a count is what filtering on the value
+// finds. Ids the functions do not know are counted as they are. A set
+// of ingredient types that cannot be read back fails the roll up
+// rather than going uncounted.
+func (f Facets) RollUp(cuisines func(domain.CuisineType) []domain.CuisineType, types func(domain.IngredientType) []domain.IngredientType) (Facets, error) {
+	rolled := Facets{
+		Cuisines:        make(map[domain.CuisineType]int, len(f.Cuisines)),
+		IngredientTypes: make(map[domain.IngredientType]int, len(f.IngredientTypes)),
+		TotalTimes:      f.TotalTimes,
+		DietaryTags:     f.DietaryTags,
+		typeSets:        f.typeSets,
+	}
+	for c, n := range f.Cuisines {
+		up := cuisines(c)
+		if len(up) == 0 {
+			up = []domain.CuisineType{c}
+		}
+		for _, a := range up {
+			rolled.Cuisines[a] += n
+		}
+	}
+	for key, n := range f.typeSets {
+		set, err := parseTypeSet(key)
+		if err != nil {
+			return Facets{}, fmt.Errorf("reading ingredient type set %q: %w", key, err)
+		}
+		var up []domain.IngredientType
+		for _, t := range set {
+			ancestors := types(t)
+			if len(ancestors) == 0 {
+				ancestors = []domain.IngredientType{t}
+			}
+			for _, a := range ancestors {
+				if !slices.Contains(up, a) {
+					up = append(up, a)
+				}
+			}
+		}
+		for _, a := range up {
+			rolled.IngredientTypes[a] += n
+		}
+	}
+	return rolled, nil
+}
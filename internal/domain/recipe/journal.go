@@ -0,0 +1,245 @@
+package recipe
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	journalFile  = "journal.bson"
+	snapshotFile = "snapshot.bson"
+)
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// journalEntry is one change to the repository. Entries are written
+// back to back as bson documents, which carry their own length.
+type journalEntry struct {
+	Op     string    `bson:"op"`
+	ID     uuid.UUID `bson:"id"`
+	Recipe bson.Raw  `bson:"recipe,omitempty"`
+}
+
+// journal persists a MemoryRepository as a snapshot of every recipe
+// plus an append-only log of the changes made since. Both entries are
+// idempotent, so replaying a journal over a snapshot that already
+// contains some of its changes is harmless.
+type journal struct {
+	dir  string
+	file *os.File
+}
+
+// openJournal replays the snapshot and journal in dir, creating them
+// if needed, and returns the recovered recipes.
+func openJournal(dir string) (*journal, map[uuid.UUID]Recipe, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+
+	recipes := make(map[uuid.UUID]Recipe)
+	if err := readDocuments(filepath.Join(dir, snapshotFile), func(raw bson.Raw) error {
+		doc, err := decodeRecipe(raw)
+		if err != nil {
+			return err
+		}
+		r, err := doc.ToRecipe()
+		if err != nil {
+			return err
+		}
+		recipes[r.ID()] = r
+		return nil
+	}); err != nil {
+		return nil, nil, fmt.Errorf("reading snapshot failed: %w", err)
+	}
+
+	path := filepath.Join(dir, journalFile)
+	var valid int64
+	if err := readDocuments(path, func(raw bson.Raw) error {
+		var entry journalEntry
+		if err := bson.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+		switch entry.Op {
+		case opPut:
+			doc, err := decodeRecipe(entry.Recipe)
+			if err != nil {
+				return err
+			}
+			r, err := doc.ToRecipe()
+			if err != nil {
+				return err
+			}
+			recipes[r.ID()] = r
+		case opDelete:
+			delete(recipes, entry.ID)
+		default:
+			return fmt.Errorf("unknown journal operation %q", entry.Op)
+		}
+		valid += int64(len(raw))
+		return nil
+	}); err != nil {
+		return nil, nil, fmt.Errorf("replaying journal failed: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	// drop a record torn by a crash mid-write so new entries follow
+	// the last complete one
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return &journal{dir: dir, file: file}, recipes, nil
+}
+
+// readDocuments calls fn for every complete bson document in the file.
+// A missing file holds no documents. A record torn by a crash
+// mid-write, whether cut short or left as zeros, is dropped with a
+// warning, but only at the end of the file: a damaged record with more
+// data after it fails the read rather than losing what follows.
+func readDocuments(path string, fn func(bson.Raw) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	r := bufio.NewReader(f)
+	for offset := int64(0); offset < size; {
+		torn := func(reason string) error {
+			slog.Warn("dropping torn record at the end of the file", "path", path, "offset", offset, "bytes", size-offset, "reason", reason)
+			return nil
+		}
+		if size-offset < 4 {
+			return torn("record header is cut short")
+		}
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return err
+		}
+		length := int64(binary.LittleEndian.Uint32(header[:]))
+		if length < 5 {
+			rest, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			if length == 0 && !slices.ContainsFunc(rest, func(b byte) bool { return b != 0 }) {
+				return torn("record is zeroed")
+			}
+			return fmt.Errorf("corrupt record at offset %d of %s: length %d", offset, path, length)
+		}
+		if offset+length > size {
+			return torn("record is cut short")
+		}
+		raw := make([]byte, length)
+		copy(raw, header[:])
+		if _, err := io.ReadFull(r, raw[4:]); err != nil {
+			return err
+		}
+		if err := fn(raw); err != nil {
+			return err
+		}
+		offset += length
+	}
+	return nil
+}
+
+func (j *journal) append(entry journalEntry) error {
+	raw, err := bson.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(raw); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+func (j *journal) put(r Recipe) error {
+	raw, err := bson.Marshal(recipeFromRecipe(r))
+	if err != nil {
+		return err
+	}
+	return j.append(journalEntry{Op: opPut, ID: r.ID(), Recipe: raw})
+}
+
+func (j *journal) delete(id uuid.UUID) error {
+	return j.append(journalEntry{Op: opDelete, ID: id})
+}
+
+// compact writes every recipe to a fresh snapshot and empties the
+// journal. The snapshot is swapped in with a rename so a crash leaves
+// either the old or the new one in place.
+func (j *journal) compact(recipes map[uuid.UUID]Recipe) error {
+	tmp := filepath.Join(j.dir, snapshotFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range recipes {
+		raw, err := bson.Marshal(recipeFromRecipe(r))
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := w.Write(raw); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(j.dir, snapshotFile)); err != nil {
+		return err
+	}
+
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+func (j *journal) close() error {
+	return j.file.Close()
+}
@@ -2,20 +2,164 @@ package recipe
 
 import (
 	"context"
+	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/bento01dev/cookbook/internal/search"
 	"github.com/google/uuid"
 )
 
 type MemoryRepository struct {
 	recipes map[uuid.UUID]Recipe
-	mu      sync.Mutex
+	// index holds every recipe that is not deleted
+	index *search.Index
+	// users holds the ids of the recipes with a line for each
+	// ingredient, deleted ones included
+	users map[uuid.UUID]map[uuid.UUID]bool
+	mu    sync.Mutex
+	// journal is nil unless the repository is durable
+	journal *journal
+	done    chan struct{}
+	wg      sync.WaitGroup
+	closed  sync.Once
+	// closeErr is what the first Close returned
+	closeErr error
 }
 
 func NewMemoryRepository() *MemoryRepository {
 	return &MemoryRepository{
 		recipes: make(map[uuid.UUID]Recipe),
+		index:   search.NewIndex(),
+		users:   make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+}
+
+// NewDurableMemoryRepository returns a memory repository that survives
+// restarts. Every change is appended to a journal in dir before it is
+// applied, the journal is compacted into a snapshot every compactEvery
+// and both are replayed when the repository is opened again. A zero
+// or negative compactEvery only compacts on Close. Close must be
+// called to stop compaction and release the journal.
+func NewDurableMemoryRepository(dir string, compactEvery time.Duration) (*MemoryRepository, error) {
+	j, recipes, err := openJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+	mr := &MemoryRepository{
+		recipes: recipes,
+		index:   search.NewIndex(),
+		users:   make(map[uuid.UUID]map[uuid.UUID]bool),
+		journal: j,
+		done:    make(chan struct{}),
+	}
+	for _, r := range recipes {
+		mr.reindex(r)
+		mr.addUser(r)
+	}
+
+	if compactEvery <= 0 {
+		return mr, nil
+	}
+	mr.wg.Add(1)
+	go func() {
+		defer mr.wg.Done()
+		ticker := time.NewTicker(compactEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-mr.done:
+				return
+			case <-ticker.C:
+				if err := mr.Compact(); err != nil {
+					slog.Error("compacting recipe journal failed", "err", err.Error())
+				}
+			}
+		}
+	}()
+	return mr, nil
+}
+
+// Compact folds the journal into a fresh snapshot. It is a no-op for a
+// volatile repository.
+func (mr *MemoryRepository) Compact() error {
+	if mr.journal == nil {
+		return nil
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	return mr.journal.compact(mr.recipes)
+}
+
+// Close compacts and closes the journal of a durable repository.
+// Closing it again returns the result of the first Close.
+func (mr *MemoryRepository) Close() error {
+	if mr.journal == nil {
+		return nil
+	}
+	mr.closed.Do(func() {
+		close(mr.done)
+		mr.wg.Wait()
+		if mr.closeErr = mr.Compact(); mr.closeErr != nil {
+			return
+		}
+		mr.closeErr = mr.journal.close()
+	})
+	return mr.closeErr
+}
+
+func (mr *MemoryRepository) put(recipe Recipe) error {
+	if mr.journal != nil {
+		if err := mr.journal.put(recipe); err != nil {
+			return err
+		}
+	}
+	if old, ok := mr.recipes[recipe.ID()]; ok {
+		mr.removeUser(old)
+	}
+	mr.recipes[recipe.ID()] = recipe
+	mr.reindex(recipe)
+	mr.addUser(recipe)
+	return nil
+}
+
+func (mr *MemoryRepository) reindex(recipe Recipe) {
+	if recipe.Deleted() {
+		mr.index.Remove(recipe.ID().String())
+		return
+	}
+	mr.index.Put(searchDocument(recipe))
+}
+
+func (mr *MemoryRepository) remove(id uuid.UUID) error {
+	if mr.journal != nil {
+		if err := mr.journal.delete(id); err != nil {
+			return err
+		}
+	}
+	if old, ok := mr.recipes[id]; ok {
+		mr.removeUser(old)
+	}
+	delete(mr.recipes, id)
+	mr.index.Remove(id.String())
+	return nil
+}
+
+func (mr *MemoryRepository) addUser(recipe Recipe) {
+	for _, id := range recipe.ingredientIDs() {
+		if mr.users[id] == nil {
+			mr.users[id] = make(map[uuid.UUID]bool)
+		}
+		mr.users[id][recipe.ID()] = true
+	}
+}
+
+func (mr *MemoryRepository) removeUser(recipe Recipe) {
+	for _, id := range recipe.ingredientIDs() {
+		delete(mr.users[id], recipe.ID())
+		if len(mr.users[id]) == 0 {
+			delete(mr.users, id)
+		}
 	}
 }
 
@@ -34,8 +178,10 @@ func (mr *MemoryRepository) Get(ctx context.Context, id uuid.UUID) (Recipe, erro
 }
 
 // this really isnt needed. just for fun
+// the channels are buffered so the goroutine can always hand over its
+// result and exit, even once the caller has given up waiting.
 func (mr *MemoryRepository) get(id uuid.UUID) <-chan wrapper {
-	ch := make(chan wrapper)
+	ch := make(chan wrapper, 1)
 	go func() {
 		time.Sleep(200 * time.Millisecond)
 		mr.mu.Lock()
@@ -53,37 +199,163 @@ func (mr *MemoryRepository) Add(ctx context.Context, recipe Recipe) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case res := <-mr.add(recipe):
+	case res := <-mr.add(ctx, recipe):
 		return res
 	}
 }
 
-func (mr *MemoryRepository) add(recipe Recipe) <-chan error {
-	ch := make(chan error)
+func (mr *MemoryRepository) add(ctx context.Context, recipe Recipe) <-chan error {
+	ch := make(chan error, 1)
 	go func() {
 		time.Sleep(200 * time.Millisecond)
 		mr.mu.Lock()
 		defer mr.mu.Unlock()
-		mr.recipes[recipe.ID()] = recipe
-		ch <- nil
+		// the caller has already been told the add failed
+		if err := ctx.Err(); err != nil {
+			ch <- err
+			return
+		}
+		if _, ok := mr.recipes[recipe.ID()]; ok {
+			ch <- ErrRecipeExists
+			return
+		}
+		ch <- mr.put(recipe)
 	}()
 	return ch
 }
 
 func (mr *MemoryRepository) Update(ctx context.Context, recipe Recipe) (Recipe, error) {
-	if _, ok := mr.recipes[recipe.ID()]; ok {
-		return recipe, ErrRecipeExists
+	if err := ctx.Err(); err != nil {
+		return Recipe{}, err
 	}
 	mr.mu.Lock()
-	mr.recipes[recipe.ID()] = recipe
-	mr.mu.Unlock()
+	defer mr.mu.Unlock()
+	stored, ok := mr.recipes[recipe.ID()]
+	if !ok {
+		return Recipe{}, ErrRecipeNotFound
+	}
+	if stored.version != recipe.version {
+		return Recipe{}, ErrRecipeConflict
+	}
+	recipe.version++
+	if err := mr.put(recipe); err != nil {
+		return Recipe{}, err
+	}
 	return recipe, nil
 }
 
+// Purge hard deletes every recipe soft deleted before the given time
+// and reports how many were removed.
+func (mr *MemoryRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	var purged int
+	for id, recipe := range mr.recipes {
+		if recipe.Deleted() && recipe.DeletedAt().Before(before) {
+			if err := mr.remove(id); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
 func (mr *MemoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
 	if _, ok := mr.recipes[id]; !ok {
 		return ErrRecipeNotFound
 	}
-	delete(mr.recipes, id)
-	return nil
+	return mr.remove(id)
+}
+
+func (mr *MemoryRepository) List(ctx context.Context, q Query) (Page, error) {
+	if err := ctx.Err(); err != nil {
+		return Page{}, err
+	}
+	q, err := q.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+	mr.mu.Lock()
+	candidates := mr.candidates(q)
+	mr.mu.Unlock()
+	return paginate(candidates, q)
+}
+
+// candidates returns the recipes q can match, looking up the ones using
+// its ingredients rather than going through every recipe.
+func (mr *MemoryRepository) candidates(q Query) []Recipe {
+	if len(q.Ingredients) == 0 {
+		candidates := make([]Recipe, 0, len(mr.recipes))
+		for _, r := range mr.recipes {
+			candidates = append(candidates, r)
+		}
+		return candidates
+	}
+	seen := make(map[uuid.UUID]bool)
+	var candidates []Recipe
+	for _, ingredient := range q.Ingredients {
+		for id := range mr.users[ingredient] {
+			if !seen[id] {
+				seen[id] = true
+				candidates = append(candidates, mr.recipes[id])
+			}
+		}
+	}
+	return candidates
+}
+
+func (mr *MemoryRepository) Search(ctx context.Context, q search.Query, limit int) ([]SearchHit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	var hits []SearchHit
+	for _, h := range mr.index.Search(q, limit) {
+		r := mr.recipes[uuid.MustParse(h.ID)]
+		hits = append(hits, searchHit(r, h.Score, q))
+	}
+	return hits, nil
+}
+
+// SearchFacets counts the facets of every recipe matching q.
+func (mr *MemoryRepository) SearchFacets(ctx context.Context, q search.Query) (Facets, error) {
+	if err := ctx.Err(); err != nil {
+		return Facets{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	f := newFacets()
+	for _, h := range mr.index.Search(q, 0) {
+		f.add(mr.recipes[uuid.MustParse(h.ID)])
+	}
+	return f, nil
+}
+
+func (mr *MemoryRepository) Facets(ctx context.Context, q Query) (Facets, error) {
+	if err := ctx.Err(); err != nil {
+		return Facets{}, err
+	}
+	q, err := q.Normalize()
+	if err != nil {
+		return Facets{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	f := newFacets()
+	for _, r := range mr.candidates(q) {
+		if q.matches(r) {
+			f.add(r)
+		}
+	}
+	return f, nil
 }
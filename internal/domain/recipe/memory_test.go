@@ -0,0 +1,134 @@
+package recipe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) repository {
+		return NewMemoryRepository()
+	})
+}
+
+func TestDurableMemoryRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) repository {
+		mr, err := NewDurableMemoryRepository(t.TempDir(), time.Hour)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			mr.Close()
+		})
+		return mr
+	})
+}
+
+func TestDurableMemoryRepositoryRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	mr, err := NewDurableMemoryRepository(dir, time.Hour)
+	require.NoError(t, err)
+	kept := fullRecipe(t)
+	updated := newRecipe(t, "pancakes")
+	deleted := newRecipe(t, "waffles")
+	require.NoError(t, mr.Add(ctx, kept))
+	require.NoError(t, mr.Add(ctx, updated))
+	require.NoError(t, mr.Add(ctx, deleted))
+
+	// everything up to here ends up in the snapshot, the rest only in
+	// the journal
+	require.NoError(t, mr.Compact())
+	name := "crepes"
+	require.NoError(t, updated.Apply(Patch{Name: &name}))
+	updated, err = mr.Update(ctx, updated)
+	require.NoError(t, err)
+	require.NoError(t, mr.Delete(ctx, deleted.ID()))
+
+	// stop without compacting, as a crash would
+	close(mr.done)
+	mr.wg.Wait()
+	require.NoError(t, mr.journal.close())
+
+	// a record torn by the crash is dropped on replay
+	f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x40, 0x00, 0x00, 0x00, 0x02})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	mr, err = NewDurableMemoryRepository(dir, time.Hour)
+	require.NoError(t, err)
+
+	got, err := mr.Get(ctx, kept.ID())
+	require.NoError(t, err)
+	assert.Equal(t, kept, got)
+	got, err = mr.Get(ctx, updated.ID())
+	require.NoError(t, err)
+	assert.Equal(t, updated, got)
+	_, err = mr.Get(ctx, deleted.ID())
+	assert.ErrorIs(t, err, ErrRecipeNotFound)
+
+	// writes after the torn record survive the next restart too
+	extra := newRecipe(t, "omelette")
+	require.NoError(t, mr.Add(ctx, extra))
+	require.NoError(t, mr.Close())
+
+	mr, err = NewDurableMemoryRepository(dir, time.Hour)
+	require.NoError(t, err)
+	defer mr.Close()
+	_, err = mr.Get(ctx, extra.ID())
+	assert.NoError(t, err)
+}
+
+func TestDurableMemoryRepositoryCloseTwice(t *testing.T) {
+	// compaction is off, so only Close writes the snapshot
+	mr, err := NewDurableMemoryRepository(t.TempDir(), 0)
+	require.NoError(t, err)
+	require.NoError(t, mr.Add(context.Background(), newRecipe(t, "pancakes")))
+
+	assert.NoError(t, mr.Close())
+	assert.NoError(t, mr.Close())
+}
+
+func TestDurableMemoryRepositoryDamagedJournal(t *testing.T) {
+	ctx := context.Background()
+	// journalWith writes a journal holding one recipe followed by
+	// damage, and returns its directory
+	journalWith := func(t *testing.T, damage func(record []byte) []byte) string {
+		dir := t.TempDir()
+		mr, err := NewDurableMemoryRepository(dir, 0)
+		require.NoError(t, err)
+		require.NoError(t, mr.Add(ctx, newRecipe(t, "pancakes")))
+		require.NoError(t, mr.journal.close())
+
+		path := filepath.Join(dir, journalFile)
+		record, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, append(record, damage(record)...), 0o644))
+		return dir
+	}
+
+	t.Run("a zeroed tail is dropped", func(t *testing.T) {
+		mr, err := NewDurableMemoryRepository(journalWith(t, func([]byte) []byte {
+			return make([]byte, 16)
+		}), 0)
+		require.NoError(t, err)
+		defer mr.Close()
+		page, err := mr.List(ctx, Query{})
+		require.NoError(t, err)
+		assert.Len(t, page.Recipes, 1)
+	})
+
+	t.Run("a damaged record with more after it fails", func(t *testing.T) {
+		_, err := NewDurableMemoryRepository(journalWith(t, func(record []byte) []byte {
+			return append([]byte{0x02, 0x00, 0x00, 0x00}, record...)
+		}), 0)
+		assert.Error(t, err)
+	})
+}
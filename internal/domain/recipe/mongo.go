@@ -3,12 +3,16 @@ package recipe
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/search"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type MongoRepository struct {
@@ -25,55 +29,520 @@ func NewMongoRepository(client *mongo.Client, databaseName, collectionName strin
 	}
 }
 
-type recipe struct {
-	ID          uuid.UUID      `bson:"id"`
-	Name        string         `bson:"name"`
-	Description string         `bson:"description"`
-	CreatedAt   bson.Timestamp `bson:"created_at"`
-}
-
-func (r recipe) ToRecipe() Recipe {
-	return Recipe{
-		item: &domain.Item{
-			ID:          r.ID,
-			Name:        r.Name,
-			Description: r.Description,
+// EnsureIndexes creates the indexes the repository relies on. It is
+// safe to call on every start as existing indexes are left alone.
+func (mr *MongoRepository) EnsureIndexes(ctx context.Context) error {
+	collection := mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{{
+		// without it two concurrent upserts in Add could both insert
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetName("recipe_id").SetUnique(true),
+	}, {
+		Keys:    bson.D{{Key: "ingredient_keys", Value: 1}},
+		Options: options.Index().SetName("recipe_ingredient_keys"),
+	}, {
+		Keys:    bson.D{{Key: "techniques", Value: 1}},
+		Options: options.Index().SetName("recipe_techniques"),
+	}, {
+		Keys:    bson.D{{Key: "pairings.with", Value: 1}},
+		Options: options.Index().SetName("recipe_pairings_with"),
+	}, {
+		Keys:    bson.D{{Key: "ingredients.ingredient_id", Value: 1}},
+		Options: options.Index().SetName("recipe_ingredient_ids"),
+	}, {
+		Keys:    bson.D{{Key: "overrides.line.ingredient_id", Value: 1}},
+		Options: options.Index().SetName("recipe_override_ingredient_ids"),
+	}, {
+		Keys: bson.D{
+			{Key: "name", Value: "text"},
+			{Key: "description", Value: "text"},
+			{Key: "ingredients.name", Value: "text"},
+			{Key: "prep_steps.action", Value: "text"},
+			{Key: "steps.action", Value: "text"},
 		},
-		createdAt: time.Unix(int64(r.CreatedAt.T), 0),
+		Options: options.Index().
+			SetName("recipe_search").
+			SetDefaultLanguage("english").
+			SetWeights(bson.D{
+				{Key: "name", Value: nameWeight},
+				{Key: "description", Value: descriptionWeight},
+				{Key: "ingredients.name", Value: ingredientsWeight},
+				{Key: "prep_steps.action", Value: stepsWeight},
+				{Key: "steps.action", Value: stepsWeight},
+			}),
+	}})
+	if err != nil {
+		return err
 	}
+	return mr.upgrade(ctx)
 }
 
-func recipeFromRecipe(r Recipe) recipe {
-	return recipe{
-		ID:          r.item.ID,
-		Name:        r.item.Name,
-		Description: r.item.Description,
-		CreatedAt:   bson.Timestamp{T: uint32(r.createdAt.Unix())},
+// upgrade rewrites the documents of older schema versions, so fields
+// derived on write and only queried on, such as the traits, are there
+// for every recipe. A document changed meanwhile is already upgraded.
+func (mr *MongoRepository) upgrade(ctx context.Context) error {
+	collection := mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+	cur, err := collection.Find(ctx, bson.M{"schema_version": bson.M{"$not": bson.M{"$gte": schemaVersion}}})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	var upgraded int
+	for cur.Next(ctx) {
+		doc, err := decodeRecipe(cur.Current)
+		if err != nil {
+			return err
+		}
+		r, err := doc.ToRecipe()
+		if err != nil {
+			return err
+		}
+		filter := bson.M{"id": r.ID(), "schema_version": bson.M{"$exists": false}}
+		if v, err := cur.Current.LookupErr("schema_version"); err == nil {
+			filter["schema_version"] = v
+		}
+		res, err := collection.ReplaceOne(ctx, filter, recipeFromRecipe(r))
+		if err != nil {
+			return err
+		}
+		upgraded += int(res.ModifiedCount)
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	if upgraded > 0 {
+		slog.InfoContext(ctx, "upgraded stored recipes", "recipes", upgraded, "schema_version", schemaVersion)
 	}
+	return nil
 }
 
 func (mr *MongoRepository) Get(ctx context.Context, id uuid.UUID) (Recipe, error) {
 	collection := mr.client.Database(mr.databaseName).Collection(mr.collectionName)
-	var result recipe
-	if err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&result); err != nil {
+	raw, err := collection.FindOne(ctx, bson.M{"id": id}).Raw()
+	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return Recipe{}, ErrRecipeNotFound
 		}
 		return Recipe{}, err
 	}
-	return result.ToRecipe(), nil
+	result, err := decodeRecipe(raw)
+	if err != nil {
+		return Recipe{}, err
+	}
+	return result.ToRecipe()
 }
 
+// Add inserts the recipe unless one with the same id already exists.
+// The upsert only writes when nothing matched, and the unique index
+// on id from EnsureIndexes makes the loser of two concurrent upserts
+// fail instead of inserting a duplicate.
 func (mr *MongoRepository) Add(ctx context.Context, recipe Recipe) error {
 	collection := mr.client.Database(mr.databaseName).Collection(mr.collectionName)
-	_, err := collection.InsertOne(ctx, recipeFromRecipe(recipe))
-	return err
+	res, err := collection.UpdateOne(
+		ctx,
+		bson.M{"id": recipe.ID()},
+		bson.M{"$setOnInsert": recipeFromRecipe(recipe)},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrRecipeExists
+		}
+		return err
+	}
+	if res.MatchedCount > 0 {
+		return ErrRecipeExists
+	}
+	return nil
 }
 
+// Update replaces the stored recipe only while it is still at the
+// version the recipe was read at. Documents from before versioning
+// have no version field and count as version 0.
 func (mr *MongoRepository) Update(ctx context.Context, recipe Recipe) (Recipe, error) {
-	return Recipe{}, nil
+	collection := mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+	filter := bson.M{"id": recipe.ID(), "version": recipe.version}
+	if recipe.version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	next := recipe
+	next.version++
+	res, err := collection.ReplaceOne(ctx, filter, recipeFromRecipe(next))
+	if err != nil {
+		return Recipe{}, err
+	}
+	if res.MatchedCount == 0 {
+		n, err := collection.CountDocuments(ctx, bson.M{"id": recipe.ID()})
+		if err != nil {
+			return Recipe{}, err
+		}
+		if n > 0 {
+			return Recipe{}, ErrRecipeConflict
+		}
+		return Recipe{}, ErrRecipeNotFound
+	}
+	return next, nil
 }
 
 func (mr *MongoRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	collection := mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+	res, err := collection.DeleteOne(ctx, bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrRecipeNotFound
+	}
 	return nil
 }
+
+func (mr *MongoRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	collection := mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+	res, err := collection.DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+// sortKey is the document field a listing is ordered by.
+func sortKey(s SortField) string {
+	switch s {
+	case SortByName:
+		return "name"
+	case SortByUpdatedAt:
+		return "modified_at"
+	default:
+		return "created_at"
+	}
+}
+
+func timeRange(after, before time.Time) bson.M {
+	r := bson.M{}
+	if !after.IsZero() {
+		r["$gte"] = after
+	}
+	if !before.IsZero() {
+		r["$lt"] = before
+	}
+	return r
+}
+
+// listFilter selects the recipes matching the filters of q.
+func listFilter(q Query) bson.D {
+	filter := bson.D{}
+	if !q.IncludeDeleted {
+		filter = append(filter, bson.E{Key: "deleted_at", Value: bson.M{"$exists": false}})
+	}
+	if len(q.Cuisines) > 0 {
+		cuisines := make([]int, 0, len(q.Cuisines))
+		for _, c := range q.Cuisines {
+			cuisines = append(cuisines, int(c))
+		}
+		filter = append(filter, bson.E{Key: "cuisine", Value: bson.M{"$in": cuisines}})
+	}
+	if len(q.IngredientTypes) > 0 {
+		types := make([]int, 0, len(q.IngredientTypes))
+		for _, t := range q.IngredientTypes {
+			types = append(types, int(t))
+		}
+		filter = append(filter, bson.E{Key: "ingredients.type", Value: bson.M{"$in": types}})
+	}
+	if r := timeRange(q.CreatedAfter, q.CreatedBefore); len(r) > 0 {
+		filter = append(filter, bson.E{Key: "created_at", Value: r})
+	}
+	if r := timeRange(q.UpdatedAfter, q.UpdatedBefore); len(r) > 0 {
+		filter = append(filter, bson.E{Key: "modified_at", Value: r})
+	}
+	if len(q.DietaryTags) > 0 {
+		tags := make([]int, 0, len(q.DietaryTags))
+		for _, t := range q.DietaryTags {
+			tags = append(tags, int(t))
+		}
+		filter = append(filter, bson.E{Key: "dietary_tags", Value: bson.M{"$all": tags}})
+	}
+	// or collects the filters that match any of several alternatives
+	var or []bson.A
+	if len(q.TotalTimes) > 0 {
+		ranges := make(bson.A, 0, len(q.TotalTimes))
+		for _, b := range q.TotalTimes {
+			from, to := b.bounds()
+			r := bson.M{"$gte": int64(from / time.Second)}
+			if to > 0 {
+				r["$lt"] = int64(to / time.Second)
+			}
+			ranges = append(ranges, bson.M{"total_time": r})
+		}
+		or = append(or, ranges)
+	}
+	if !q.Sharing.Empty() {
+		var sharing bson.A
+		if len(q.Sharing.Ingredients) > 0 {
+			sharing = append(sharing, bson.M{"ingredient_keys": bson.M{"$in": q.Sharing.Ingredients}})
+		}
+		if len(q.Sharing.Techniques) > 0 {
+			sharing = append(sharing, bson.M{"techniques": bson.M{"$in": q.Sharing.Techniques}})
+		}
+		or = append(or, sharing)
+	}
+	if len(q.IDs) > 0 {
+		filter = append(filter, bson.E{Key: "id", Value: bson.M{"$in": q.IDs}})
+	}
+	if len(q.PairedWith) > 0 {
+		filter = append(filter, bson.E{Key: "pairings.with", Value: bson.M{"$in": q.PairedWith}})
+	}
+	if len(q.Ingredients) > 0 {
+		or = append(or, bson.A{
+			bson.M{"ingredients.ingredient_id": bson.M{"$in": q.Ingredients}},
+			bson.M{"overrides.line.ingredient_id": bson.M{"$in": q.Ingredients}},
+		})
+	}
+	// nested in an $and as the listing cursor takes the top level $or
+	if len(or) > 0 {
+		and := make(bson.A, 0, len(or))
+		for _, alternatives := range or {
+			and = append(and, bson.M{"$or": alternatives})
+		}
+		filter = append(filter, bson.E{Key: "$and", Value: and})
+	}
+	return filter
+}
+
+func (mr *MongoRepository) List(ctx context.Context, q Query) (Page, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+	after, hasCursor, err := decodeCursor(q)
+	if err != nil {
+		return Page{}, err
+	}
+
+	filter := listFilter(q)
+
+	key := sortKey(q.Sort)
+	direction, op := 1, "$gt"
+	if q.Descending {
+		direction, op = -1, "$lt"
+	}
+	if hasCursor {
+		var value any = time.UnixMilli(after.Time).UTC()
+		if q.Sort == SortByName {
+			value = after.Name
+		}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.M{key: bson.M{op: value}},
+			bson.M{key: value, "sort_id": bson.M{op: after.ID}},
+		}})
+	}
+
+	collection := mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+	opts := options.Find().
+		SetSort(bson.D{{Key: key, Value: direction}, {Key: "sort_id", Value: direction}}).
+		SetLimit(int64(q.Limit + 1))
+	cur, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return Page{}, err
+	}
+	defer cur.Close(ctx)
+
+	var recipes []Recipe
+	for cur.Next(ctx) {
+		doc, err := decodeRecipe(cur.Current)
+		if err != nil {
+			return Page{}, err
+		}
+		r, err := doc.ToRecipe()
+		if err != nil {
+			return Page{}, err
+		}
+		recipes = append(recipes, r)
+	}
+	if err := cur.Err(); err != nil {
+		return Page{}, err
+	}
+	return pageOf(recipes, q), nil
+}
+
+type facetCount struct {
+	Value int64 `bson:"_id"`
+	Count int   `bson:"count"`
+}
+
+type typeSetCount struct {
+	Types []int64 `bson:"_id"`
+	Count int     `bson:"count"`
+}
+
+// Facets counts in a single aggregation, one $facet pipeline per kind
+// of facet over the recipes matching q.
+func (mr *MongoRepository) Facets(ctx context.Context, q Query) (Facets, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return Facets{}, err
+	}
+	count := bson.D{{Key: "$sum", Value: 1}}
+	boundaries := bson.A{int64(1)}
+	for _, bound := range timeBucketBounds {
+		boundaries = append(boundaries, int64(bound/time.Second))
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: listFilter(q)}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "cuisines", Value: bson.A{
+				bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$cuisine"}, {Key: "count", Value: count}}}},
+			}},
+			// recipes are counted by the set of types they have, which
+			// the per type counts are added up from
+			{Key: "ingredient_types", Value: bson.A{
+				bson.D{{Key: "$project", Value: bson.D{{Key: "types", Value: bson.D{{Key: "$sortArray", Value: bson.D{
+					{Key: "input", Value: bson.D{{Key: "$setUnion", Value: bson.A{"$ingredients.type", bson.A{}}}}},
+					{Key: "sortBy", Value: 1},
+				}}}}}}},
+				bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$types"}, {Key: "count", Value: count}}}},
+			}},
+			{Key: "total_times", Value: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "total_time", Value: bson.D{{Key: "$gt", Value: 0}}}}}},
+				// every total time past the last boundary lands in the
+				// default bucket, which is named after that boundary
+				bson.D{{Key: "$bucket", Value: bson.D{
+					{Key: "groupBy", Value: "$total_time"},
+					{Key: "boundaries", Value: boundaries},
+					{Key: "default", Value: boundaries[len(boundaries)-1]},
+				}}},
+			}},
+			{Key: "dietary_tags", Value: bson.A{
+				bson.D{{Key: "$unwind", Value: "$dietary_tags"}},
+				bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$dietary_tags"}, {Key: "count", Value: count}}}},
+			}},
+		}}},
+	}
+
+	collection := mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+	cur, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return Facets{}, err
+	}
+	defer cur.Close(ctx)
+
+	var results []struct {
+		Cuisines        []facetCount   `bson:"cuisines"`
+		IngredientTypes []typeSetCount `bson:"ingredient_types"`
+		TotalTimes      []facetCount   `bson:"total_times"`
+		DietaryTags     []facetCount   `bson:"dietary_tags"`
+	}
+	if err := cur.All(ctx, &results); err != nil {
+		return Facets{}, err
+	}
+	f := newFacets()
+	for _, res := range results {
+		for _, c := range res.Cuisines {
+			f.Cuisines[domain.CuisineType(c.Value)] += c.Count
+		}
+		for _, c := range res.IngredientTypes {
+			types := make([]domain.IngredientType, 0, len(c.Types))
+			for _, t := range c.Types {
+				types = append(types, domain.IngredientType(t))
+			}
+			f.addTypes(types, c.Count)
+		}
+		for _, c := range res.TotalTimes {
+			f.TotalTimes[BucketOf(time.Duration(c.Value)*time.Second)] += c.Count
+		}
+		for _, c := range res.DietaryTags {
+			f.DietaryTags[domain.DietaryTag(c.Value)] += c.Count
+		}
+	}
+	return f, nil
+}
+
+// textSearch is q in the $text search syntax. Mongo only requires every
+// phrase to match and any one of the terms, so synonyms are given as
+// more terms and results are filtered again with search.Match.
+func textSearch(q search.Query) string {
+	parts := make([]string, 0, len(q.Terms)+len(q.Phrases))
+	for _, t := range q.Terms {
+		parts = append(parts, t.Word)
+	}
+	for _, group := range q.Synonyms {
+		for _, alt := range group {
+			for _, t := range alt {
+				parts = append(parts, t.Word)
+			}
+		}
+	}
+	for _, p := range q.Phrases {
+		words := make([]string, 0, len(p))
+		for _, t := range p {
+			words = append(words, t.Word)
+		}
+		parts = append(parts, `"`+strings.Join(words, " ")+`"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Search needs the text index from EnsureIndexes.
+func (mr *MongoRepository) Search(ctx context.Context, q search.Query, limit int) ([]SearchHit, error) {
+	var hits []SearchHit
+	err := mr.searchMatches(ctx, q, func(r Recipe, score float64) bool {
+		hits = append(hits, searchHit(r, score, q))
+		return len(hits) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// SearchFacets counts the facets of every recipe matching q. They are
+// counted here rather than with an aggregation as matches have to be
+// checked again with search.Match.
+func (mr *MongoRepository) SearchFacets(ctx context.Context, q search.Query) (Facets, error) {
+	f := newFacets()
+	err := mr.searchMatches(ctx, q, func(r Recipe, _ float64) bool {
+		f.add(r)
+		return true
+	})
+	if err != nil {
+		return Facets{}, err
+	}
+	return f, nil
+}
+
+// searchMatches calls fn with the recipes matching q, best first,
+// until it returns false.
+func (mr *MongoRepository) searchMatches(ctx context.Context, q search.Query, fn func(Recipe, float64) bool) error {
+	collection := mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+	score := bson.M{"$meta": "textScore"}
+	filter := bson.D{
+		{Key: "$text", Value: bson.M{"$search": textSearch(q)}},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "sort_id", Value: 1}})
+	cur, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		doc, err := decodeRecipe(cur.Current)
+		if err != nil {
+			return err
+		}
+		r, err := doc.ToRecipe()
+		if err != nil {
+			return err
+		}
+		if !search.Match(searchDocument(r), q) {
+			continue
+		}
+		if !fn(r, cur.Current.Lookup("score").Double()) {
+			break
+		}
+	}
+	return cur.Err()
+}
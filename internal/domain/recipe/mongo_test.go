@@ -0,0 +1,22 @@
+package recipe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bento01dev/cookbook/internal/db/dbtest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMongoRepository(t *testing.T) {
+	ctx := context.Background()
+	client := dbtest.MongoClient(t)
+
+	// every case gets its own collection so they cannot see each other
+	testRepository(t, func(t *testing.T) repository {
+		repo := NewMongoRepository(client, "cookbook_test", "recipe_"+uuid.NewString())
+		require.NoError(t, repo.EnsureIndexes(ctx))
+		return repo
+	})
+}
@@ -0,0 +1,82 @@
+package recipe
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+)
+
+// The helpers below never modify the slice they are given, so a recipe
+// copied before a change keeps its own steps.
+
+func insertAt[T any](s []T, pos int, v T) []T {
+	res := make([]T, 0, len(s)+1)
+	res = append(res, s[:pos]...)
+	res = append(res, v)
+	return append(res, s[pos:]...)
+}
+
+func removeAt[T any](s []T, pos int) []T {
+	res := make([]T, 0, len(s)-1)
+	res = append(res, s[:pos]...)
+	return append(res, s[pos+1:]...)
+}
+
+func moveTo[T any](s []T, from, to int) []T {
+	return insertAt(removeAt(s, from), to, s[from])
+}
+
+// renumberPrep sets the index of every prep step to its position.
+func renumberPrep(prepSteps []domain.Prep) []domain.Prep {
+	for i, p := range prepSteps {
+		prepSteps[i] = p.WithIndex(i)
+	}
+	return prepSteps
+}
+
+// renumberSteps sets the index of every cooking step to its position.
+func renumberSteps(steps []domain.Step) []domain.Step {
+	for i, s := range steps {
+		steps[i] = s.WithIndex(i)
+	}
+	return steps
+}
+
+// remapSteps renumbers steps that have been inserted, moved or removed,
+// pointing their dependencies at the new positions of the steps they
+// depend on. position maps an old position onto the new one.
+func remapSteps(steps []domain.Step, position func(old int) int) ([]domain.Step, error) {
+	for i, s := range steps {
+		after := make([]int, 0, len(s.After()))
+		for _, v := range s.After() {
+			if v = position(v); v >= i {
+				return nil, ErrStepOrder
+			}
+			after = append(after, v)
+		}
+		s, err := s.WithDependencies(after)
+		if err != nil {
+			return nil, err
+		}
+		steps[i] = s.WithIndex(i)
+	}
+	return steps, nil
+}
+
+// sortPrep orders stored prep steps by their index. The sort is stable
+// so steps stored without one keep the order they were stored in.
+func sortPrep(prepSteps []domain.Prep) []domain.Prep {
+	slices.SortStableFunc(prepSteps, func(a, b domain.Prep) int {
+		return cmp.Compare(a.Index(), b.Index())
+	})
+	return renumberPrep(prepSteps)
+}
+
+// sortSteps orders stored cooking steps by their index, like sortPrep.
+func sortSteps(steps []domain.Step) []domain.Step {
+	slices.SortStableFunc(steps, func(a, b domain.Step) int {
+		return cmp.Compare(a.Index(), b.Index())
+	})
+	return renumberSteps(steps)
+}
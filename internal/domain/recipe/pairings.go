@@ -0,0 +1,145 @@
+package recipe
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MaxPairingHops is how far from a recipe pairing suggestions are
+// looked for. Past a pairing of a pairing of a pairing the link to the
+// original dish is too loose to be worth suggesting.
+const MaxPairingHops = 3
+
+// PairingGraph treats the pairings of a set of recipes as an
+// undirected graph: if pancakes go with bacon, bacon goes with
+// pancakes. Pairings with recipes outside the set, such as deleted
+// ones, are ignored.
+type PairingGraph struct {
+	recipes map[uuid.UUID]Recipe
+	// edges holds the description of every pairing both ways round.
+	// When both recipes describe the pairing, each keeps its own.
+	edges map[uuid.UUID]map[uuid.UUID]string
+}
+
+func NewPairingGraph(recipes []Recipe) PairingGraph {
+	g := PairingGraph{
+		recipes: make(map[uuid.UUID]Recipe, len(recipes)),
+		edges:   make(map[uuid.UUID]map[uuid.UUID]string),
+	}
+	for _, r := range recipes {
+		if !r.Deleted() {
+			g.recipes[r.ID()] = r
+		}
+	}
+	link := func(from, to uuid.UUID, description string, own bool) {
+		if g.edges[from] == nil {
+			g.edges[from] = make(map[uuid.UUID]string)
+		}
+		if _, ok := g.edges[from][to]; !ok || own {
+			g.edges[from][to] = description
+		}
+	}
+	for _, r := range g.recipes {
+		for _, p := range r.pairings {
+			if _, ok := g.recipes[p.With()]; !ok {
+				continue
+			}
+			link(p.Base(), p.With(), p.Description(), true)
+			link(p.With(), p.Base(), p.Description(), false)
+		}
+	}
+	return g
+}
+
+// PairedRecipe is a recipe paired with another, directly or through
+// other pairings. Via lists the direct pairings of the other recipe
+// that lead here, and is empty for a direct pairing.
+type PairedRecipe struct {
+	Recipe      Recipe
+	Description string
+	Hops        int
+	Via         []Recipe
+}
+
+func byName(a, b Recipe) int {
+	if c := strings.Compare(a.Name(), b.Name()); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID().String(), b.ID().String())
+}
+
+// Pairings returns the recipes paired directly with id, by name.
+func (g PairingGraph) Pairings(id uuid.UUID) []PairedRecipe {
+	var res []PairedRecipe
+	for to, description := range g.edges[id] {
+		res = append(res, PairedRecipe{Recipe: g.recipes[to], Description: description, Hops: 1})
+	}
+	slices.SortFunc(res, func(a, b PairedRecipe) int {
+		return byName(a.Recipe, b.Recipe)
+	})
+	return res
+}
+
+// Suggestions returns up to limit recipes reachable from id through
+// more than one pairing. Closer recipes come first, then those reached
+// through more of id's own pairings.
+func (g PairingGraph) Suggestions(id uuid.UUID, limit int) []PairedRecipe {
+	hops := map[uuid.UUID]int{id: 0}
+	via := make(map[uuid.UUID]map[uuid.UUID]bool)
+	frontier := []uuid.UUID{id}
+	for depth := 1; depth <= MaxPairingHops && len(frontier) > 0; depth++ {
+		var next []uuid.UUID
+		for _, from := range frontier {
+			for to := range g.edges[from] {
+				if h, seen := hops[to]; seen && h < depth {
+					continue
+				}
+				if _, seen := hops[to]; !seen {
+					hops[to] = depth
+					next = append(next, to)
+				}
+				// carry over which direct pairings lead here, so a
+				// recipe reached several ways knows all of them
+				if via[to] == nil {
+					via[to] = make(map[uuid.UUID]bool)
+				}
+				if depth == 1 {
+					via[to][to] = true
+					continue
+				}
+				for v := range via[from] {
+					via[to][v] = true
+				}
+			}
+		}
+		frontier = next
+	}
+
+	var res []PairedRecipe
+	for to, h := range hops {
+		if h < 2 {
+			continue
+		}
+		s := PairedRecipe{Recipe: g.recipes[to], Hops: h}
+		for v := range via[to] {
+			s.Via = append(s.Via, g.recipes[v])
+		}
+		slices.SortFunc(s.Via, byName)
+		res = append(res, s)
+	}
+	slices.SortFunc(res, func(a, b PairedRecipe) int {
+		if a.Hops != b.Hops {
+			return a.Hops - b.Hops
+		}
+		if len(a.Via) != len(b.Via) {
+			return len(b.Via) - len(a.Via)
+		}
+		return byName(a.Recipe, b.Recipe)
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
@@ -0,0 +1,281 @@
+package recipe
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor for query")
+	ErrInvalidQuery  = errors.New("invalid recipe query")
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+type SortField int
+
+const (
+	SortByCreatedAt SortField = iota
+	SortByUpdatedAt
+	SortByName
+)
+
+// Query selects a page of recipes. Zero values mean "no filter";
+// deleted recipes are only listed when IncludeDeleted is set, for
+// callers that have to see tombstones too. Recipes that have never been
+// updated count as updated when they were created. A recipe has to
+// carry every one of DietaryTags but only match one of each of the
+// other lists.
+type Query struct {
+	Cuisines        []domain.CuisineType
+	IngredientTypes []domain.IngredientType
+	DietaryTags     []domain.DietaryTag
+	TotalTimes      []TimeBucket
+	CreatedAfter    time.Time
+	CreatedBefore   time.Time
+	UpdatedAfter    time.Time
+	UpdatedBefore   time.Time
+	Sort            SortField
+	Descending      bool
+	Limit           int
+	Cursor          string
+	// Facets asks for the page to come with facet counts.
+	Facets         bool
+	IncludeDeleted bool
+	// Sharing matches recipes with at least one of its ingredients or
+	// techniques.
+	Sharing Traits
+	IDs     []uuid.UUID
+	// PairedWith matches recipes with a pairing of their own with one
+	// of these recipes.
+	PairedWith []uuid.UUID
+	// Ingredients matches recipes with a line for one of these
+	// ingredients, counting the lines a variation swaps in.
+	Ingredients []uuid.UUID
+}
+
+type Page struct {
+	Recipes []Recipe
+	// NextCursor is empty on the last page.
+	NextCursor string
+	// Facets count every recipe matching the query, not just the
+	// page. Nil unless asked for.
+	Facets *Facets
+}
+
+// Normalize fills in defaults and rejects queries that cannot match.
+func (q Query) Normalize() (Query, error) {
+	if q.Limit == 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit < 0 || q.Limit > MaxPageSize {
+		return q, ErrInvalidQuery
+	}
+	if q.Sort < SortByCreatedAt || q.Sort > SortByName {
+		return q, ErrInvalidQuery
+	}
+	for _, b := range q.TotalTimes {
+		if b <= UnknownTime || b > Over2Hours {
+			return q, ErrInvalidQuery
+		}
+	}
+	if !q.CreatedAfter.IsZero() && !q.CreatedBefore.IsZero() && q.CreatedBefore.Before(q.CreatedAfter) {
+		return q, ErrInvalidQuery
+	}
+	if !q.UpdatedAfter.IsZero() && !q.UpdatedBefore.IsZero() && q.UpdatedBefore.Before(q.UpdatedAfter) {
+		return q, ErrInvalidQuery
+	}
+	return q, nil
+}
+
+// cursor is the position after the last recipe of a page: its sort key
+// and id, the id breaking ties between equal keys. The sort is kept
+// too so a cursor cannot be replayed against a different ordering.
+type cursor struct {
+	Sort       SortField `json:"s"`
+	Descending bool      `json:"d"`
+	Time       int64     `json:"t,omitempty"`
+	Name       string    `json:"n,omitempty"`
+	ID         string    `json:"i"`
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(q Query) (cursor, bool, error) {
+	if q.Cursor == "" {
+		return cursor{}, false, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return cursor{}, false, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return cursor{}, false, ErrInvalidCursor
+	}
+	if c.Sort != q.Sort || c.Descending != q.Descending {
+		return cursor{}, false, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(c.ID); err != nil {
+		return cursor{}, false, ErrInvalidCursor
+	}
+	return c, true, nil
+}
+
+func cursorAfter(r Recipe, q Query) cursor {
+	c := cursor{Sort: q.Sort, Descending: q.Descending, ID: r.ID().String()}
+	switch q.Sort {
+	case SortByName:
+		c.Name = r.Name()
+	case SortByUpdatedAt:
+		c.Time = r.modifiedAt().UnixMilli()
+	default:
+		c.Time = r.createdAt.UnixMilli()
+	}
+	return c
+}
+
+// modifiedAt is when the recipe last changed, counting creation.
+func (r Recipe) modifiedAt() time.Time {
+	if r.updatedAt.IsZero() {
+		return r.createdAt
+	}
+	return r.updatedAt
+}
+
+// compareCursors orders two positions in ascending order.
+func compareCursors(a, b cursor) int {
+	var c int
+	if a.Sort == SortByName {
+		c = strings.Compare(a.Name, b.Name)
+	} else {
+		c = compareInt(a.Time, b.Time)
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func inRange(t time.Time, after, before time.Time) bool {
+	if !after.IsZero() && t.Before(after) {
+		return false
+	}
+	if !before.IsZero() && !t.Before(before) {
+		return false
+	}
+	return true
+}
+
+// matches applies the filters of q to r. After is inclusive and
+// before exclusive, so consecutive ranges do not overlap.
+func (q Query) matches(r Recipe) bool {
+	if r.Deleted() && !q.IncludeDeleted {
+		return false
+	}
+	if len(q.Cuisines) > 0 && !slices.Contains(q.Cuisines, r.Cuisine()) {
+		return false
+	}
+	if len(q.IngredientTypes) > 0 && !slices.ContainsFunc(r.ingredients, func(l domain.IngredientLine) bool {
+		return slices.Contains(q.IngredientTypes, l.Ingredient().Type)
+	}) {
+		return false
+	}
+	for _, t := range q.DietaryTags {
+		if !slices.Contains(r.dietaryTags, t) {
+			return false
+		}
+	}
+	if len(q.TotalTimes) > 0 && !slices.Contains(q.TotalTimes, BucketOf(r.TotalTime())) {
+		return false
+	}
+	if !q.Sharing.Empty() && !q.Sharing.sharedBy(r) {
+		return false
+	}
+	if len(q.IDs) > 0 && !slices.Contains(q.IDs, r.ID()) {
+		return false
+	}
+	if len(q.PairedWith) > 0 && !slices.ContainsFunc(r.pairings, func(p domain.Pairing) bool {
+		return slices.Contains(q.PairedWith, p.With())
+	}) {
+		return false
+	}
+	if len(q.Ingredients) > 0 && !slices.ContainsFunc(q.Ingredients, r.hasIngredient) {
+		return false
+	}
+	return inRange(r.createdAt, q.CreatedAfter, q.CreatedBefore) &&
+		inRange(r.modifiedAt(), q.UpdatedAfter, q.UpdatedBefore)
+}
+
+// paginate sorts the recipes matching q and cuts out the requested
+// page. Repositories that cannot push the query down to their storage
+// use it over every candidate recipe.
+func paginate(candidates []Recipe, q Query) (Page, error) {
+	after, hasCursor, err := decodeCursor(q)
+	if err != nil {
+		return Page{}, err
+	}
+
+	var matched []Recipe
+	for _, r := range candidates {
+		if q.matches(r) {
+			matched = append(matched, r)
+		}
+	}
+	direction := 1
+	if q.Descending {
+		direction = -1
+	}
+	slices.SortFunc(matched, func(a, b Recipe) int {
+		return direction * compareCursors(cursorAfter(a, q), cursorAfter(b, q))
+	})
+
+	start := 0
+	if hasCursor {
+		start = len(matched)
+		for i, r := range matched {
+			if direction*compareCursors(cursorAfter(r, q), after) > 0 {
+				start = i
+				break
+			}
+		}
+	}
+	return pageOf(matched[start:], q), nil
+}
+
+// pageOf trims recipes, which may hold one more than the page size, to
+// a page and sets the cursor when there is more to come.
+func pageOf(recipes []Recipe, q Query) Page {
+	if len(recipes) <= q.Limit {
+		return Page{Recipes: recipes}
+	}
+	recipes = recipes[:q.Limit]
+	return Page{
+		Recipes:    recipes,
+		NextCursor: cursorAfter(recipes[len(recipes)-1], q).encode(),
+	}
+}
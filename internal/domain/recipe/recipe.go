@@ -2,9 +2,11 @@ package recipe
 
 import (
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/units"
 	"github.com/google/uuid"
 )
 
@@ -14,24 +16,56 @@ var (
 	ErrRecipeUpdateFailed = errors.New("recipe could not be updated")
 	ErrRecipeExists       = errors.New("recipe already exists for given id")
 	ErrInvalidID          = errors.New("invalid id format")
+	ErrLineNotFound       = errors.New("ingredient line not found at given position")
+	ErrStepNotFound       = errors.New("step not found at given position")
+	ErrStepInUse          = errors.New("step is still depended on by another step")
+	ErrStepOrder          = errors.New("step cannot come before a step it depends on")
+	ErrInvalidServings    = errors.New("invalid number of servings")
+	ErrServingsUnknown    = errors.New("recipe does not state how many it serves")
+	ErrUnknownIngredient  = errors.New("ingredient is not part of the recipe")
+	ErrPairingExists      = errors.New("recipe is already paired with given item")
+	ErrPairedNotFound     = errors.New("recipe to pair with not found")
+	ErrIngredientInUse    = errors.New("ingredient is still used by a prep step or step")
+	ErrRecipeNotDeleted   = errors.New("recipe is not deleted")
+	ErrInvalidDuration    = errors.New("invalid duration for recipe")
+	ErrInvalidDietaryTag  = errors.New("invalid dietary tag")
+	ErrRecipeConflict     = errors.New("recipe was changed by another request")
 )
 
 type Recipe struct {
 	item        *domain.Item
-	ingredients []*domain.Ingredient
+	ingredients []domain.IngredientLine
 	variations  []domain.Variation
 	prepSteps   []domain.Prep
 	steps       []domain.Step
 	pairings    []domain.Pairing
+	// parent is set for variations, which inherit from their parent
+	// with overrides
+	parent      uuid.UUID
+	overrides   []Override
+	servings    int
+	prepTime    time.Duration
+	cookTime    time.Duration
+	dietaryTags []domain.DietaryTag
 	createdAt   time.Time
 	updatedAt   time.Time
+	deletedAt   time.Time
+	// version counts the writes to the stored recipe. An update only
+	// succeeds against the version it was read at.
+	version int
 }
 
-func NewRecipe(name string, description string, cuisine domain.CuisineType) (Recipe, error) {
+// NewRecipe creates a recipe. servings may be zero when the yield is
+// not known, in which case the recipe cannot be scaled.
+func NewRecipe(name string, description string, cuisine domain.CuisineType, servings int) (Recipe, error) {
 	if name == "" {
 		return Recipe{}, ErrInvalidItemName
 	}
 
+	if servings < 0 {
+		return Recipe{}, ErrInvalidServings
+	}
+
 	item := &domain.Item{
 		ID:          uuid.New(),
 		Name:        name,
@@ -41,12 +75,15 @@ func NewRecipe(name string, description string, cuisine domain.CuisineType) (Rec
 
 	return Recipe{
 		item:        item,
-		ingredients: make([]*domain.Ingredient, 0),
+		ingredients: make([]domain.IngredientLine, 0),
 		variations:  make([]domain.Variation, 0),
 		prepSteps:   make([]domain.Prep, 0),
 		steps:       make([]domain.Step, 0),
 		pairings:    make([]domain.Pairing, 0),
-		createdAt:   time.Now().UTC(),
+		overrides:   make([]Override, 0),
+		servings:    servings,
+		dietaryTags: make([]domain.DietaryTag, 0),
+		createdAt:   now(),
 	}, nil
 }
 
@@ -66,10 +103,135 @@ func (r Recipe) Cuisine() domain.CuisineType {
 	return r.item.Cuisine
 }
 
-func (r Recipe) Ingredients() []*domain.Ingredient {
+func (r Recipe) Servings() int {
+	return r.servings
+}
+
+// PrepTime and CookTime are zero when not known.
+func (r Recipe) PrepTime() time.Duration {
+	return r.prepTime
+}
+
+func (r Recipe) CookTime() time.Duration {
+	return r.cookTime
+}
+
+func (r Recipe) TotalTime() time.Duration {
+	return r.prepTime + r.cookTime
+}
+
+func (r Recipe) DietaryTags() []domain.DietaryTag {
+	return r.dietaryTags
+}
+
+func (r Recipe) Ingredients() []domain.IngredientLine {
 	return r.ingredients
 }
 
+// Scale returns a copy of the recipe adjusted to feed the given number
+// of servings, with every ingredient quantity scaled proportionally.
+func (r Recipe) Scale(servings int) (Recipe, error) {
+	if servings <= 0 {
+		return r, ErrInvalidServings
+	}
+	if r.servings == 0 {
+		return r, ErrServingsUnknown
+	}
+	if servings == r.servings {
+		return r, nil
+	}
+	factor := float64(servings) / float64(r.servings)
+	lines := make([]domain.IngredientLine, 0, len(r.ingredients))
+	for _, l := range r.ingredients {
+		lines = append(lines, l.Scale(factor))
+	}
+	r.ingredients = lines
+	r.servings = servings
+	return r, nil
+}
+
+// InSystem returns a copy of the recipe with every ingredient quantity
+// and step temperature rendered in the given measurement system.
+func (r Recipe) InSystem(sys units.System) Recipe {
+	lines := make([]domain.IngredientLine, 0, len(r.ingredients))
+	for _, l := range r.ingredients {
+		lines = append(lines, l.InSystem(sys))
+	}
+	r.ingredients = lines
+	steps := make([]domain.Step, 0, len(r.steps))
+	for _, s := range r.steps {
+		steps = append(steps, s.InSystem(sys))
+	}
+	r.steps = steps
+	return r
+}
+
+// InTemperatureUnit returns a copy of the recipe with every step
+// temperature in the given unit.
+func (r Recipe) InTemperatureUnit(u units.TemperatureUnit) Recipe {
+	steps := make([]domain.Step, 0, len(r.steps))
+	for _, s := range r.steps {
+		steps = append(steps, s.InTemperatureUnit(u))
+	}
+	r.steps = steps
+	return r
+}
+
+// AddIngredient appends a line to the end of the ingredient list.
+func (r *Recipe) AddIngredient(line domain.IngredientLine) {
+	lines := make([]domain.IngredientLine, 0, len(r.ingredients)+1)
+	lines = append(lines, r.ingredients...)
+	r.ingredients = append(lines, line)
+	r.touch()
+}
+
+// RemoveIngredient drops the line at the given position.
+func (r *Recipe) RemoveIngredient(pos int) error {
+	if pos < 0 || pos >= len(r.ingredients) {
+		return ErrLineNotFound
+	}
+	id := r.ingredients[pos].Ingredient().ID
+	// the same catalogue ingredient can be listed more than once, and
+	// steps only need one of its lines to stay
+	var uses int
+	for _, l := range r.ingredients {
+		if l.Ingredient().ID == id {
+			uses++
+		}
+	}
+	for _, p := range r.prepSteps {
+		if uses == 1 && p.IngredientID() == id {
+			return ErrIngredientInUse
+		}
+	}
+	for _, s := range r.steps {
+		if uses == 1 && s.IngredientID() == id {
+			return ErrIngredientInUse
+		}
+	}
+	lines := make([]domain.IngredientLine, 0, len(r.ingredients)-1)
+	lines = append(lines, r.ingredients[:pos]...)
+	r.ingredients = append(lines, r.ingredients[pos+1:]...)
+	r.touch()
+	return nil
+}
+
+// MoveIngredient moves the line at position from to position to,
+// shifting the lines in between.
+func (r *Recipe) MoveIngredient(from, to int) error {
+	if from < 0 || from >= len(r.ingredients) || to < 0 || to >= len(r.ingredients) {
+		return ErrLineNotFound
+	}
+	line := r.ingredients[from]
+	lines := make([]domain.IngredientLine, 0, len(r.ingredients))
+	lines = append(lines, r.ingredients[:from]...)
+	lines = append(lines, r.ingredients[from+1:]...)
+	lines = append(lines[:to], append([]domain.IngredientLine{line}, lines[to:]...)...)
+	r.ingredients = lines
+	r.touch()
+	return nil
+}
+
 func (r Recipe) Variations() []string {
 	var res []string
 	for _, v := range r.variations {
@@ -86,6 +248,354 @@ func (r Recipe) Steps() []domain.Step {
 	return r.steps
 }
 
+func (r Recipe) Pairings() []domain.Pairing {
+	return r.pairings
+}
+
+// UsesIngredient reports whether one of the recipe's lines, or of the
+// lines its overrides swap in, is for the ingredient with the given id.
+func (r Recipe) UsesIngredient(id uuid.UUID) bool {
+	return r.hasIngredient(id)
+}
+
+// RefreshIngredient replaces the recipe's copies of an ingredient with
+// the given version of it, reporting whether anything changed.
+func (r *Recipe) RefreshIngredient(ingredient domain.Ingredient) bool {
+	refresh := func(l domain.IngredientLine) (domain.IngredientLine, bool) {
+		if l.Ingredient().ID != ingredient.ID || l.Ingredient() == ingredient {
+			return l, false
+		}
+		return l.WithIngredient(ingredient), true
+	}
+
+	var changed bool
+	lines := slices.Clone(r.ingredients)
+	for i, l := range lines {
+		if l, ok := refresh(l); ok {
+			lines[i], changed = l, true
+		}
+	}
+	overrides := slices.Clone(r.overrides)
+	for i, o := range overrides {
+		if o.line == nil {
+			continue
+		}
+		if l, ok := refresh(*o.line); ok {
+			overrides[i].line, changed = &l, true
+		}
+	}
+	if !changed {
+		return false
+	}
+	r.ingredients = lines
+	r.overrides = overrides
+	r.touch()
+	return true
+}
+
+// ingredientIDs returns the ingredients the recipe has a line for,
+// counting the lines its overrides swap in, each once.
+func (r Recipe) ingredientIDs() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, l := range r.ingredients {
+		if !slices.Contains(ids, l.Ingredient().ID) {
+			ids = append(ids, l.Ingredient().ID)
+		}
+	}
+	for _, o := range r.overrides {
+		if l, ok := o.Line(); ok && !slices.Contains(ids, l.Ingredient().ID) {
+			ids = append(ids, l.Ingredient().ID)
+		}
+	}
+	return ids
+}
+
+// hasIngredient reports whether the recipe has a line for the given
+// ingredient. For a variation that covers the lines it swaps in but
+// not the ones it inherits.
+func (r Recipe) hasIngredient(id uuid.UUID) bool {
+	for _, l := range r.ingredients {
+		if l.Ingredient().ID == id {
+			return true
+		}
+	}
+	for _, o := range r.overrides {
+		if l, ok := o.Line(); ok && l.Ingredient().ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// AddPrep appends a prep step for one of the recipe's ingredients.
+func (r *Recipe) AddPrep(p domain.Prep) error {
+	return r.InsertPrep(len(r.prepSteps), p)
+}
+
+// InsertPrep places a prep step at the given position, shifting the
+// ones from there on back. pos may be one past the last step.
+func (r *Recipe) InsertPrep(pos int, p domain.Prep) error {
+	if pos < 0 || pos > len(r.prepSteps) {
+		return ErrStepNotFound
+	}
+	if !r.hasIngredient(p.IngredientID()) {
+		return ErrUnknownIngredient
+	}
+	r.prepSteps = renumberPrep(insertAt(r.prepSteps, pos, p))
+	r.touch()
+	return nil
+}
+
+// MovePrep moves the prep step at position from to position to,
+// shifting the steps in between.
+func (r *Recipe) MovePrep(from, to int) error {
+	if from < 0 || from >= len(r.prepSteps) || to < 0 || to >= len(r.prepSteps) {
+		return ErrStepNotFound
+	}
+	r.prepSteps = renumberPrep(moveTo(r.prepSteps, from, to))
+	r.touch()
+	return nil
+}
+
+// RemovePrep drops the prep step at the given position.
+func (r *Recipe) RemovePrep(pos int) error {
+	if pos < 0 || pos >= len(r.prepSteps) {
+		return ErrStepNotFound
+	}
+	r.prepSteps = renumberPrep(removeAt(r.prepSteps, pos))
+	r.touch()
+	return nil
+}
+
+// AddStep appends a cooking step. Steps that name an ingredient must
+// name one of the recipe's ingredients.
+func (r *Recipe) AddStep(s domain.Step) error {
+	return r.InsertStep(len(r.steps), s)
+}
+
+// InsertStep places a cooking step at the given position, shifting the
+// ones from there on back. pos may be one past the last step. The step
+// may only depend on steps before it.
+func (r *Recipe) InsertStep(pos int, s domain.Step) error {
+	if pos < 0 || pos > len(r.steps) {
+		return ErrStepNotFound
+	}
+	if s.IngredientID() != uuid.Nil && !r.hasIngredient(s.IngredientID()) {
+		return ErrUnknownIngredient
+	}
+	for _, v := range s.After() {
+		if v >= pos {
+			return ErrStepOrder
+		}
+	}
+	steps, err := remapSteps(insertAt(r.steps, pos, s), func(old int) int {
+		if old >= pos {
+			return old + 1
+		}
+		return old
+	})
+	if err != nil {
+		return err
+	}
+	r.steps = steps
+	r.touch()
+	return nil
+}
+
+// MoveStep moves the cooking step at position from to position to,
+// shifting the steps in between. No step may end up before one it
+// depends on.
+func (r *Recipe) MoveStep(from, to int) error {
+	if from < 0 || from >= len(r.steps) || to < 0 || to >= len(r.steps) {
+		return ErrStepNotFound
+	}
+	moved := moveTo(r.steps, from, to)
+	positions := make([]int, len(moved))
+	for i, v := range moved {
+		positions[v.Index()] = i
+	}
+	steps, err := remapSteps(moved, func(old int) int {
+		return positions[old]
+	})
+	if err != nil {
+		return err
+	}
+	r.steps = steps
+	r.touch()
+	return nil
+}
+
+// RemoveStep drops the cooking step at the given position, unless
+// another step depends on it.
+func (r *Recipe) RemoveStep(pos int) error {
+	if pos < 0 || pos >= len(r.steps) {
+		return ErrStepNotFound
+	}
+	for _, s := range r.steps {
+		if slices.Contains(s.After(), pos) {
+			return ErrStepInUse
+		}
+	}
+	steps, err := remapSteps(removeAt(r.steps, pos), func(old int) int {
+		if old > pos {
+			return old - 1
+		}
+		return old
+	})
+	if err != nil {
+		return err
+	}
+	r.steps = steps
+	r.touch()
+	return nil
+}
+
+func (r *Recipe) AddVariation(v domain.Variation) {
+	variations := make([]domain.Variation, 0, len(r.variations)+1)
+	variations = append(variations, r.variations...)
+	r.variations = append(variations, v)
+	r.touch()
+}
+
+// AddPairing records that the recipe goes well with another item. The
+// pairing must be based on this recipe and each item is paired once.
+func (r *Recipe) AddPairing(p domain.Pairing) error {
+	if p.Base() != r.ID() {
+		return domain.ErrInvalidPairing
+	}
+	for _, v := range r.pairings {
+		if v.With() == p.With() {
+			return ErrPairingExists
+		}
+	}
+	pairings := make([]domain.Pairing, 0, len(r.pairings)+1)
+	pairings = append(pairings, r.pairings...)
+	r.pairings = append(pairings, p)
+	r.touch()
+	return nil
+}
+
 func (r Recipe) CreatedAt() string {
 	return r.createdAt.Format(time.RFC3339)
 }
+
+// UpdatedAt is empty for recipes that have not changed since creation.
+func (r Recipe) UpdatedAt() string {
+	if r.updatedAt.IsZero() {
+		return ""
+	}
+	return r.updatedAt.Format(time.RFC3339)
+}
+
+// Deleted reports whether the recipe has been soft deleted. Deleted
+// recipes are kept as tombstones until purged.
+func (r Recipe) Deleted() bool {
+	return !r.deletedAt.IsZero()
+}
+
+func (r Recipe) DeletedAt() time.Time {
+	return r.deletedAt
+}
+
+// Delete marks the recipe as deleted. Deleting a recipe twice is
+// reported as the recipe not being found.
+func (r *Recipe) Delete() error {
+	if r.Deleted() {
+		return ErrRecipeNotFound
+	}
+	r.deletedAt = now()
+	r.touch()
+	return nil
+}
+
+func (r *Recipe) Restore() error {
+	if !r.Deleted() {
+		return ErrRecipeNotDeleted
+	}
+	r.deletedAt = time.Time{}
+	r.touch()
+	return nil
+}
+
+// now is truncated to milliseconds, the precision of a bson datetime,
+// so recipes compare equal whichever repository they are read from.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func (r *Recipe) touch() {
+	r.updatedAt = now()
+}
+
+// Patch is a partial update of a recipe's top level fields. Nil fields
+// are left as they are.
+type Patch struct {
+	Name        *string
+	Description *string
+	Cuisine     *domain.CuisineType
+	Servings    *int
+	PrepTime    *time.Duration
+	CookTime    *time.Duration
+	DietaryTags *[]domain.DietaryTag
+}
+
+// Apply validates the whole patch before changing anything, so a
+// failed patch leaves the recipe untouched.
+func (r *Recipe) Apply(p Patch) error {
+	if p.Name != nil && *p.Name == "" {
+		return ErrInvalidItemName
+	}
+	if p.Servings != nil && *p.Servings < 0 {
+		return ErrInvalidServings
+	}
+	if p.PrepTime != nil && *p.PrepTime < 0 || p.CookTime != nil && *p.CookTime < 0 {
+		return ErrInvalidDuration
+	}
+	var tags []domain.DietaryTag
+	if p.DietaryTags != nil {
+		var err error
+		if tags, err = dietaryTags(*p.DietaryTags); err != nil {
+			return err
+		}
+	}
+
+	item := *r.item
+	if p.Name != nil {
+		item.Name = *p.Name
+	}
+	if p.Description != nil {
+		item.Description = *p.Description
+	}
+	if p.Cuisine != nil {
+		item.Cuisine = *p.Cuisine
+	}
+	if p.Servings != nil {
+		r.servings = *p.Servings
+	}
+	if p.PrepTime != nil {
+		r.prepTime = *p.PrepTime
+	}
+	if p.CookTime != nil {
+		r.cookTime = *p.CookTime
+	}
+	if p.DietaryTags != nil {
+		r.dietaryTags = tags
+	}
+	r.item = &item
+	r.touch()
+	return nil
+}
+
+// dietaryTags validates tags and returns them sorted without
+// duplicates, so a recipe's tags compare equal however they were set.
+func dietaryTags(tags []domain.DietaryTag) ([]domain.DietaryTag, error) {
+	res := make([]domain.DietaryTag, 0, len(tags))
+	for _, t := range tags {
+		if t <= domain.UnknownDiet || t > domain.Kosher {
+			return nil, ErrInvalidDietaryTag
+		}
+		res = append(res, t)
+	}
+	slices.Sort(res)
+	return slices.Compact(res), nil
+}
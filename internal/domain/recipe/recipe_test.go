@@ -0,0 +1,612 @@
+package recipe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/units"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLine(t *testing.T, name string, quantity float64, unit string) domain.IngredientLine {
+	t.Helper()
+	line, err := domain.NewIngredientLine(domain.Ingredient{ID: uuid.New(), Name: name}, quantity, unit, "")
+	require.NoError(t, err)
+	return line
+}
+
+func lineNames(r Recipe) []string {
+	var names []string
+	for _, l := range r.Ingredients() {
+		names = append(names, l.Ingredient().Name)
+	}
+	return names
+}
+
+func TestIngredientLines(t *testing.T) {
+	r, err := NewRecipe("bread", "plain loaf", domain.French, 0)
+	require.NoError(t, err)
+
+	r.AddIngredient(newLine(t, "flour", 500, "g"))
+	r.AddIngredient(newLine(t, "water", 350, "ml"))
+	r.AddIngredient(newLine(t, "salt", 10, "g"))
+	assert.Equal(t, []string{"flour", "water", "salt"}, lineNames(r))
+
+	require.NoError(t, r.MoveIngredient(2, 0))
+	assert.Equal(t, []string{"salt", "flour", "water"}, lineNames(r))
+
+	require.NoError(t, r.MoveIngredient(0, 2))
+	assert.Equal(t, []string{"flour", "water", "salt"}, lineNames(r))
+
+	require.NoError(t, r.RemoveIngredient(1))
+	assert.Equal(t, []string{"flour", "salt"}, lineNames(r))
+
+	assert.ErrorIs(t, r.RemoveIngredient(5), ErrLineNotFound)
+	assert.ErrorIs(t, r.MoveIngredient(0, 2), ErrLineNotFound)
+}
+
+func TestIngredientLinesDoNotAlias(t *testing.T) {
+	r, err := NewRecipe("bread", "plain loaf", domain.French, 0)
+	require.NoError(t, err)
+	r.AddIngredient(newLine(t, "flour", 500, "g"))
+	r.AddIngredient(newLine(t, "water", 350, "ml"))
+
+	stored := r
+	require.NoError(t, r.MoveIngredient(1, 0))
+	assert.Equal(t, []string{"flour", "water"}, lineNames(stored))
+}
+
+func TestRefreshIngredient(t *testing.T) {
+	r, err := NewRecipe("bread", "plain loaf", domain.French, 0)
+	require.NoError(t, err)
+	flour := newLine(t, "flour", 400, "g")
+	r.AddIngredient(flour)
+	r.AddIngredient(newLine(t, "water", 350, "ml"))
+	r.AddIngredient(flour)
+	p, err := domain.NewPrep(flour.Ingredient().ID, "sift")
+	require.NoError(t, err)
+	require.NoError(t, r.AddPrep(p))
+
+	stored := r
+	renamed := flour.Ingredient()
+	assert.False(t, r.RefreshIngredient(renamed))
+	renamed.Name = "bread flour"
+	assert.True(t, r.RefreshIngredient(renamed))
+	assert.Equal(t, []string{"bread flour", "water", "bread flour"}, lineNames(r))
+	assert.Equal(t, []string{"flour", "water", "flour"}, lineNames(stored))
+	assert.Equal(t, flour.Quantity(), r.Ingredients()[0].Quantity())
+	assert.True(t, r.UsesIngredient(renamed.ID))
+
+	// the prep still has the other line to refer to
+	require.NoError(t, r.RemoveIngredient(2))
+	assert.ErrorIs(t, r.RemoveIngredient(0), ErrIngredientInUse)
+}
+
+func TestNewIngredientLineValidation(t *testing.T) {
+	_, err := domain.NewIngredientLine(domain.Ingredient{Name: " "}, 1, "g", "")
+	assert.ErrorIs(t, err, domain.ErrInvalidIngredient)
+
+	_, err = domain.NewIngredientLine(domain.Ingredient{Name: "flour"}, -1, "g", "")
+	assert.ErrorIs(t, err, domain.ErrInvalidQuantity)
+}
+
+func TestScale(t *testing.T) {
+	r, err := NewRecipe("pancakes", "fluffy", domain.Western, 4)
+	require.NoError(t, err)
+	r.AddIngredient(newLine(t, "egg", 3, ""))
+	r.AddIngredient(newLine(t, "baking powder", 4, "tsp"))
+	r.AddIngredient(newLine(t, "sugar", 16, "tsp"))
+	r.AddIngredient(newLine(t, "flour", 300, "g"))
+
+	scaled, err := r.Scale(12)
+	require.NoError(t, err)
+	assert.Equal(t, 12, scaled.Servings())
+
+	var got []units.Quantity
+	for _, l := range scaled.Ingredients() {
+		got = append(got, l.Quantity())
+	}
+	assert.Equal(t, []units.Quantity{
+		{Amount: 9, Unit: units.Piece},
+		{Amount: 0.25, Unit: units.Cup},
+		{Amount: 1, Unit: units.Cup},
+		{Amount: 900, Unit: units.Gram},
+	}, got)
+
+	halved, err := r.Scale(1)
+	require.NoError(t, err)
+	assert.Equal(t, units.Quantity{Amount: 1, Unit: units.Piece}, halved.Ingredients()[0].Quantity())
+
+	// the original recipe is left untouched
+	assert.Equal(t, 3.0, r.Ingredients()[0].Quantity().Amount)
+
+	_, err = r.Scale(0)
+	assert.ErrorIs(t, err, ErrInvalidServings)
+
+	unknown, err := NewRecipe("stew", "", domain.Western, 0)
+	require.NoError(t, err)
+	_, err = unknown.Scale(2)
+	assert.ErrorIs(t, err, ErrServingsUnknown)
+}
+
+func TestAuthoring(t *testing.T) {
+	r, err := NewRecipe("omelette", "", domain.French, 1)
+	require.NoError(t, err)
+	egg := newLine(t, "egg", 3, "")
+	r.AddIngredient(egg)
+
+	p, err := domain.NewPrep(uuid.New(), "beat")
+	require.NoError(t, err)
+	assert.ErrorIs(t, r.AddPrep(p), ErrUnknownIngredient)
+
+	p, err = domain.NewPrep(egg.Ingredient().ID, "beat")
+	require.NoError(t, err)
+	require.NoError(t, r.AddPrep(p))
+
+	s, err := domain.NewStep(uuid.Nil, "heat pan", units.Temperature{})
+	require.NoError(t, err)
+	require.NoError(t, r.AddStep(s))
+	assert.ErrorIs(t, r.RemoveIngredient(0), ErrIngredientInUse)
+
+	_, err = domain.NewPairing(r.ID(), r.ID(), "")
+	assert.ErrorIs(t, err, domain.ErrInvalidPairing)
+
+	pairing, err := domain.NewPairing(r.ID(), uuid.New(), "toast")
+	require.NoError(t, err)
+	require.NoError(t, r.AddPairing(pairing))
+	assert.ErrorIs(t, r.AddPairing(pairing), ErrPairingExists)
+}
+
+func TestApplyPatch(t *testing.T) {
+	r, err := NewRecipe("pancakes", "fluffy", domain.Western, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "", r.UpdatedAt())
+	stored := r
+
+	name, description, servings := "crepes", "", 2
+	cuisine := domain.CuisineType(domain.French)
+	require.NoError(t, r.Apply(Patch{Name: &name, Description: &description, Cuisine: &cuisine, Servings: &servings}))
+	assert.Equal(t, "crepes", r.Name())
+	assert.Equal(t, "", r.Description())
+	assert.Equal(t, cuisine, r.Cuisine())
+	assert.Equal(t, 2, r.Servings())
+	assert.NotEqual(t, "", r.UpdatedAt())
+	assert.Equal(t, "pancakes", stored.Name())
+
+	empty := ""
+	assert.ErrorIs(t, r.Apply(Patch{Name: &empty, Servings: &servings}), ErrInvalidItemName)
+	assert.Equal(t, "crepes", r.Name())
+
+	prep, cook := 5*time.Minute, 15*time.Minute
+	tags := []domain.DietaryTag{domain.Vegan, domain.Vegetarian, domain.Vegan}
+	require.NoError(t, r.Apply(Patch{PrepTime: &prep, CookTime: &cook, DietaryTags: &tags}))
+	assert.Equal(t, 20*time.Minute, r.TotalTime())
+	assert.Equal(t, []domain.DietaryTag{domain.Vegetarian, domain.Vegan}, r.DietaryTags())
+
+	negative := -time.Minute
+	assert.ErrorIs(t, r.Apply(Patch{CookTime: &negative}), ErrInvalidDuration)
+	assert.ErrorIs(t, r.Apply(Patch{DietaryTags: &[]domain.DietaryTag{domain.UnknownDiet}}), ErrInvalidDietaryTag)
+	assert.Equal(t, 15*time.Minute, r.CookTime())
+}
+
+func TestDeleteRestore(t *testing.T) {
+	r, err := NewRecipe("pancakes", "fluffy", domain.Western, 4)
+	require.NoError(t, err)
+	assert.ErrorIs(t, r.Restore(), ErrRecipeNotDeleted)
+
+	require.NoError(t, r.Delete())
+	assert.True(t, r.Deleted())
+	assert.ErrorIs(t, r.Delete(), ErrRecipeNotFound)
+
+	require.NoError(t, r.Restore())
+	assert.False(t, r.Deleted())
+}
+
+func TestSimilar(t *testing.T) {
+	recipe := func(name string, cuisine domain.CuisineType, ingredients []string, steps ...string) Recipe {
+		r, err := NewRecipe(name, "", cuisine, 2)
+		require.NoError(t, err)
+		for _, i := range ingredients {
+			r.AddIngredient(newLine(t, i, 1, ""))
+		}
+		for _, action := range steps {
+			s, err := domain.NewStep(uuid.Nil, action, units.Temperature{})
+			require.NoError(t, err)
+			require.NoError(t, r.AddStep(s))
+		}
+		return r
+	}
+
+	base := recipe("tomato soup", domain.Western, []string{"Tomatoes", "onion", "stock"}, "Roast the tomatoes", "Simmer with stock", "Blend")
+	assert.Equal(t, []string{"roast", "simmer", "blend"}, base.Techniques())
+
+	closest := recipe("tomato bisque", domain.Western, []string{"tomato", "onion", "cream"}, "Simmer everything", "Blend until smooth")
+	sameIngredients := recipe("salsa", domain.Spanish, []string{"tomato", "onion", "chilli"}, "Chop and mix")
+	sameCuisine := recipe("pancakes", domain.Western, []string{"flour", "egg"}, "Whisk", "Fry")
+	unrelated := recipe("stir fried greens", domain.Chinese, []string{"pak choi"}, "Stir-fry over high heat")
+	deleted := recipe("deleted tomato soup", domain.Western, []string{"tomato", "onion", "stock"})
+	require.NoError(t, deleted.Delete())
+	assert.Equal(t, []string{"stir-fry"}, unrelated.Techniques())
+
+	similar := Similar(base, []Recipe{unrelated, sameCuisine, base, deleted, sameIngredients, closest}, 0)
+	var names []string
+	for _, s := range similar {
+		names = append(names, s.Recipe.Name())
+	}
+	assert.Equal(t, []string{"tomato bisque", "salsa", "pancakes"}, names)
+	assert.Equal(t, []string{"Tomatoes", "onion"}, similar[0].SharedIngredients)
+	assert.Equal(t, []string{"blend", "simmer"}, similar[0].SharedTechniques)
+	assert.True(t, similar[0].SameCuisine)
+	assert.InDelta(t, 0.6*2.0/4+0.25+0.15*2.0/3, similar[0].Score, 1e-9)
+
+	assert.Len(t, Similar(base, []Recipe{closest, sameIngredients, sameCuisine}, 2), 2)
+
+	// common knife work alone makes nothing similar
+	diced := recipe("diced onion", domain.Chinese, []string{"shallot"}, "Dice the shallot")
+	salad := recipe("salad", domain.Spanish, []string{"lettuce"}, "Chop the lettuce", "Dice the cucumber")
+	assert.Equal(t, []string{"chop", "dice"}, salad.Techniques())
+	assert.Empty(t, salad.Traits().Techniques)
+	assert.Empty(t, Similar(diced, []Recipe{salad}, 0))
+
+	// recipes of no known cuisine do not share one
+	noCuisine := recipe("toast", domain.UnknownCuisine, []string{"bread"})
+	assert.Empty(t, Similar(noCuisine, []Recipe{recipe("jam", domain.UnknownCuisine, []string{"plums"})}, 0))
+}
+
+func TestPairingGraph(t *testing.T) {
+	recipes := map[string]*Recipe{}
+	for _, name := range []string{"pancakes", "bacon", "syrup", "eggs", "waffles", "hash", "toast", "deleted"} {
+		r := newRecipe(t, name)
+		recipes[name] = &r
+	}
+	pair := func(base, with, description string) {
+		p, err := domain.NewPairing(recipes[base].ID(), recipes[with].ID(), description)
+		require.NoError(t, err)
+		require.NoError(t, recipes[base].AddPairing(p))
+	}
+	pair("pancakes", "bacon", "salty and sweet")
+	pair("pancakes", "syrup", "")
+	pair("eggs", "bacon", "")
+	pair("waffles", "syrup", "")
+	pair("waffles", "bacon", "")
+	pair("hash", "eggs", "")
+	pair("toast", "hash", "")
+	pair("deleted", "pancakes", "")
+	require.NoError(t, recipes["deleted"].Delete())
+	missing, err := domain.NewPairing(recipes["pancakes"].ID(), uuid.New(), "")
+	require.NoError(t, err)
+	require.NoError(t, recipes["pancakes"].AddPairing(missing))
+
+	var all []Recipe
+	for _, r := range recipes {
+		all = append(all, *r)
+	}
+	g := NewPairingGraph(all)
+	names := func(paired []PairedRecipe) []string {
+		var names []string
+		for _, p := range paired {
+			names = append(names, p.Recipe.Name())
+		}
+		return names
+	}
+
+	direct := g.Pairings(recipes["pancakes"].ID())
+	assert.Equal(t, []string{"bacon", "syrup"}, names(direct))
+	assert.Equal(t, "salty and sweet", direct[0].Description)
+	// pairings go both ways
+	assert.Equal(t, []string{"eggs", "pancakes", "waffles"}, names(g.Pairings(recipes["bacon"].ID())))
+
+	suggested := g.Suggestions(recipes["pancakes"].ID(), 0)
+	assert.Equal(t, []string{"waffles", "eggs", "hash"}, names(suggested))
+	var via []string
+	for _, r := range suggested[0].Via {
+		via = append(via, r.Name())
+	}
+	assert.Equal(t, []string{"bacon", "syrup"}, via)
+	assert.Equal(t, 3, suggested[2].Hops)
+	assert.Len(t, g.Suggestions(recipes["pancakes"].ID(), 1), 1)
+}
+
+func TestVariation(t *testing.T) {
+	parent, err := NewRecipe("pancakes", "fluffy", domain.Western, 4)
+	require.NoError(t, err)
+	butter := newLine(t, "butter", 2, "tbsp")
+	milk := newLine(t, "milk", 1, "cup")
+	parent.AddIngredient(butter)
+	parent.AddIngredient(newLine(t, "flour", 2, "cup"))
+	parent.AddIngredient(milk)
+	p, err := domain.NewPrep(butter.Ingredient().ID, "melt")
+	require.NoError(t, err)
+	require.NoError(t, parent.AddPrep(p))
+	s, err := domain.NewStep(milk.Ingredient().ID, "whisk in", units.Temperature{})
+	require.NoError(t, err)
+	require.NoError(t, parent.AddStep(s))
+	s, err = domain.NewStep(butter.Ingredient().ID, "fry", units.Temperature{Value: 180, Unit: units.Celsius})
+	require.NoError(t, err)
+	require.NoError(t, parent.AddStep(s))
+
+	child, err := NewVariation(parent, "vegan pancakes", "swaps butter for oil")
+	require.NoError(t, err)
+	child.servings = 2
+	assert.Equal(t, parent.ID(), child.Parent())
+	assert.True(t, child.IsVariation())
+	assert.False(t, parent.IsVariation())
+
+	oil := newLine(t, "oil", 1, "tbsp")
+	require.NoError(t, child.Override(parent, Swap(butter.Ingredient().ID, oil)))
+	require.NoError(t, child.Override(parent, Omit(milk.Ingredient().ID)))
+	assert.ErrorIs(t, child.Override(parent, Omit(uuid.New())), ErrUnknownIngredient)
+	oatMilk := newLine(t, "oat milk", 0.5, "cup")
+	child.AddIngredient(oatMilk)
+	s, err = domain.NewStep(oil.Ingredient().ID, "grease pan", units.Temperature{})
+	require.NoError(t, err)
+	require.NoError(t, child.AddStep(s), "steps may use swapped in ingredients")
+
+	resolved, err := child.Resolve(parent)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"oil", "flour", "oat milk"}, lineNames(resolved))
+	assert.Equal(t, 1.0, resolved.Ingredients()[1].Quantity().Amount, "inherited lines scale to the variation")
+	require.Len(t, resolved.Prep(), 1)
+	assert.Equal(t, oil.Ingredient().ID, resolved.Prep()[0].IngredientID())
+	require.Len(t, resolved.Steps(), 2)
+	assert.Equal(t, "fry", resolved.Steps()[0].Action())
+	assert.Equal(t, oil.Ingredient().ID, resolved.Steps()[0].IngredientID())
+	assert.Equal(t, "grease pan", resolved.Steps()[1].Action())
+	assert.Len(t, child.Ingredients(), 1, "resolving leaves the variation as is")
+
+	other, err := NewRecipe("waffles", "", domain.Western, 2)
+	require.NoError(t, err)
+	_, err = child.Resolve(other)
+	assert.ErrorIs(t, err, ErrNotVariationOf)
+	assert.ErrorIs(t, child.Override(other, Omit(butter.Ingredient().ID)), ErrNotVariationOf)
+}
+
+func prepActions(r Recipe) []string {
+	var actions []string
+	for i, p := range r.Prep() {
+		if p.Index() != i {
+			return nil
+		}
+		actions = append(actions, p.Action())
+	}
+	return actions
+}
+
+func stepActions(r Recipe) []string {
+	var actions []string
+	for i, s := range r.Steps() {
+		if s.Index() != i {
+			return nil
+		}
+		actions = append(actions, s.Action())
+	}
+	return actions
+}
+
+func TestStepOrder(t *testing.T) {
+	r, err := NewRecipe("omelette", "", domain.French, 1)
+	require.NoError(t, err)
+	egg := newLine(t, "egg", 3, "")
+	r.AddIngredient(egg)
+
+	newStep := func(action string) domain.Step {
+		s, err := domain.NewStep(uuid.Nil, action, units.Temperature{})
+		require.NoError(t, err)
+		return s
+	}
+	require.NoError(t, r.AddStep(newStep("heat pan")))
+	require.NoError(t, r.AddStep(newStep("fold")))
+	require.NoError(t, r.InsertStep(1, newStep("pour")))
+	require.NoError(t, r.InsertStep(3, newStep("serve")))
+	assert.Equal(t, []string{"heat pan", "pour", "fold", "serve"}, stepActions(r))
+	assert.ErrorIs(t, r.InsertStep(5, newStep("rest")), ErrStepNotFound)
+
+	before := r
+	require.NoError(t, r.MoveStep(3, 0))
+	assert.Equal(t, []string{"serve", "heat pan", "pour", "fold"}, stepActions(r))
+	assert.Equal(t, []string{"heat pan", "pour", "fold", "serve"}, stepActions(before), "changes do not alias")
+	require.NoError(t, r.MoveStep(0, 3))
+	require.NoError(t, r.RemoveStep(1))
+	assert.Equal(t, []string{"heat pan", "fold", "serve"}, stepActions(r))
+	assert.ErrorIs(t, r.MoveStep(0, 3), ErrStepNotFound)
+	assert.ErrorIs(t, r.RemoveStep(3), ErrStepNotFound)
+
+	for _, action := range []string{"crack", "whisk"} {
+		p, err := domain.NewPrep(egg.Ingredient().ID, action)
+		require.NoError(t, err)
+		require.NoError(t, r.AddPrep(p))
+	}
+	p, err := domain.NewPrep(egg.Ingredient().ID, "season")
+	require.NoError(t, err)
+	require.NoError(t, r.InsertPrep(1, p))
+	assert.Equal(t, []string{"crack", "season", "whisk"}, prepActions(r))
+	require.NoError(t, r.MovePrep(0, 2))
+	assert.Equal(t, []string{"season", "whisk", "crack"}, prepActions(r))
+	require.NoError(t, r.RemovePrep(0))
+	assert.Equal(t, []string{"whisk", "crack"}, prepActions(r))
+	assert.ErrorIs(t, r.MovePrep(-1, 0), ErrStepNotFound)
+}
+
+func TestTimeline(t *testing.T) {
+	r, err := NewRecipe("roast vegetables", "", domain.Western, 2)
+	require.NoError(t, err)
+	newStep := func(action string, active, passive time.Duration, after ...int) domain.Step {
+		s, err := domain.NewStep(uuid.Nil, action, units.Temperature{})
+		require.NoError(t, err)
+		s, err = s.WithDurations(active, passive)
+		require.NoError(t, err)
+		s, err = s.WithDependencies(after)
+		require.NoError(t, err)
+		return s
+	}
+	require.NoError(t, r.AddStep(newStep("preheat oven", time.Minute, 15*time.Minute)))
+	require.NoError(t, r.AddStep(newStep("chop vegetables", 10*time.Minute, 0)))
+	require.NoError(t, r.AddStep(newStep("roast", 2*time.Minute, 30*time.Minute, 0, 1)))
+	require.NoError(t, r.AddStep(newStep("make dressing", 5*time.Minute, 0)))
+	require.NoError(t, r.AddStep(newStep("toss", 2*time.Minute, 0, 2, 3)))
+	assert.ErrorIs(t, r.InsertStep(1, newStep("rest", 0, time.Minute, 1)), ErrStepOrder)
+
+	timeline := r.Timeline()
+	assert.Equal(t, 50*time.Minute, timeline.Elapsed)
+	assert.Equal(t, 20*time.Minute, timeline.HandsOn)
+	var starts []time.Duration
+	var critical []string
+	for _, s := range timeline.Steps {
+		starts = append(starts, s.Start)
+		if s.Critical {
+			critical = append(critical, s.Step.Action())
+		}
+	}
+	assert.Equal(t, []time.Duration{0, time.Minute, 16 * time.Minute, 11 * time.Minute, 48 * time.Minute}, starts)
+	assert.Equal(t, []string{"preheat oven", "roast", "toss"}, critical)
+
+	// dependencies follow the steps they point at
+	require.NoError(t, r.MoveStep(3, 0))
+	assert.Equal(t, []int{1, 2}, r.Steps()[3].After())
+	assert.Equal(t, []int{0, 3}, r.Steps()[4].After())
+	assert.ErrorIs(t, r.MoveStep(3, 1), ErrStepOrder)
+	assert.ErrorIs(t, r.RemoveStep(1), ErrStepInUse)
+	require.NoError(t, r.InsertStep(0, newStep("wash vegetables", 3*time.Minute, 0)))
+	assert.Equal(t, []int{2, 3}, r.Steps()[4].After())
+	timeline = r.Timeline()
+	assert.Equal(t, 50*time.Minute, timeline.Elapsed, "washing fits in while the vegetables roast")
+	assert.Equal(t, 23*time.Minute, timeline.HandsOn)
+}
+
+func TestPlanMeal(t *testing.T) {
+	type step struct {
+		action          string
+		equipment       domain.Equipment
+		temperature     float64
+		active, passive time.Duration
+		after           []int
+	}
+	newMealRecipe := func(name string, steps ...step) Recipe {
+		r, err := NewRecipe(name, "", domain.Western, 4)
+		require.NoError(t, err)
+		for _, v := range steps {
+			var temperature units.Temperature
+			if v.temperature != 0 {
+				temperature = units.Temperature{Value: v.temperature, Unit: units.Celsius}
+			}
+			s, err := domain.NewStep(uuid.Nil, v.action, temperature)
+			require.NoError(t, err)
+			s, err = s.WithDurations(v.active, v.passive)
+			require.NoError(t, err)
+			s, err = s.WithDependencies(v.after)
+			require.NoError(t, err)
+			s, err = s.WithEquipment(v.equipment)
+			require.NoError(t, err)
+			require.NoError(t, r.AddStep(s))
+		}
+		return r
+	}
+	chicken := newMealRecipe("roast chicken",
+		step{action: "preheat oven", equipment: domain.Oven, temperature: 200, active: time.Minute, passive: 14 * time.Minute},
+		step{action: "season chicken", active: 5 * time.Minute},
+		step{action: "roast chicken", equipment: domain.Oven, temperature: 200, active: 2 * time.Minute, passive: time.Hour, after: []int{0, 1}},
+		step{action: "rest chicken", passive: 10 * time.Minute, after: []int{2}},
+	)
+	gratin := newMealRecipe("potato gratin",
+		step{action: "slice potatoes", active: 10 * time.Minute},
+		step{action: "bake gratin", equipment: domain.Oven, temperature: 180, active: 2 * time.Minute, passive: 40 * time.Minute, after: []int{0}},
+	)
+	cream := newMealRecipe("whipped cream",
+		step{action: "whip cream", active: 5 * time.Minute},
+	)
+	serveAt := time.Date(2024, 12, 25, 18, 0, 0, 0, time.UTC)
+
+	meal, err := PlanMeal(context.Background(), []Recipe{chicken, gratin, cream}, serveAt, DefaultKitchen)
+	require.NoError(t, err)
+	// one oven means the gratin has to wait for the chicken
+	assert.Equal(t, 119*time.Minute, meal.Elapsed)
+	assert.Equal(t, 25*time.Minute, meal.HandsOn)
+	assert.Equal(t, serveAt.Add(-119*time.Minute), meal.StartAt)
+	var actions []string
+	ready := make(map[string]time.Duration)
+	for i, s := range meal.Steps {
+		actions = append(actions, s.Step.Action())
+		ready[s.Recipe.Name()] = max(ready[s.Recipe.Name()], s.End)
+		for _, o := range meal.Steps[:i] {
+			if s.Step.Active() > 0 && o.Step.Active() > 0 {
+				assert.False(t, overlaps(s.Start, s.Start+s.Step.Active(), o.Start, o.Start+o.Step.Active()), "%s and %s both need the cook", s.Step.Action(), o.Step.Action())
+			}
+			if s.Step.Equipment() == domain.Oven && o.Step.Equipment() == domain.Oven && s.Step.Temperature() != o.Step.Temperature() {
+				assert.False(t, overlaps(s.Start, s.End, o.Start, o.End), "%s and %s both need the oven", s.Step.Action(), o.Step.Action())
+			}
+		}
+	}
+	assert.Equal(t, []string{"preheat oven", "season chicken", "roast chicken", "slice potatoes", "bake gratin", "rest chicken", "whip cream"}, actions)
+	assert.Equal(t, map[string]time.Duration{"roast chicken": meal.Elapsed, "potato gratin": meal.Elapsed, "whipped cream": meal.Elapsed}, ready, "everything is ready to serve together")
+
+	meal, err = PlanMeal(context.Background(), []Recipe{chicken, gratin, cream}, serveAt, Kitchen{Ovens: 2, Burners: 4})
+	require.NoError(t, err)
+	assert.Equal(t, 87*time.Minute, meal.Elapsed)
+
+	_, err = PlanMeal(context.Background(), nil, serveAt, DefaultKitchen)
+	assert.ErrorIs(t, err, ErrEmptyMeal)
+	_, err = PlanMeal(context.Background(), []Recipe{chicken, chicken}, serveAt, DefaultKitchen)
+	assert.ErrorIs(t, err, ErrDuplicateRecipe)
+	_, err = PlanMeal(context.Background(), []Recipe{chicken}, serveAt, Kitchen{})
+	assert.ErrorIs(t, err, ErrInvalidKitchen)
+
+	long := newRecipe(t, "tasting menu")
+	for range MaxMealSteps + 1 {
+		s, err := domain.NewStep(uuid.Nil, "plate", units.Temperature{})
+		require.NoError(t, err)
+		require.NoError(t, long.AddStep(s))
+	}
+	_, err = PlanMeal(context.Background(), []Recipe{long}, serveAt, DefaultKitchen)
+	assert.ErrorIs(t, err, ErrMealTooLarge)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = PlanMeal(cancelled, []Recipe{chicken, gratin, cream}, serveAt, DefaultKitchen)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestStepTemperature(t *testing.T) {
+	r, err := NewRecipe("steak", "", domain.Western, 2)
+	require.NoError(t, err)
+	hot := units.Temperature{Value: 450, Unit: units.Fahrenheit}
+	s, err := domain.NewStep(uuid.Nil, "sear", hot)
+	require.NoError(t, err)
+	_, err = s.WithMethod(domain.SousVide)
+	assert.ErrorIs(t, err, domain.ErrImplausibleTemperature)
+	s, err = s.WithMethod(domain.Roast)
+	require.NoError(t, err)
+	require.NoError(t, r.AddStep(s))
+
+	s, err = domain.NewStep(uuid.Nil, "cook in bath", units.Temperature{Value: 55, Unit: units.Celsius})
+	require.NoError(t, err)
+	_, err = s.WithMethod(domain.Bake)
+	assert.ErrorIs(t, err, domain.ErrImplausibleTemperature)
+	s, err = s.WithMethod(domain.SousVide)
+	require.NoError(t, err)
+	require.NoError(t, r.AddStep(s))
+
+	s, err = domain.NewStep(uuid.Nil, "bake", units.Temperature{Value: 4, Unit: units.GasMark})
+	require.NoError(t, err)
+	s, err = s.WithMethod(domain.Bake)
+	require.NoError(t, err, "gas mark 4 is a baking temperature")
+	require.NoError(t, r.AddStep(s))
+
+	_, err = domain.NewStep(uuid.Nil, "bake", units.Temperature{Value: 12, Unit: units.GasMark})
+	assert.ErrorIs(t, err, units.ErrInvalidTemperature)
+
+	var temperatures []string
+	for _, s := range r.InSystem(units.Metric).Steps() {
+		temperatures = append(temperatures, s.Temperature().String())
+	}
+	assert.Equal(t, []string{"230°C", "55°C", "175°C"}, temperatures)
+	temperatures = temperatures[:0]
+	for _, s := range r.InTemperatureUnit(units.GasMark).Steps() {
+		temperatures = append(temperatures, s.Temperature().String())
+	}
+	assert.Equal(t, []string{"gas mark 8", "gas mark 0.25", "gas mark 4"}, temperatures)
+	assert.Equal(t, hot, r.Steps()[0].Temperature(), "converting leaves the recipe as authored")
+}
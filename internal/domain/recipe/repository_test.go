@@ -0,0 +1,697 @@
+package recipe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/units"
+	"github.com/bento01dev/cookbook/internal/search"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// repository is the behaviour every recipe repository has to share.
+// It mirrors the recipeRepository interface the recipe service uses.
+type repository interface {
+	Get(context.Context, uuid.UUID) (Recipe, error)
+	Add(context.Context, Recipe) error
+	Update(context.Context, Recipe) (Recipe, error)
+	Delete(context.Context, uuid.UUID) error
+	Purge(context.Context, time.Time) (int, error)
+	List(context.Context, Query) (Page, error)
+	Search(context.Context, search.Query, int) ([]SearchHit, error)
+	SearchFacets(context.Context, search.Query) (Facets, error)
+	Facets(context.Context, Query) (Facets, error)
+}
+
+// testRepository runs the repository contract against a fresh
+// repository from newRepo for every case.
+func testRepository(t *testing.T, newRepo func(t *testing.T) repository) {
+	ctx := context.Background()
+
+	t.Run("add then get returns the same recipe", func(t *testing.T) {
+		repo := newRepo(t)
+		r := fullRecipe(t)
+		require.NoError(t, r.Restore())
+
+		require.NoError(t, repo.Add(ctx, r))
+		got, err := repo.Get(ctx, r.ID())
+		require.NoError(t, err)
+		assert.Equal(t, r, got)
+	})
+
+	t.Run("get unknown id is not found", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.Get(ctx, uuid.New())
+		assert.ErrorIs(t, err, ErrRecipeNotFound)
+	})
+
+	t.Run("adding an existing recipe conflicts", func(t *testing.T) {
+		repo := newRepo(t)
+		r := newRecipe(t, "pancakes")
+		require.NoError(t, repo.Add(ctx, r))
+
+		name := "crepes"
+		require.NoError(t, r.Apply(Patch{Name: &name}))
+		assert.ErrorIs(t, repo.Add(ctx, r), ErrRecipeExists)
+
+		got, err := repo.Get(ctx, r.ID())
+		require.NoError(t, err)
+		assert.Equal(t, "pancakes", got.Name())
+	})
+
+	t.Run("update replaces the stored recipe", func(t *testing.T) {
+		repo := newRepo(t)
+		r := newRecipe(t, "pancakes")
+		require.NoError(t, repo.Add(ctx, r))
+
+		name := "crepes"
+		require.NoError(t, r.Apply(Patch{Name: &name}))
+		r.AddIngredient(newLine(t, "flour", 200, "g"))
+		updated, err := repo.Update(ctx, r)
+		require.NoError(t, err)
+		assert.Equal(t, r.version+1, updated.version)
+
+		got, err := repo.Get(ctx, r.ID())
+		require.NoError(t, err)
+		assert.Equal(t, updated, got)
+	})
+
+	t.Run("stale update conflicts", func(t *testing.T) {
+		repo := newRepo(t)
+		r := newRecipe(t, "pancakes")
+		require.NoError(t, repo.Add(ctx, r))
+
+		first, second := r, r
+		name := "crepes"
+		require.NoError(t, first.Apply(Patch{Name: &name}))
+		_, err := repo.Update(ctx, first)
+		require.NoError(t, err)
+
+		name = "waffles"
+		require.NoError(t, second.Apply(Patch{Name: &name}))
+		_, err = repo.Update(ctx, second)
+		assert.ErrorIs(t, err, ErrRecipeConflict)
+
+		got, err := repo.Get(ctx, r.ID())
+		require.NoError(t, err)
+		assert.Equal(t, "crepes", got.Name())
+	})
+
+	t.Run("reordered steps read back in order", func(t *testing.T) {
+		repo := newRepo(t)
+		r := newRecipe(t, "stir fry")
+		beef := newLine(t, "beef", 300, "g")
+		r.AddIngredient(beef)
+		for _, action := range []string{"slice", "marinate", "pat dry"} {
+			p, err := domain.NewPrep(beef.Ingredient().ID, action)
+			require.NoError(t, err)
+			require.NoError(t, r.AddPrep(p))
+		}
+		for _, action := range []string{"sear", "toss", "heat wok"} {
+			s, err := domain.NewStep(uuid.Nil, action, units.Temperature{})
+			require.NoError(t, err)
+			require.NoError(t, r.AddStep(s))
+		}
+		require.NoError(t, repo.Add(ctx, r))
+
+		require.NoError(t, r.MovePrep(2, 1))
+		require.NoError(t, r.MoveStep(2, 0))
+		r, err := repo.Update(ctx, r)
+		require.NoError(t, err)
+
+		got, err := repo.Get(ctx, r.ID())
+		require.NoError(t, err)
+		assert.Equal(t, r, got)
+		assert.Equal(t, []string{"slice", "pat dry", "marinate"}, prepActions(got))
+		assert.Equal(t, []string{"heat wok", "sear", "toss"}, stepActions(got))
+	})
+
+	t.Run("update unknown recipe is not found", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.Update(ctx, newRecipe(t, "pancakes"))
+		assert.ErrorIs(t, err, ErrRecipeNotFound)
+	})
+
+	t.Run("delete removes the recipe", func(t *testing.T) {
+		repo := newRepo(t)
+		r := newRecipe(t, "pancakes")
+		require.NoError(t, repo.Add(ctx, r))
+
+		require.NoError(t, repo.Delete(ctx, r.ID()))
+		_, err := repo.Get(ctx, r.ID())
+		assert.ErrorIs(t, err, ErrRecipeNotFound)
+		assert.ErrorIs(t, repo.Delete(ctx, r.ID()), ErrRecipeNotFound)
+	})
+
+	t.Run("purge only removes old tombstones", func(t *testing.T) {
+		repo := newRepo(t)
+		live := newRecipe(t, "live")
+		deleted := newRecipe(t, "deleted")
+		require.NoError(t, deleted.Delete())
+		require.NoError(t, repo.Add(ctx, live))
+		require.NoError(t, repo.Add(ctx, deleted))
+
+		purged, err := repo.Purge(ctx, deleted.DeletedAt())
+		require.NoError(t, err)
+		assert.Equal(t, 0, purged)
+
+		purged, err = repo.Purge(ctx, deleted.DeletedAt().Add(time.Millisecond))
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		_, err = repo.Get(ctx, deleted.ID())
+		assert.ErrorIs(t, err, ErrRecipeNotFound)
+		_, err = repo.Get(ctx, live.ID())
+		assert.NoError(t, err)
+	})
+
+	t.Run("cancelled context is reported and nothing is written", func(t *testing.T) {
+		repo := newRepo(t)
+		r := newRecipe(t, "pancakes")
+		require.NoError(t, repo.Add(ctx, r))
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := repo.Get(cancelled, r.ID())
+		assert.ErrorIs(t, err, context.Canceled)
+
+		other := newRecipe(t, "waffles")
+		assert.ErrorIs(t, repo.Add(cancelled, other), context.Canceled)
+		_, err = repo.Get(ctx, other.ID())
+		assert.ErrorIs(t, err, ErrRecipeNotFound)
+
+		name := "crepes"
+		require.NoError(t, r.Apply(Patch{Name: &name}))
+		_, err = repo.Update(cancelled, r)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		assert.ErrorIs(t, repo.Delete(cancelled, r.ID()), context.Canceled)
+		_, err = repo.Purge(cancelled, time.Now())
+		assert.ErrorIs(t, err, context.Canceled)
+
+		got, err := repo.Get(ctx, r.ID())
+		require.NoError(t, err)
+		assert.Equal(t, "pancakes", got.Name())
+	})
+
+	t.Run("listing filters, sorts and pages", func(t *testing.T) {
+		testListing(t, newRepo)
+	})
+
+	t.Run("sharing lists recipes with an ingredient or technique in common", func(t *testing.T) {
+		repo := newRepo(t)
+		withSteps := func(name string, ingredients []string, actions ...string) Recipe {
+			r := newRecipe(t, name)
+			for _, i := range ingredients {
+				r.AddIngredient(newLine(t, i, 1, ""))
+			}
+			for _, a := range actions {
+				s, err := domain.NewStep(r.Ingredients()[0].Ingredient().ID, a, units.Temperature{})
+				require.NoError(t, err)
+				require.NoError(t, r.AddStep(s))
+			}
+			require.NoError(t, repo.Add(ctx, r))
+			return r
+		}
+		omelette := withSteps("omelette", []string{"egg", "chive"}, "whisk")
+		custard := withSteps("custard", []string{"milk", "egg"}, "simmer")
+		meringue := withSteps("meringue", []string{"sugar"}, "whisk")
+		withSteps("rice", []string{"rice"}, "simmer")
+
+		names := func() []string {
+			page, err := repo.List(ctx, Query{Sharing: omelette.Traits(), Sort: SortByName})
+			require.NoError(t, err)
+			var names []string
+			for _, r := range page.Recipes {
+				names = append(names, r.Name())
+			}
+			return names
+		}
+		assert.Equal(t, []string{"custard", "meringue", "omelette"}, names())
+
+		// traits are rewritten with the recipe
+		require.NoError(t, custard.RemoveIngredient(1))
+		_, err := repo.Update(ctx, custard)
+		require.NoError(t, err)
+		require.NoError(t, meringue.Delete())
+		_, err = repo.Update(ctx, meringue)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"omelette"}, names())
+	})
+
+	t.Run("listing by id and by pairing", func(t *testing.T) {
+		repo := newRepo(t)
+		toast, bacon, eggs := newRecipe(t, "toast"), newRecipe(t, "bacon"), newRecipe(t, "eggs")
+		for _, with := range []Recipe{bacon, eggs} {
+			p, err := domain.NewPairing(toast.ID(), with.ID(), "")
+			require.NoError(t, err)
+			require.NoError(t, toast.AddPairing(p))
+		}
+		p, err := domain.NewPairing(eggs.ID(), bacon.ID(), "")
+		require.NoError(t, err)
+		require.NoError(t, eggs.AddPairing(p))
+		soup := newRecipe(t, "soup")
+		for _, r := range []Recipe{toast, bacon, eggs, soup} {
+			require.NoError(t, repo.Add(ctx, r))
+		}
+
+		names := func(q Query) []string {
+			q.Sort = SortByName
+			page, err := repo.List(ctx, q)
+			require.NoError(t, err)
+			var names []string
+			for _, r := range page.Recipes {
+				names = append(names, r.Name())
+			}
+			return names
+		}
+		assert.Equal(t, []string{"bacon", "toast"}, names(Query{IDs: []uuid.UUID{toast.ID(), bacon.ID(), uuid.New()}}))
+		assert.Equal(t, []string{"eggs", "toast"}, names(Query{PairedWith: []uuid.UUID{bacon.ID()}}))
+
+		// pairings are reindexed with the recipe
+		p, err = domain.NewPairing(soup.ID(), bacon.ID(), "")
+		require.NoError(t, err)
+		require.NoError(t, soup.AddPairing(p))
+		_, err = repo.Update(ctx, soup)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"eggs", "soup", "toast"}, names(Query{PairedWith: []uuid.UUID{bacon.ID()}}))
+	})
+
+	t.Run("listing by ingredient", func(t *testing.T) {
+		repo := newRepo(t)
+		butter, oil, flour := newLine(t, "butter", 50, "g"), newLine(t, "oil", 40, "ml"), newLine(t, "flour", 200, "g")
+		pancakes := newRecipe(t, "pancakes")
+		pancakes.AddIngredient(butter)
+		pancakes.AddIngredient(flour)
+		vegan, err := NewVariation(pancakes, "vegan pancakes", "")
+		require.NoError(t, err)
+		require.NoError(t, vegan.Override(pancakes, Swap(butter.Ingredient().ID, oil)))
+		bread := newRecipe(t, "bread")
+		bread.AddIngredient(flour)
+		require.NoError(t, bread.Delete())
+		for _, r := range []Recipe{pancakes, vegan, bread} {
+			require.NoError(t, repo.Add(ctx, r))
+		}
+
+		names := func(ingredients ...uuid.UUID) []string {
+			page, err := repo.List(ctx, Query{Ingredients: ingredients, IncludeDeleted: true, Sort: SortByName})
+			require.NoError(t, err)
+			var names []string
+			for _, r := range page.Recipes {
+				names = append(names, r.Name())
+			}
+			return names
+		}
+		assert.Equal(t, []string{"bread", "pancakes"}, names(flour.Ingredient().ID))
+		assert.Equal(t, []string{"vegan pancakes"}, names(oil.Ingredient().ID))
+		assert.Equal(t, []string{"pancakes", "vegan pancakes"}, names(butter.Ingredient().ID, oil.Ingredient().ID))
+
+		// ingredients are reindexed with the recipe
+		require.NoError(t, pancakes.RemoveIngredient(1))
+		_, err = repo.Update(ctx, pancakes)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"bread"}, names(flour.Ingredient().ID))
+		require.NoError(t, repo.Delete(ctx, bread.ID()))
+		assert.Empty(t, names(flour.Ingredient().ID))
+	})
+
+	t.Run("facets count every matching recipe", func(t *testing.T) {
+		testFacets(t, newRepo)
+	})
+
+	t.Run("search ranks, filters and highlights", func(t *testing.T) {
+		testSearch(t, newRepo)
+	})
+
+	t.Run("concurrent writers", func(t *testing.T) {
+		repo := newRepo(t)
+		shared := newRecipe(t, "shared")
+		require.NoError(t, repo.Add(ctx, shared))
+
+		const writers = 8
+		added := make([]Recipe, writers)
+		names := make(map[string]bool)
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			updated int
+		)
+		for i := range writers {
+			added[i] = newRecipe(t, fmt.Sprintf("recipe %d", i))
+			name := fmt.Sprintf("shared %d", i)
+			names[name] = true
+			update := shared
+			require.NoError(t, update.Apply(Patch{Name: &name}))
+
+			wg.Add(2)
+			go func(r Recipe) {
+				defer wg.Done()
+				assert.NoError(t, repo.Add(ctx, r))
+			}(added[i])
+			go func(r Recipe) {
+				defer wg.Done()
+				_, err := repo.Update(ctx, r)
+				if err != nil {
+					assert.ErrorIs(t, err, ErrRecipeConflict)
+					return
+				}
+				mu.Lock()
+				updated++
+				mu.Unlock()
+			}(update)
+		}
+		wg.Wait()
+		// every writer read the same version, so only one may win
+		assert.Equal(t, 1, updated)
+
+		for _, r := range added {
+			got, err := repo.Get(ctx, r.ID())
+			require.NoError(t, err)
+			assert.Equal(t, r.Name(), got.Name())
+		}
+		got, err := repo.Get(ctx, shared.ID())
+		require.NoError(t, err)
+		assert.True(t, names[got.Name()], "unexpected name %q", got.Name())
+	})
+}
+
+// listAll follows cursors until the last page, checking no page is
+// larger than asked for.
+func listAll(t *testing.T, repo repository, q Query) []string {
+	t.Helper()
+	var names []string
+	for {
+		page, err := repo.List(context.Background(), q)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Recipes), q.Limit)
+		for _, r := range page.Recipes {
+			names = append(names, r.Name())
+		}
+		if page.NextCursor == "" {
+			return names
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func testListing(t *testing.T, newRepo func(t *testing.T) repository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	recipes := map[string]Recipe{}
+	for i, spec := range []struct {
+		name    string
+		cuisine domain.CuisineType
+		typ     domain.IngredientType
+	}{
+		{"dashi", domain.Japanese, domain.Fish},
+		{"bouillabaisse", domain.French, domain.Fish},
+		{"ratatouille", domain.French, domain.Vegetable},
+		{"paella", domain.Spanish, domain.Poultry},
+		{"gazpacho", domain.Spanish, domain.Vegetable},
+	} {
+		r, err := NewRecipe(spec.name, "", spec.cuisine, 2)
+		require.NoError(t, err)
+		// spread creation times so the default order is known
+		r.createdAt = r.createdAt.Add(time.Duration(i) * time.Second)
+		line, err := domain.NewIngredientLine(domain.Ingredient{ID: uuid.New(), Name: "main", Type: spec.typ}, 1, "", "")
+		require.NoError(t, err)
+		r.AddIngredient(line)
+		r.updatedAt = r.createdAt
+		require.NoError(t, repo.Add(ctx, r))
+		recipes[spec.name] = r
+	}
+	deleted := newRecipe(t, "deleted")
+	require.NoError(t, deleted.Delete())
+	require.NoError(t, repo.Add(ctx, deleted))
+
+	assert.Equal(t,
+		[]string{"dashi", "bouillabaisse", "ratatouille", "paella", "gazpacho"},
+		listAll(t, repo, Query{Limit: 2}),
+	)
+	assert.Equal(t,
+		[]string{"ratatouille", "paella", "gazpacho", "dashi", "bouillabaisse"},
+		listAll(t, repo, Query{Sort: SortByName, Descending: true, Limit: 2}),
+	)
+	assert.Equal(t,
+		[]string{"bouillabaisse", "ratatouille", "paella", "gazpacho"},
+		listAll(t, repo, Query{Cuisines: []domain.CuisineType{domain.French, domain.Spanish}, Limit: 3}),
+	)
+	assert.Equal(t,
+		[]string{"ratatouille", "gazpacho"},
+		listAll(t, repo, Query{IngredientTypes: []domain.IngredientType{domain.Vegetable}, Limit: 1}),
+	)
+	assert.Equal(t,
+		[]string{"bouillabaisse", "ratatouille"},
+		listAll(t, repo, Query{
+			CreatedAfter:  recipes["bouillabaisse"].createdAt,
+			CreatedBefore: recipes["paella"].createdAt,
+			Limit:         5,
+		}),
+	)
+	assert.Equal(t,
+		[]string{"gazpacho", "paella"},
+		listAll(t, repo, Query{
+			UpdatedAfter: recipes["paella"].updatedAt,
+			Sort:         SortByUpdatedAt,
+			Descending:   true,
+			Limit:        5,
+		}),
+	)
+	assert.Equal(t,
+		[]string{"bouillabaisse", "dashi", "deleted", "gazpacho", "paella", "ratatouille"},
+		listAll(t, repo, Query{Sort: SortByName, IncludeDeleted: true, Limit: 4}),
+	)
+
+	page, err := repo.List(ctx, Query{Limit: 2})
+	require.NoError(t, err)
+	_, err = repo.List(ctx, Query{Limit: 2, Sort: SortByName, Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = repo.List(ctx, Query{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = repo.List(ctx, Query{Limit: MaxPageSize + 1})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func testFacets(t *testing.T, newRepo func(t *testing.T) repository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	for _, spec := range []struct {
+		name    string
+		cuisine domain.CuisineType
+		types   []domain.IngredientType
+		total   time.Duration
+		tags    []domain.DietaryTag
+	}{
+		{"dashi", domain.Japanese, []domain.IngredientType{domain.Fish, domain.Vegetable}, 20 * time.Minute, nil},
+		{"ratatouille", domain.French, []domain.IngredientType{domain.Vegetable, domain.Vegetable}, 90 * time.Minute, []domain.DietaryTag{domain.Vegan, domain.GlutenFree}},
+		{"gazpacho", domain.Spanish, []domain.IngredientType{domain.Vegetable}, 10 * time.Minute, []domain.DietaryTag{domain.Vegan}},
+		{"paella", domain.Spanish, []domain.IngredientType{domain.Poultry}, 3 * time.Hour, []domain.DietaryTag{domain.GlutenFree}},
+		{"toast", domain.Western, nil, 0, []domain.DietaryTag{domain.Vegetarian}},
+		{"bouillabaisse", domain.French, []domain.IngredientType{domain.Shellfish, domain.Fish}, 45 * time.Minute, nil},
+	} {
+		r, err := NewRecipe(spec.name, "", spec.cuisine, 2)
+		require.NoError(t, err)
+		for _, typ := range spec.types {
+			line, err := domain.NewIngredientLine(domain.Ingredient{ID: uuid.New(), Name: "main", Type: typ}, 1, "", "")
+			require.NoError(t, err)
+			r.AddIngredient(line)
+		}
+		tags := spec.tags
+		require.NoError(t, r.Apply(Patch{CookTime: &spec.total, DietaryTags: &tags}))
+		require.NoError(t, repo.Add(ctx, r))
+	}
+	deleted := newRecipe(t, "deleted")
+	require.NoError(t, deleted.Delete())
+	require.NoError(t, repo.Add(ctx, deleted))
+
+	f, err := repo.Facets(ctx, Query{})
+	require.NoError(t, err)
+	assert.Equal(t, Facets{
+		Cuisines:        map[domain.CuisineType]int{domain.Japanese: 1, domain.French: 2, domain.Spanish: 2, domain.Western: 1},
+		IngredientTypes: map[domain.IngredientType]int{domain.Fish: 2, domain.Shellfish: 1, domain.Vegetable: 3, domain.Poultry: 1},
+		TotalTimes:      map[TimeBucket]int{Under15Minutes: 1, Under30Minutes: 1, Under1Hour: 1, Under2Hours: 1, Over2Hours: 1},
+		DietaryTags:     map[domain.DietaryTag]int{domain.Vegan: 2, domain.GlutenFree: 2, domain.Vegetarian: 1},
+		typeSets:        map[string]int{"1": 2, "1,4": 1, "3": 1, "4,9": 1},
+	}, f)
+
+	// rolled up, the bouillabaisse counts once for fish although it
+	// has shellfish too
+	parents := map[domain.IngredientType]domain.IngredientType{
+		domain.Shellfish: domain.Fish,
+		domain.Fish:      domain.Protein,
+		domain.Poultry:   domain.Meat,
+		domain.Meat:      domain.Protein,
+	}
+	ancestors := func(t domain.IngredientType) []domain.IngredientType {
+		up := []domain.IngredientType{t}
+		for p, ok := parents[t]; ok; p, ok = parents[p] {
+			up = append(up, p)
+		}
+		return up
+	}
+	regions := func(c domain.CuisineType) []domain.CuisineType {
+		if c == domain.Spanish {
+			return []domain.CuisineType{c, domain.Western}
+		}
+		return nil
+	}
+	rolled, err := f.RollUp(regions, ancestors)
+	require.NoError(t, err)
+	assert.Equal(t, map[domain.CuisineType]int{domain.Japanese: 1, domain.French: 2, domain.Spanish: 2, domain.Western: 3}, rolled.Cuisines)
+	assert.Equal(t, map[domain.IngredientType]int{
+		domain.Vegetable: 3,
+		domain.Fish:      2,
+		domain.Shellfish: 1,
+		domain.Poultry:   1,
+		domain.Meat:      1,
+		domain.Protein:   3,
+	}, rolled.IngredientTypes)
+	_, err = Facets{typeSets: map[string]int{"4,fish": 1}}.RollUp(regions, ancestors)
+	assert.Error(t, err, "an unreadable type set is not dropped")
+
+	q := Query{DietaryTags: []domain.DietaryTag{domain.Vegan}}
+	f, err = repo.Facets(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, Facets{
+		Cuisines:        map[domain.CuisineType]int{domain.French: 1, domain.Spanish: 1},
+		IngredientTypes: map[domain.IngredientType]int{domain.Vegetable: 2},
+		TotalTimes:      map[TimeBucket]int{Under15Minutes: 1, Under2Hours: 1},
+		DietaryTags:     map[domain.DietaryTag]int{domain.Vegan: 2, domain.GlutenFree: 1},
+		typeSets:        map[string]int{"1": 2},
+	}, f)
+
+	q.Sort, q.Limit = SortByName, 5
+	assert.Equal(t, []string{"gazpacho", "ratatouille"}, listAll(t, repo, q))
+	assert.Equal(t, []string{"ratatouille"}, listAll(t, repo, Query{
+		DietaryTags: []domain.DietaryTag{domain.GlutenFree, domain.Vegan},
+		Sort:        SortByName,
+		Limit:       5,
+	}))
+	assert.Equal(t, []string{"dashi", "gazpacho", "paella"}, listAll(t, repo, Query{
+		TotalTimes: []TimeBucket{Under15Minutes, Under30Minutes, Over2Hours},
+		Sort:       SortByName,
+		Limit:      5,
+	}))
+
+	_, err = repo.Facets(ctx, Query{TotalTimes: []TimeBucket{UnknownTime}})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func testSearch(t *testing.T, newRepo func(t *testing.T) repository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	recipes := map[string]Recipe{}
+	for _, spec := range []struct {
+		name, description, ingredient string
+	}{
+		{"Tomato soup", "Roasted tomatoes blended with olive oil", "tomato"},
+		{"Bruschetta", "Grilled bread with chopped tomato", "bread"},
+		{"Pancakes", "Fluffy and sweet", "flour"},
+	} {
+		r, err := NewRecipe(spec.name, spec.description, domain.Western, 2)
+		require.NoError(t, err)
+		r.AddIngredient(newLine(t, spec.ingredient, 1, ""))
+		require.NoError(t, repo.Add(ctx, r))
+		recipes[spec.name] = r
+	}
+	deleted, err := NewRecipe("Tomato tart", "", domain.French, 2)
+	require.NoError(t, err)
+	require.NoError(t, deleted.Delete())
+	require.NoError(t, repo.Add(ctx, deleted))
+
+	find := func(s string, limit int) []SearchHit {
+		t.Helper()
+		q, err := search.ParseQuery(s)
+		require.NoError(t, err)
+		hits, err := repo.Search(ctx, q, limit)
+		require.NoError(t, err)
+		return hits
+	}
+	names := func(s string) []string {
+		t.Helper()
+		var names []string
+		for _, h := range find(s, MaxPageSize) {
+			names = append(names, h.Recipe.Name())
+		}
+		return names
+	}
+
+	// a match in the name outranks one in the description
+	assert.Equal(t, []string{"Tomato soup", "Bruschetta"}, names("tomatoes"))
+	assert.Equal(t, []string{"Bruschetta"}, names("chopping tomato"))
+	assert.Equal(t, []string{"Tomato soup"}, names(`"olive oil"`))
+	assert.Empty(t, names("tomato pancakes"))
+	assert.Len(t, find("tomato", 1), 1)
+
+	// one alternative of a group of synonyms is enough
+	q, err := search.ParseQuery("grilled")
+	require.NoError(t, err)
+	alt := func(s string) []search.Term {
+		q, err := search.ParseQuery(s)
+		require.NoError(t, err)
+		return q.Terms
+	}
+	q.Synonyms = []search.Synonyms{{alt("toast"), alt("chopped tomato")}}
+	hits, err := repo.Search(ctx, q, MaxPageSize)
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "Bruschetta", hits[0].Recipe.Name())
+	q.Terms = nil
+	hits, err = repo.Search(ctx, q, MaxPageSize)
+	require.NoError(t, err)
+	assert.Len(t, hits, 1)
+	q.Synonyms = []search.Synonyms{{alt("sweet"), alt("olive oil")}}
+	hits, err = repo.Search(ctx, q, MaxPageSize)
+	require.NoError(t, err)
+	assert.Len(t, hits, 2)
+
+	// facets count every match, not only the hits asked for, and leave
+	// out the deleted tart
+	q, err = search.ParseQuery("tomato")
+	require.NoError(t, err)
+	f, err := repo.SearchFacets(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[domain.CuisineType]int{domain.Western: 2}, f.Cuisines)
+	q, err = search.ParseQuery(`"olive oil"`)
+	require.NoError(t, err)
+	f, err = repo.SearchFacets(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[domain.CuisineType]int{domain.Western: 1}, f.Cuisines)
+
+	hits = find("tomatoes", 1)
+	assert.Equal(t, recipes["Tomato soup"], hits[0].Recipe)
+	assert.Positive(t, hits[0].Score)
+	assert.Equal(t, []Highlight{
+		{Field: "name", Snippet: "<mark>Tomato</mark> soup"},
+		{Field: "description", Snippet: "Roasted <mark>tomatoes</mark> blended with olive oil"},
+		{Field: "ingredients", Snippet: "<mark>tomato</mark>"},
+	}, hits[0].Highlights)
+
+	renamed := recipes["Bruschetta"]
+	name := "Crostini"
+	require.NoError(t, renamed.Apply(Patch{Name: &name}))
+	_, err = repo.Update(ctx, renamed)
+	require.NoError(t, err)
+	assert.Empty(t, names("bruschetta"))
+	assert.Equal(t, []string{"Crostini"}, names("crostini"))
+
+	require.NoError(t, repo.Delete(ctx, renamed.ID()))
+	assert.Empty(t, names("crostini"))
+}
+
+func newRecipe(t *testing.T, name string) Recipe {
+	t.Helper()
+	r, err := NewRecipe(name, "", domain.Western, 2)
+	require.NoError(t, err)
+	return r
+}
@@ -0,0 +1,80 @@
+package recipe
+
+import (
+	"strings"
+
+	"github.com/bento01dev/cookbook/internal/search"
+)
+
+// Search field weights. A word in the name says most about what a
+// recipe is, then its ingredients, then the rest.
+const (
+	nameWeight        = 3
+	ingredientsWeight = 2
+	descriptionWeight = 1
+	stepsWeight       = 1
+)
+
+// SearchHit is a recipe matching a search, its relevance and snippets
+// of each field that matched with the matches marked up.
+type SearchHit struct {
+	Recipe     Recipe
+	Score      float64
+	Highlights []Highlight
+}
+
+type Highlight struct {
+	Field   string
+	Snippet string
+}
+
+// SearchQuery parses q and checks limit, filling in the default page
+// size when it is zero.
+func SearchQuery(q string, limit int) (search.Query, int, error) {
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	if limit < 0 || limit > MaxPageSize {
+		return search.Query{}, 0, ErrInvalidQuery
+	}
+	query, err := search.ParseQuery(q)
+	if err != nil {
+		return search.Query{}, 0, err
+	}
+	return query, limit, nil
+}
+
+// searchDocument is the text of r that search looks at. Prep and
+// cooking steps are searched as one field.
+func searchDocument(r Recipe) search.Document {
+	ingredients := make([]string, 0, len(r.ingredients))
+	for _, l := range r.ingredients {
+		ingredients = append(ingredients, l.Ingredient().Name)
+	}
+	steps := make([]string, 0, len(r.prepSteps)+len(r.steps))
+	for _, p := range r.prepSteps {
+		steps = append(steps, p.Action())
+	}
+	for _, s := range r.steps {
+		steps = append(steps, s.Action())
+	}
+	return search.Document{
+		ID: r.ID().String(),
+		Fields: []search.Field{
+			{Name: "name", Text: r.Name(), Weight: nameWeight},
+			{Name: "description", Text: r.Description(), Weight: descriptionWeight},
+			{Name: "ingredients", Text: strings.Join(ingredients, ", "), Weight: ingredientsWeight},
+			{Name: "steps", Text: strings.Join(steps, ". "), Weight: stepsWeight},
+		},
+	}
+}
+
+func searchHit(r Recipe, score float64, q search.Query) SearchHit {
+	hit := SearchHit{Recipe: r, Score: score}
+	for _, f := range searchDocument(r).Fields {
+		if snippet, ok := search.Highlight(f.Text, q); ok {
+			hit.Highlights = append(hit.Highlights, Highlight{Field: f.Name, Snippet: snippet})
+		}
+	}
+	return hit
+}
@@ -0,0 +1,207 @@
+package recipe
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/search"
+	"github.com/google/uuid"
+)
+
+// How much each signal counts towards similarity. Ingredients say the
+// most about what a dish is; sharing a cuisine or a way of cooking
+// mostly separates recipes with similar ingredients.
+const (
+	ingredientSimilarity = 0.6
+	cuisineSimilarity    = 0.25
+	techniqueSimilarity  = 0.15
+)
+
+// techniques are the cooking methods recognised in steps, keyed by
+// their stems.
+var techniques = stemmed(
+	"bake", "roast", "grill", "broil", "fry", "saute", "sear", "stir-fry",
+	"boil", "simmer", "poach", "steam", "blanch", "braise", "stew",
+	"smoke", "cure", "pickle", "ferment", "marinate", "knead", "proof",
+	"whisk", "whip", "fold", "blend", "puree", "caramelize", "reduce",
+	"deglaze", "toast", "glaze", "chop", "dice", "mince", "slice", "julienne",
+)
+
+// commonTechniques are the knife work nearly every recipe starts with.
+// Sharing them says nothing about two dishes, so they neither make a
+// recipe a candidate nor count towards how similar it is.
+var commonTechniques = []string{"chop", "dice", "mince", "slice"}
+
+func stemmed(words ...string) map[string]string {
+	m := make(map[string]string, len(words))
+	for _, w := range words {
+		m[strings.Join(search.Stems(w), " ")] = w
+	}
+	return m
+}
+
+// Techniques lists the cooking methods named in the recipe's prep and
+// cooking steps, each once, in the order first used.
+func (r Recipe) Techniques() []string {
+	var found []string
+	add := func(action string) {
+		stems := search.Stems(action)
+		for i := 0; i < len(stems); i++ {
+			// two word techniques such as stir-fry win over their
+			// last word on its own
+			if i+1 < len(stems) {
+				if t, ok := techniques[stems[i]+" "+stems[i+1]]; ok {
+					if !slices.Contains(found, t) {
+						found = append(found, t)
+					}
+					i++
+					continue
+				}
+			}
+			if t, ok := techniques[stems[i]]; ok && !slices.Contains(found, t) {
+				found = append(found, t)
+			}
+		}
+	}
+	for _, p := range r.prepSteps {
+		add(p.Action())
+	}
+	for _, s := range r.steps {
+		add(s.Action())
+	}
+	return found
+}
+
+// distinctiveTechniques are the recipe's techniques but the common
+// ones, keyed by themselves.
+func (r Recipe) distinctiveTechniques() map[string]string {
+	found := make(map[string]string)
+	for _, t := range r.Techniques() {
+		if !slices.Contains(commonTechniques, t) {
+			found[t] = t
+		}
+	}
+	return found
+}
+
+// Similarity is how alike a recipe is to another, between 0 and 1,
+// and what they have in common.
+type Similarity struct {
+	Recipe            Recipe
+	Score             float64
+	SharedIngredients []string
+	SharedTechniques  []string
+	SameCuisine       bool
+}
+
+// ingredientKeys are the recipe's ingredient names keyed by their
+// stems, so "Tomatoes" and "tomato" count as the same ingredient.
+func (r Recipe) ingredientKeys() map[string]string {
+	keys := make(map[string]string, len(r.ingredients))
+	for _, l := range r.ingredients {
+		name := l.Ingredient().Name
+		keys[strings.Join(search.Stems(name), " ")] = name
+	}
+	return keys
+}
+
+// Traits are what a recipe has to share with another to be similar to
+// it beyond their cuisine: the stems of its ingredient names and the
+// techniques of its steps but the common ones. Repositories store them to find candidates
+// for Similar without reading every recipe.
+type Traits struct {
+	Ingredients []string
+	Techniques  []string
+}
+
+func (r Recipe) Traits() Traits {
+	var t Traits
+	for k := range r.ingredientKeys() {
+		t.Ingredients = append(t.Ingredients, k)
+	}
+	sort.Strings(t.Ingredients)
+	for _, technique := range r.Techniques() {
+		if !slices.Contains(commonTechniques, technique) {
+			t.Techniques = append(t.Techniques, technique)
+		}
+	}
+	return t
+}
+
+// Empty reports whether t has no ingredients or techniques at all.
+func (t Traits) Empty() bool {
+	return len(t.Ingredients) == 0 && len(t.Techniques) == 0
+}
+
+// sharedBy reports whether r has at least one of the ingredients or
+// techniques of t.
+func (t Traits) sharedBy(r Recipe) bool {
+	other := r.Traits()
+	return slices.ContainsFunc(t.Ingredients, func(k string) bool {
+		return slices.Contains(other.Ingredients, k)
+	}) || slices.ContainsFunc(t.Techniques, func(k string) bool {
+		return slices.Contains(other.Techniques, k)
+	})
+}
+
+// jaccard is the size of the intersection of a and b over the size of
+// their union, and the intersection as named in a.
+func jaccard(a, b map[string]string) (float64, []string) {
+	if len(a) == 0 && len(b) == 0 {
+		return 0, nil
+	}
+	var shared []string
+	for k, v := range a {
+		if _, ok := b[k]; ok {
+			shared = append(shared, v)
+		}
+	}
+	sort.Strings(shared)
+	return float64(len(shared)) / float64(len(a)+len(b)-len(shared)), shared
+}
+
+// Similar ranks candidates by how alike they are to r, most similar
+// first and then by name, and returns up to limit of them. r itself,
+// deleted recipes and recipes with nothing in common with r are left
+// out. Candidates only need to hold the recipes sharing r's traits and
+// the first limit of the others of r's cuisine by name, as the rest
+// cannot make it into the result.
+func Similar(r Recipe, candidates []Recipe, limit int) []Similarity {
+	ingredients := r.ingredientKeys()
+	techniques := r.distinctiveTechniques()
+
+	var res []Similarity
+	seen := make(map[uuid.UUID]bool)
+	for _, c := range candidates {
+		if c.ID() == r.ID() || c.Deleted() || seen[c.ID()] {
+			continue
+		}
+		seen[c.ID()] = true
+		// an unknown cuisine says nothing about either recipe
+		s := Similarity{Recipe: c, SameCuisine: r.Cuisine() != domain.UnknownCuisine && c.Cuisine() == r.Cuisine()}
+		ingredientScore, sharedIngredients := jaccard(ingredients, c.ingredientKeys())
+		techniqueScore, sharedTechniques := jaccard(techniques, c.distinctiveTechniques())
+		s.SharedIngredients, s.SharedTechniques = sharedIngredients, sharedTechniques
+		s.Score = ingredientSimilarity*ingredientScore + techniqueSimilarity*techniqueScore
+		if s.SameCuisine {
+			s.Score += cuisineSimilarity
+		}
+		if s.Score > 0 {
+			res = append(res, s)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b Similarity) int {
+		if a.Score != b.Score {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		return byName(a.Recipe, b.Recipe)
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
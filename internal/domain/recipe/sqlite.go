@@ -0,0 +1,929 @@
+package recipe
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/db"
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/search"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var migrations = []db.Migration{
+	db.Exec(`CREATE TABLE recipes (
+		id         TEXT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		cuisine    INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER,
+		deleted_at INTEGER,
+		document   BLOB NOT NULL
+	)`),
+	db.Exec(`CREATE INDEX recipes_deleted_at ON recipes (deleted_at) WHERE deleted_at IS NOT NULL`),
+	migrateListing,
+	migrateSearch,
+	migrateFacets,
+	db.Exec(`ALTER TABLE recipes ADD COLUMN version INTEGER NOT NULL DEFAULT 0`),
+	migrateTraits,
+	migratePairings,
+	migrateIngredients,
+	reindexTraits,
+}
+
+// migrateListing adds what listing filters and sorts on: when a recipe
+// last changed and which ingredient types it contains. The types only
+// live inside the stored documents, so existing rows are backfilled
+// by decoding them.
+func migrateListing(ctx context.Context, tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`ALTER TABLE recipes ADD COLUMN modified_at INTEGER NOT NULL DEFAULT 0`,
+		`UPDATE recipes SET modified_at = COALESCE(updated_at, created_at)`,
+		`CREATE INDEX recipes_created_at ON recipes (created_at, id)`,
+		`CREATE INDEX recipes_modified_at ON recipes (modified_at, id)`,
+		`CREATE INDEX recipes_name ON recipes (name, id)`,
+		`CREATE TABLE recipe_ingredient_types (
+			recipe_id TEXT NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+			type      INTEGER NOT NULL,
+			PRIMARY KEY (recipe_id, type)
+		)`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	recipes, err := readRecipes(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, r := range recipes {
+		if err := writeIngredientTypes(ctx, tx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSearch adds the full-text index. Its columns follow the fields
+// of searchDocument; the porter tokenizer stems both what is indexed
+// and what is searched for. Hard deleted recipes are dropped from it
+// by a trigger.
+func migrateSearch(ctx context.Context, tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`CREATE VIRTUAL TABLE recipe_search USING fts5 (
+			id UNINDEXED, name, description, ingredients, steps,
+			tokenize = 'porter unicode61'
+		)`,
+		`CREATE TRIGGER recipes_search_delete AFTER DELETE ON recipes BEGIN
+			DELETE FROM recipe_search WHERE id = old.id;
+		END`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	recipes, err := readRecipes(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, r := range recipes {
+		if err := writeSearch(ctx, tx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateFacets adds the total time and dietary tags that listing can
+// now filter and count on, backfilled from the documents.
+func migrateFacets(ctx context.Context, tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`ALTER TABLE recipes ADD COLUMN total_time INTEGER NOT NULL DEFAULT 0`,
+		`CREATE INDEX recipes_total_time ON recipes (total_time)`,
+		`CREATE TABLE recipe_dietary_tags (
+			recipe_id TEXT NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+			tag       INTEGER NOT NULL,
+			PRIMARY KEY (recipe_id, tag)
+		)`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	recipes, err := readRecipes(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, r := range recipes {
+		if _, err := tx.ExecContext(ctx, `UPDATE recipes SET total_time = ? WHERE id = ?`, int64(r.TotalTime()/time.Second), r.ID().String()); err != nil {
+			return err
+		}
+		if err := writeDietaryTags(ctx, tx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTraits adds the ingredients and techniques similar recipes
+// are found by, backfilled from the documents.
+func migrateTraits(ctx context.Context, tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`CREATE TABLE recipe_ingredient_keys (
+			recipe_id TEXT NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+			key       TEXT NOT NULL,
+			PRIMARY KEY (recipe_id, key)
+		)`,
+		`CREATE INDEX recipe_ingredient_keys_key ON recipe_ingredient_keys (key)`,
+		`CREATE TABLE recipe_techniques (
+			recipe_id TEXT NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+			technique TEXT NOT NULL,
+			PRIMARY KEY (recipe_id, technique)
+		)`,
+		`CREATE INDEX recipe_techniques_technique ON recipe_techniques (technique)`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	recipes, err := readRecipes(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, r := range recipes {
+		if err := writeTraits(ctx, tx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migratePairings adds which recipes each recipe is paired with, so
+// the recipes paired with a given one are found without decoding every
+// document.
+func migratePairings(ctx context.Context, tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`CREATE TABLE recipe_pairings (
+			recipe_id TEXT NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+			with_id   TEXT NOT NULL,
+			PRIMARY KEY (recipe_id, with_id)
+		)`,
+		`CREATE INDEX recipe_pairings_with_id ON recipe_pairings (with_id)`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	recipes, err := readRecipes(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, r := range recipes {
+		if err := writePairings(ctx, tx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateIngredients adds which ingredients each recipe has a line
+// for, so the recipes using an ingredient are found without decoding
+// every document.
+func migrateIngredients(ctx context.Context, tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`CREATE TABLE recipe_ingredients (
+			recipe_id     TEXT NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+			ingredient_id TEXT NOT NULL,
+			PRIMARY KEY (recipe_id, ingredient_id)
+		)`,
+		`CREATE INDEX recipe_ingredients_ingredient_id ON recipe_ingredients (ingredient_id)`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	recipes, err := readRecipes(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, r := range recipes {
+		if err := writeIngredients(ctx, tx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reindexTraits rewrites the traits of every recipe, for when what
+// counts as one changes.
+func reindexTraits(ctx context.Context, tx *sql.Tx) error {
+	recipes, err := readRecipes(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, r := range recipes {
+		if err := writeTraits(ctx, tx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRecipes decodes every stored recipe, for migrations that have
+// to backfill from the documents.
+func readRecipes(ctx context.Context, tx *sql.Tx) ([]Recipe, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT document FROM recipes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recipes []Recipe
+	for rows.Next() {
+		var document []byte
+		if err := rows.Scan(&document); err != nil {
+			return nil, err
+		}
+		doc, err := decodeRecipe(document)
+		if err != nil {
+			return nil, err
+		}
+		r, err := doc.ToRecipe()
+		if err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, r)
+	}
+	return recipes, rows.Err()
+}
+
+// writeSearch replaces the text indexed for r. Deleted recipes are
+// not searchable so they are only removed.
+func writeSearch(ctx context.Context, tx *sql.Tx, r Recipe) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recipe_search WHERE id = ?`, r.ID().String()); err != nil {
+		return err
+	}
+	if r.Deleted() {
+		return nil
+	}
+	doc := searchDocument(r)
+	args := []any{doc.ID}
+	for _, f := range doc.Fields {
+		args = append(args, f.Text)
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO recipe_search (id, name, description, ingredients, steps) VALUES (?, ?, ?, ?, ?)`, args...)
+	return err
+}
+
+// writeDietaryTags replaces the dietary tags indexed for r.
+func writeDietaryTags(ctx context.Context, tx *sql.Tx, r Recipe) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recipe_dietary_tags WHERE recipe_id = ?`, r.ID().String()); err != nil {
+		return err
+	}
+	for _, t := range r.dietaryTags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recipe_dietary_tags (recipe_id, tag) VALUES (?, ?)`, r.ID().String(), int(t)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIngredientTypes replaces the ingredient types indexed for r.
+func writeIngredientTypes(ctx context.Context, tx *sql.Tx, r Recipe) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recipe_ingredient_types WHERE recipe_id = ?`, r.ID().String()); err != nil {
+		return err
+	}
+	for _, l := range r.ingredients {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO recipe_ingredient_types (recipe_id, type) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+			r.ID().String(),
+			int(l.Ingredient().Type),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTraits replaces the ingredients and techniques indexed for r.
+func writeTraits(ctx context.Context, tx *sql.Tx, r Recipe) error {
+	for _, stmt := range []string{
+		`DELETE FROM recipe_ingredient_keys WHERE recipe_id = ?`,
+		`DELETE FROM recipe_techniques WHERE recipe_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, r.ID().String()); err != nil {
+			return err
+		}
+	}
+	traits := r.Traits()
+	for _, k := range traits.Ingredients {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recipe_ingredient_keys (recipe_id, key) VALUES (?, ?)`, r.ID().String(), k); err != nil {
+			return err
+		}
+	}
+	for _, t := range traits.Techniques {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recipe_techniques (recipe_id, technique) VALUES (?, ?)`, r.ID().String(), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePairings replaces the recipes indexed as paired with r.
+func writePairings(ctx context.Context, tx *sql.Tx, r Recipe) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recipe_pairings WHERE recipe_id = ?`, r.ID().String()); err != nil {
+		return err
+	}
+	for _, p := range r.pairings {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO recipe_pairings (recipe_id, with_id) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+			r.ID().String(),
+			p.With().String(),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIngredients replaces the ingredients indexed for r.
+func writeIngredients(ctx context.Context, tx *sql.Tx, r Recipe) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recipe_ingredients WHERE recipe_id = ?`, r.ID().String()); err != nil {
+		return err
+	}
+	for _, id := range r.ingredientIDs() {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO recipe_ingredients (recipe_id, ingredient_id) VALUES (?, ?)`,
+			r.ID().String(),
+			id.String(),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SQLiteRepository keeps each recipe as the same versioned document
+// the Mongo repository stores, with the fields needed for querying
+// copied into their own columns.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{
+		db: db,
+	}
+}
+
+// Migrate brings the schema up to date.
+func (sr *SQLiteRepository) Migrate(ctx context.Context) error {
+	return db.Migrate(ctx, sr.db, "schema_migrations", migrations)
+}
+
+func nullableMilli(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixMilli(), Valid: true}
+}
+
+func (sr *SQLiteRepository) Get(ctx context.Context, id uuid.UUID) (Recipe, error) {
+	var document []byte
+	err := sr.db.QueryRowContext(ctx, `SELECT document FROM recipes WHERE id = ?`, id.String()).Scan(&document)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Recipe{}, ErrRecipeNotFound
+		}
+		return Recipe{}, err
+	}
+	doc, err := decodeRecipe(document)
+	if err != nil {
+		return Recipe{}, err
+	}
+	return doc.ToRecipe()
+}
+
+// write runs fn in a transaction and, if it changed a row, reindexes
+// the recipe's ingredients, ingredient types, dietary tags, text,
+// traits and pairings in the same transaction.
+func (sr *SQLiteRepository) write(ctx context.Context, recipe Recipe, fn func(*sql.Tx) (sql.Result, error)) (int64, error) {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := fn(tx)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if err := writeIngredientTypes(ctx, tx, recipe); err != nil {
+		return 0, err
+	}
+	if err := writeDietaryTags(ctx, tx, recipe); err != nil {
+		return 0, err
+	}
+	if err := writeSearch(ctx, tx, recipe); err != nil {
+		return 0, err
+	}
+	if err := writeTraits(ctx, tx, recipe); err != nil {
+		return 0, err
+	}
+	if err := writePairings(ctx, tx, recipe); err != nil {
+		return 0, err
+	}
+	if err := writeIngredients(ctx, tx, recipe); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+func (sr *SQLiteRepository) Add(ctx context.Context, recipe Recipe) error {
+	document, err := bson.Marshal(recipeFromRecipe(recipe))
+	if err != nil {
+		return err
+	}
+	n, err := sr.write(ctx, recipe, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.ExecContext(
+			ctx,
+			`INSERT INTO recipes (id, name, cuisine, created_at, updated_at, deleted_at, modified_at, total_time, version, document)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING`,
+			recipe.ID().String(),
+			recipe.Name(),
+			int(recipe.Cuisine()),
+			recipe.createdAt.UnixMilli(),
+			nullableMilli(recipe.updatedAt),
+			nullableMilli(recipe.deletedAt),
+			recipe.modifiedAt().UnixMilli(),
+			int64(recipe.TotalTime()/time.Second),
+			recipe.version,
+			document,
+		)
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecipeExists
+	}
+	return nil
+}
+
+// Update replaces the stored recipe only while it is still at the
+// version the recipe was read at.
+func (sr *SQLiteRepository) Update(ctx context.Context, recipe Recipe) (Recipe, error) {
+	next := recipe
+	next.version++
+	document, err := bson.Marshal(recipeFromRecipe(next))
+	if err != nil {
+		return Recipe{}, err
+	}
+	n, err := sr.write(ctx, next, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.ExecContext(
+			ctx,
+			`UPDATE recipes
+			SET name = ?, cuisine = ?, updated_at = ?, deleted_at = ?, modified_at = ?, total_time = ?, version = ?, document = ?
+			WHERE id = ? AND version = ?`,
+			next.Name(),
+			int(next.Cuisine()),
+			nullableMilli(next.updatedAt),
+			nullableMilli(next.deletedAt),
+			next.modifiedAt().UnixMilli(),
+			int64(next.TotalTime()/time.Second),
+			next.version,
+			document,
+			next.ID().String(),
+			recipe.version,
+		)
+	})
+	if err != nil {
+		return Recipe{}, err
+	}
+	if n == 0 {
+		var exists bool
+		if err := sr.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM recipes WHERE id = ?)`, recipe.ID().String()).Scan(&exists); err != nil {
+			return Recipe{}, err
+		}
+		if exists {
+			return Recipe{}, ErrRecipeConflict
+		}
+		return Recipe{}, ErrRecipeNotFound
+	}
+	return next, nil
+}
+
+func (sr *SQLiteRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := sr.db.ExecContext(ctx, `DELETE FROM recipes WHERE id = ?`, id.String())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecipeNotFound
+	}
+	return nil
+}
+
+func (sr *SQLiteRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	res, err := sr.db.ExecContext(ctx, `DELETE FROM recipes WHERE deleted_at IS NOT NULL AND deleted_at < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func sqliteSortColumn(s SortField) string {
+	switch s {
+	case SortByName:
+		return "name"
+	case SortByUpdatedAt:
+		return "modified_at"
+	default:
+		return "created_at"
+	}
+}
+
+// sqliteFilter returns the conditions, to be joined with AND, and
+// their arguments that select the recipes matching the filters of q.
+func sqliteFilter(q Query) ([]string, []any) {
+	var where []string
+	if !q.IncludeDeleted {
+		where = append(where, "deleted_at IS NULL")
+	}
+	var args []any
+	if len(q.Cuisines) > 0 {
+		where = append(where, "cuisine IN ("+placeholders(len(q.Cuisines))+")")
+		for _, c := range q.Cuisines {
+			args = append(args, int(c))
+		}
+	}
+	if len(q.IngredientTypes) > 0 {
+		where = append(where, "id IN (SELECT recipe_id FROM recipe_ingredient_types WHERE type IN ("+placeholders(len(q.IngredientTypes))+"))")
+		for _, t := range q.IngredientTypes {
+			args = append(args, int(t))
+		}
+	}
+	for _, r := range []struct {
+		column string
+		after  time.Time
+		before time.Time
+	}{
+		{"created_at", q.CreatedAfter, q.CreatedBefore},
+		{"modified_at", q.UpdatedAfter, q.UpdatedBefore},
+	} {
+		if !r.after.IsZero() {
+			where = append(where, r.column+" >= ?")
+			args = append(args, r.after.UnixMilli())
+		}
+		if !r.before.IsZero() {
+			where = append(where, r.column+" < ?")
+			args = append(args, r.before.UnixMilli())
+		}
+	}
+	for _, t := range q.DietaryTags {
+		where = append(where, "id IN (SELECT recipe_id FROM recipe_dietary_tags WHERE tag = ?)")
+		args = append(args, int(t))
+	}
+	if len(q.TotalTimes) > 0 {
+		ranges := make([]string, 0, len(q.TotalTimes))
+		for _, b := range q.TotalTimes {
+			from, to := b.bounds()
+			if to == 0 {
+				ranges = append(ranges, "total_time >= ?")
+				args = append(args, int64(from/time.Second))
+				continue
+			}
+			ranges = append(ranges, "(total_time >= ? AND total_time < ?)")
+			args = append(args, int64(from/time.Second), int64(to/time.Second))
+		}
+		where = append(where, "("+strings.Join(ranges, " OR ")+")")
+	}
+	if !q.Sharing.Empty() {
+		where = append(where, "(id IN (SELECT recipe_id FROM recipe_ingredient_keys WHERE key IN ("+placeholders(len(q.Sharing.Ingredients))+
+			")) OR id IN (SELECT recipe_id FROM recipe_techniques WHERE technique IN ("+placeholders(len(q.Sharing.Techniques))+")))")
+		for _, k := range q.Sharing.Ingredients {
+			args = append(args, k)
+		}
+		for _, t := range q.Sharing.Techniques {
+			args = append(args, t)
+		}
+	}
+	if len(q.IDs) > 0 {
+		where = append(where, "id IN ("+placeholders(len(q.IDs))+")")
+		for _, id := range q.IDs {
+			args = append(args, id.String())
+		}
+	}
+	if len(q.Ingredients) > 0 {
+		where = append(where, "id IN (SELECT recipe_id FROM recipe_ingredients WHERE ingredient_id IN ("+placeholders(len(q.Ingredients))+"))")
+		for _, id := range q.Ingredients {
+			args = append(args, id.String())
+		}
+	}
+	if len(q.PairedWith) > 0 {
+		where = append(where, "id IN (SELECT recipe_id FROM recipe_pairings WHERE with_id IN ("+placeholders(len(q.PairedWith))+"))")
+		for _, id := range q.PairedWith {
+			args = append(args, id.String())
+		}
+	}
+	return where, args
+}
+
+// whereClause joins conditions from sqliteFilter into a WHERE clause,
+// which is empty when there are none.
+func whereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(where, " AND ")
+}
+
+func (sr *SQLiteRepository) List(ctx context.Context, q Query) (Page, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+	after, hasCursor, err := decodeCursor(q)
+	if err != nil {
+		return Page{}, err
+	}
+
+	where, args := sqliteFilter(q)
+
+	column := sqliteSortColumn(q.Sort)
+	direction, op := "ASC", ">"
+	if q.Descending {
+		direction, op = "DESC", "<"
+	}
+	if hasCursor {
+		var value any = after.Time
+		if q.Sort == SortByName {
+			value = after.Name
+		}
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op))
+		args = append(args, value, value, after.ID)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT document FROM recipes %s ORDER BY %s %s, id %s LIMIT ?",
+		whereClause(where), column, direction, direction,
+	)
+	args = append(args, q.Limit+1)
+	rows, err := sr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	defer rows.Close()
+
+	var recipes []Recipe
+	for rows.Next() {
+		var document []byte
+		if err := rows.Scan(&document); err != nil {
+			return Page{}, err
+		}
+		doc, err := decodeRecipe(document)
+		if err != nil {
+			return Page{}, err
+		}
+		r, err := doc.ToRecipe()
+		if err != nil {
+			return Page{}, err
+		}
+		recipes = append(recipes, r)
+	}
+	if err := rows.Err(); err != nil {
+		return Page{}, err
+	}
+	return pageOf(recipes, q), nil
+}
+
+// sqliteTimeBucket is BucketOf as an SQL expression over total_time.
+func sqliteTimeBucket() string {
+	var sb strings.Builder
+	sb.WriteString("CASE WHEN total_time <= 0 THEN 0")
+	for i, bound := range timeBucketBounds {
+		fmt.Fprintf(&sb, " WHEN total_time < %d THEN %d", int64(bound/time.Second), i+1)
+	}
+	fmt.Fprintf(&sb, " ELSE %d END", Over2Hours)
+	return sb.String()
+}
+
+// Facets runs one GROUP BY per kind of facet over the recipes matching
+// q, all within a read transaction so the counts agree.
+func (sr *SQLiteRepository) Facets(ctx context.Context, q Query) (Facets, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return Facets{}, err
+	}
+	where, args := sqliteFilter(q)
+	matching := "SELECT id FROM recipes " + whereClause(where)
+
+	tx, err := sr.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return Facets{}, err
+	}
+	defer tx.Rollback()
+
+	f := newFacets()
+	for _, facet := range []struct {
+		query string
+		add   func(value int64, count int)
+	}{
+		{
+			"SELECT cuisine, COUNT(*) FROM recipes WHERE id IN (" + matching + ") GROUP BY cuisine",
+			func(v int64, n int) { f.Cuisines[domain.CuisineType(v)] += n },
+		},
+		{
+			"SELECT " + sqliteTimeBucket() + " AS bucket, COUNT(*) FROM recipes WHERE id IN (" + matching + ") AND total_time > 0 GROUP BY bucket",
+			func(v int64, n int) { f.TotalTimes[TimeBucket(v)] += n },
+		},
+		{
+			"SELECT tag, COUNT(*) FROM recipe_dietary_tags WHERE recipe_id IN (" + matching + ") GROUP BY tag",
+			func(v int64, n int) { f.DietaryTags[domain.DietaryTag(v)] += n },
+		},
+	} {
+		rows, err := tx.QueryContext(ctx, facet.query, args...)
+		if err != nil {
+			return Facets{}, err
+		}
+		for rows.Next() {
+			var value int64
+			var count int
+			if err := rows.Scan(&value, &count); err != nil {
+				rows.Close()
+				return Facets{}, err
+			}
+			facet.add(value, count)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return Facets{}, err
+		}
+	}
+	if err := typeSetFacets(ctx, tx, f, matching, args); err != nil {
+		return Facets{}, err
+	}
+	return f, nil
+}
+
+// typeSetFacets counts the matching recipes by the set of ingredient
+// types they have, which the per type counts are added up from.
+func typeSetFacets(ctx context.Context, tx *sql.Tx, f Facets, matching string, args []any) error {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT types, COUNT(*) FROM (
+			SELECT group_concat(type, ',' ORDER BY type) AS types FROM recipe_ingredient_types
+			WHERE recipe_id IN (`+matching+`) GROUP BY recipe_id
+		) GROUP BY types`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return err
+		}
+		types, err := parseTypeSet(key)
+		if err != nil {
+			return err
+		}
+		f.addTypes(types, count)
+	}
+	return rows.Err()
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// ftsMatch is q as an FTS5 query. Words only hold letters and digits
+// so quoting them is enough to keep them from being read as syntax.
+func ftsMatch(q search.Query) string {
+	parts := make([]string, 0, len(q.Terms)+len(q.Phrases))
+	for _, t := range q.Terms {
+		parts = append(parts, `"`+t.Word+`"`)
+	}
+	for _, p := range q.Phrases {
+		words := make([]string, 0, len(p))
+		for _, t := range p {
+			words = append(words, t.Word)
+		}
+		parts = append(parts, `"`+strings.Join(words, " ")+`"`)
+	}
+	for _, group := range q.Synonyms {
+		alts := make([]string, 0, len(group))
+		for _, alt := range group {
+			words := make([]string, 0, len(alt))
+			for _, t := range alt {
+				words = append(words, `"`+t.Word+`"`)
+			}
+			alts = append(alts, "("+strings.Join(words, " AND ")+")")
+		}
+		parts = append(parts, "("+strings.Join(alts, " OR ")+")")
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// Search ranks with FTS5's bm25, which is lower for better matches, so
+// the score is negated to read like the other repositories'.
+func (sr *SQLiteRepository) Search(ctx context.Context, q search.Query, limit int) ([]SearchHit, error) {
+	var hits []SearchHit
+	err := sr.searchMatches(ctx, q, func(r Recipe, score float64) bool {
+		hits = append(hits, searchHit(r, score, q))
+		return len(hits) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// SearchFacets counts the facets of every recipe matching q. They are
+// counted here rather than with GROUP BY as matches have to be checked
+// again with search.Match.
+func (sr *SQLiteRepository) SearchFacets(ctx context.Context, q search.Query) (Facets, error) {
+	f := newFacets()
+	err := sr.searchMatches(ctx, q, func(r Recipe, _ float64) bool {
+		f.add(r)
+		return true
+	})
+	if err != nil {
+		return Facets{}, err
+	}
+	return f, nil
+}
+
+// searchMatches calls fn with the recipes matching q, best first,
+// until it returns false. The porter stemmer is more aggressive than
+// search.Stem so matches are checked again with search.Match.
+func (sr *SQLiteRepository) searchMatches(ctx context.Context, q search.Query, fn func(Recipe, float64) bool) error {
+	rank := fmt.Sprintf("bm25(recipe_search, 0, %d, %d, %d, %d)", nameWeight, descriptionWeight, ingredientsWeight, stepsWeight)
+	rows, err := sr.db.QueryContext(
+		ctx,
+		`SELECT r.document, -`+rank+`
+		FROM recipe_search JOIN recipes r ON r.id = recipe_search.id
+		WHERE recipe_search MATCH ?
+		ORDER BY `+rank+`, r.id`,
+		ftsMatch(q),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var document []byte
+		var score float64
+		if err := rows.Scan(&document, &score); err != nil {
+			return err
+		}
+		doc, err := decodeRecipe(document)
+		if err != nil {
+			return err
+		}
+		r, err := doc.ToRecipe()
+		if err != nil {
+			return err
+		}
+		if !search.Match(searchDocument(r), q) {
+			continue
+		}
+		if !fn(r, score) {
+			break
+		}
+	}
+	return rows.Err()
+}
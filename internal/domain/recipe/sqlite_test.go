@@ -0,0 +1,26 @@
+package recipe
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bento01dev/cookbook/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) repository {
+		sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "cookbook.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			sqlDB.Close()
+		})
+
+		repo := NewSQLiteRepository(sqlDB)
+		require.NoError(t, repo.Migrate(context.Background()))
+		// migrating an up to date schema is a no-op
+		require.NoError(t, repo.Migrate(context.Background()))
+		return repo
+	})
+}
@@ -0,0 +1,448 @@
+package recipe
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+)
+
+// Planning takes time quadratic in the number of steps, so meals are
+// kept to what one cook could manage anyway.
+const (
+	MaxMealRecipes = 12
+	MaxMealSteps   = 200
+)
+
+var (
+	ErrMealTooLarge    = fmt.Errorf("meal cannot have more than %d recipes or %d steps", MaxMealRecipes, MaxMealSteps)
+	ErrEmptyMeal       = errors.New("meal needs at least one recipe")
+	ErrDuplicateRecipe = errors.New("meal lists a recipe more than once")
+	ErrInvalidKitchen  = errors.New("kitchen needs at least one oven and one burner")
+)
+
+// Kitchen is the equipment cooking steps share. Each oven holds one
+// temperature at a time, so steps at different temperatures cannot be
+// in the same oven together; each burner of the hob takes one step.
+type Kitchen struct {
+	Ovens   int
+	Burners int
+}
+
+var DefaultKitchen = Kitchen{Ovens: 1, Burners: 4}
+
+func (k Kitchen) validate() error {
+	if k.Ovens < 1 || k.Burners < 1 {
+		return ErrInvalidKitchen
+	}
+	return nil
+}
+
+// ScheduledStep is a cooking step placed on a timeline, starting and
+// ending at offsets from when cooking starts.
+type ScheduledStep struct {
+	Step  domain.Step
+	Start time.Duration
+	End   time.Duration
+	// Critical steps are on the path that decides how long cooking
+	// takes: starting any of them later makes the whole recipe later.
+	Critical bool
+}
+
+// Timeline is a schedule for the cooking steps of a recipe.
+type Timeline struct {
+	// Steps are in recipe order, which is not necessarily the order
+	// they start in.
+	Steps   []ScheduledStep
+	Elapsed time.Duration
+	HandsOn time.Duration
+}
+
+// Timeline schedules the recipe's cooking steps for a single cook in
+// the default kitchen, every step as early as it can go. See planner
+// for how steps are placed.
+func (r Recipe) Timeline() Timeline {
+	p := newPlanner(DefaultKitchen)
+	p.add(r)
+	// nothing to cancel, so there is no error either
+	_ = p.forward(context.Background())
+	p.markCritical()
+	return Timeline{Steps: p.slots, Elapsed: p.elapsed(), HandsOn: p.handsOn()}
+}
+
+// MealStep is a step of one of the recipes of a meal.
+type MealStep struct {
+	ScheduledStep
+	Recipe Recipe
+}
+
+// Meal is one plan for cooking several recipes together so they are
+// all ready at the same time.
+type Meal struct {
+	StartAt time.Time
+	ServeAt time.Time
+	// Steps are in the order they start in, steps starting together in
+	// the order the recipes were given.
+	Steps   []MealStep
+	Elapsed time.Duration
+	HandsOn time.Duration
+}
+
+// PlanMeal interleaves the cooking steps of recipes for a single cook
+// in the given kitchen so that cooking is over by serveAt. Steps are
+// first placed as early as they can go, which decides how long cooking
+// takes, and then each is moved as late as it can go, so that nothing
+// is ready long before it is served. Planning stops early with the
+// error of ctx once it is done.
+func PlanMeal(ctx context.Context, recipes []Recipe, serveAt time.Time, kitchen Kitchen) (Meal, error) {
+	if len(recipes) == 0 {
+		return Meal{}, ErrEmptyMeal
+	}
+	if len(recipes) > MaxMealRecipes {
+		return Meal{}, ErrMealTooLarge
+	}
+	if err := kitchen.validate(); err != nil {
+		return Meal{}, err
+	}
+	p := newPlanner(kitchen)
+	owner := make([]int, 0)
+	for i, r := range recipes {
+		for _, o := range recipes[:i] {
+			if o.ID() == r.ID() {
+				return Meal{}, ErrDuplicateRecipe
+			}
+		}
+		p.add(r)
+		for range r.steps {
+			owner = append(owner, i)
+		}
+	}
+	if len(p.steps) > MaxMealSteps {
+		return Meal{}, ErrMealTooLarge
+	}
+	if err := p.forward(ctx); err != nil {
+		return Meal{}, err
+	}
+	p.markCritical()
+	if err := p.justify(ctx, p.elapsed()); err != nil {
+		return Meal{}, err
+	}
+	elapsed := p.shift()
+
+	m := Meal{
+		StartAt: serveAt.Add(-elapsed),
+		ServeAt: serveAt,
+		Steps:   make([]MealStep, 0, len(p.slots)),
+		Elapsed: elapsed,
+		HandsOn: p.handsOn(),
+	}
+	for i, s := range p.slots {
+		m.Steps = append(m.Steps, MealStep{ScheduledStep: s, Recipe: recipes[owner[i]]})
+	}
+	slices.SortStableFunc(m.Steps, func(a, b MealStep) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	return m, nil
+}
+
+// planner places cooking steps on a timeline. A step starts once the
+// steps it depends on are finished, and for its hands-on part once the
+// cook is free; its passive part needs no attention, so the cook moves
+// on to other steps meanwhile. A step using equipment holds it for its
+// whole duration.
+type planner struct {
+	kitchen Kitchen
+	steps   []domain.Step
+	// after holds the dependencies of every step as indexes into steps
+	after  [][]int
+	slots  []ScheduledStep
+	placed []bool
+}
+
+func newPlanner(kitchen Kitchen) *planner {
+	return &planner{kitchen: kitchen}
+}
+
+// add appends the cooking steps of r.
+func (p *planner) add(r Recipe) {
+	offset := len(p.steps)
+	for _, s := range r.steps {
+		after := make([]int, 0, len(s.After()))
+		for _, v := range s.After() {
+			after = append(after, offset+v)
+		}
+		p.steps = append(p.steps, s)
+		p.after = append(p.after, after)
+		p.slots = append(p.slots, ScheduledStep{Step: s})
+		p.placed = append(p.placed, false)
+	}
+}
+
+func (p *planner) elapsed() time.Duration {
+	var elapsed time.Duration
+	for _, s := range p.slots {
+		elapsed = max(elapsed, s.End)
+	}
+	return elapsed
+}
+
+func (p *planner) handsOn() time.Duration {
+	var handsOn time.Duration
+	for _, s := range p.steps {
+		handsOn += s.Active()
+	}
+	return handsOn
+}
+
+func overlaps(start, end, otherStart, otherEnd time.Duration) bool {
+	return start < otherEnd && otherStart < end
+}
+
+// fits reports whether step i can start at start next to the steps
+// placed so far.
+func (p *planner) fits(i int, start time.Duration) bool {
+	s := p.steps[i]
+	end := start + s.Duration()
+	var shared []ScheduledStep
+	for j, o := range p.slots {
+		if j == i || !p.placed[j] {
+			continue
+		}
+		if s.Active() > 0 && o.Step.Active() > 0 && overlaps(start, start+s.Active(), o.Start, o.Start+o.Step.Active()) {
+			return false
+		}
+		if s.Equipment() != domain.NoEquipment && o.Step.Equipment() == s.Equipment() && overlaps(start, end, o.Start, o.End) {
+			shared = append(shared, o)
+		}
+	}
+	if len(shared) == 0 {
+		return true
+	}
+
+	// what is in use only changes where another step starts
+	points := []time.Duration{start}
+	for _, o := range shared {
+		if o.Start > start {
+			points = append(points, o.Start)
+		}
+	}
+	for _, t := range points {
+		inUse := 1
+		temperatures := map[float64]bool{ovenSetting(s): true}
+		for _, o := range shared {
+			if o.Start <= t && t < o.End {
+				inUse++
+				temperatures[ovenSetting(o.Step)] = true
+			}
+		}
+		// an oven step without a temperature goes along with any
+		delete(temperatures, 0)
+		switch s.Equipment() {
+		case domain.Hob:
+			if inUse > p.kitchen.Burners {
+				return false
+			}
+		case domain.Oven:
+			if len(temperatures) > p.kitchen.Ovens {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ovenSetting is the temperature an oven is set to for a step, in
+// steps of 10°C so that a step given as 350°F shares the oven with one
+// at 180°C.
+func ovenSetting(s domain.Step) float64 {
+	return math.Round(s.Temperature().Celsius()/10) * 10
+}
+
+// starts lists where step i could start: where, for step i, any of
+// the steps placed so far starts, ends, or has the cook free again.
+func (p *planner) starts(i int) []time.Duration {
+	s := p.steps[i]
+	var starts []time.Duration
+	for j, o := range p.slots {
+		if j == i || !p.placed[j] {
+			continue
+		}
+		for _, t := range []time.Duration{o.Start, o.Start + o.Step.Active(), o.End} {
+			starts = append(starts, t, t-s.Active(), t-s.Duration())
+		}
+	}
+	return starts
+}
+
+// forward places every step as early as it can go. Of the steps that
+// could go next, the one that can start earliest does, preferring the
+// one with the longest chain of steps still to follow and then the
+// order the steps were added in.
+func (p *planner) forward(ctx context.Context) error {
+	// tail is how long it takes at least from the start of a step to
+	// the end of the last step depending on it
+	tail := make([]time.Duration, len(p.steps))
+	for i := len(p.steps) - 1; i >= 0; i-- {
+		tail[i] += p.steps[i].Duration()
+		for _, v := range p.after[i] {
+			tail[v] = max(tail[v], tail[i])
+		}
+	}
+
+	for range p.steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		next, start := -1, time.Duration(0)
+		for i := range p.steps {
+			if p.placed[i] {
+				continue
+			}
+			ready, ok := time.Duration(0), true
+			for _, v := range p.after[i] {
+				if !p.placed[v] {
+					ok = false
+					break
+				}
+				ready = max(ready, p.slots[v].End)
+			}
+			if !ok {
+				continue
+			}
+			earliest := p.earliest(i, ready)
+			if next < 0 || earliest < start || (earliest == start && tail[i] > tail[next]) {
+				next, start = i, earliest
+			}
+		}
+		p.place(next, start)
+	}
+	return nil
+}
+
+func (p *planner) earliest(i int, ready time.Duration) time.Duration {
+	starts := append(p.starts(i), ready)
+	slices.Sort(starts)
+	for _, t := range starts {
+		if t >= ready && p.fits(i, t) {
+			return t
+		}
+	}
+	// past the end of everything placed a step always fits
+	return max(ready, p.elapsed())
+}
+
+func (p *planner) place(i int, start time.Duration) {
+	p.slots[i].Start = start
+	p.slots[i].End = start + p.steps[i].Duration()
+	p.placed[i] = true
+}
+
+// justify moves every placed step as late as it can go while
+// finishing by horizon, latest starting steps first so each one moves
+// up against steps that have already moved.
+func (p *planner) justify(ctx context.Context, horizon time.Duration) error {
+	next := make([][]int, len(p.steps))
+	for i, after := range p.after {
+		for _, v := range after {
+			next[v] = append(next[v], i)
+		}
+	}
+	order := make([]int, len(p.steps))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		if c := cmp.Compare(p.slots[b].Start, p.slots[a].Start); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(p.slots[b].End, p.slots[a].End); c != 0 {
+			return c
+		}
+		return cmp.Compare(b, a)
+	})
+
+	for _, i := range order {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s := p.slots[i]
+		latest := horizon - s.Step.Duration()
+		for _, v := range next[i] {
+			latest = min(latest, p.slots[v].Start-s.Step.Duration())
+		}
+		starts := append(p.starts(i), latest)
+		slices.Sort(starts)
+		for k := len(starts) - 1; k >= 0; k-- {
+			t := starts[k]
+			if t > latest || t < s.Start {
+				continue
+			}
+			if t == s.Start || p.fits(i, t) {
+				p.place(i, t)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// shift moves every step earlier so that the first one starts at
+// zero, for when justifying has left time before it, and returns how
+// long the steps take now.
+func (p *planner) shift() time.Duration {
+	first := p.elapsed()
+	for _, s := range p.slots {
+		first = min(first, s.Start)
+	}
+	for i := range p.slots {
+		p.slots[i].Start -= first
+		p.slots[i].End -= first
+	}
+	return p.elapsed()
+}
+
+// markCritical walks back from the step that finishes last, through
+// whatever held each step up: a step it depends on or, failing that,
+// the step keeping the cook or the equipment busy.
+func (p *planner) markCritical() {
+	cur := -1
+	for i, s := range p.slots {
+		if cur < 0 || s.End > p.slots[cur].End {
+			cur = i
+		}
+	}
+	for cur >= 0 {
+		p.slots[cur].Critical = true
+		s := p.slots[cur]
+		if s.Start == 0 {
+			return
+		}
+		prev := -1
+		for _, v := range p.after[cur] {
+			if p.slots[v].End == s.Start {
+				prev = v
+				break
+			}
+		}
+		for i, o := range p.slots {
+			if prev >= 0 {
+				break
+			}
+			if i == cur {
+				continue
+			}
+			if s.Step.Active() > 0 && o.Step.Active() > 0 && o.Start+o.Step.Active() == s.Start {
+				prev = i
+			}
+			if s.Step.Equipment() != domain.NoEquipment && o.Step.Equipment() == s.Step.Equipment() && o.End == s.Start {
+				prev = i
+			}
+		}
+		cur = prev
+	}
+}
@@ -0,0 +1,213 @@
+package recipe
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotVariationOf = errors.New("recipe is not a variation of given recipe")
+	ErrHasVariations  = errors.New("recipe still has variations deriving from it")
+	ErrParentDeleted  = errors.New("recipe derives from a deleted recipe")
+	ErrStepInherited  = errors.New("step is inherited from the parent recipe")
+)
+
+// MaxVariationDepth bounds how deep variations of variations may nest.
+const MaxVariationDepth = 8
+
+var ErrVariationTooDeep = fmt.Errorf("variations cannot nest more than %d deep", MaxVariationDepth)
+
+// Override changes one of the ingredient lines a variation inherits
+// from its parent: the line is swapped for another one, or omitted
+// when there is no replacement.
+type Override struct {
+	ingredientID uuid.UUID
+	line         *domain.IngredientLine
+}
+
+func Swap(ingredientID uuid.UUID, line domain.IngredientLine) Override {
+	return Override{ingredientID: ingredientID, line: &line}
+}
+
+func Omit(ingredientID uuid.UUID) Override {
+	return Override{ingredientID: ingredientID}
+}
+
+// IngredientID is the parent ingredient the override applies to.
+func (o Override) IngredientID() uuid.UUID {
+	return o.ingredientID
+}
+
+// Line is the replacement line. ok is false when the ingredient is
+// omitted.
+func (o Override) Line() (line domain.IngredientLine, ok bool) {
+	if o.line == nil {
+		return domain.IngredientLine{}, false
+	}
+	return *o.line, true
+}
+
+// NewVariation creates a recipe deriving from parent. It starts out
+// with the parent's cuisine, servings, times and dietary tags, and
+// inherits the parent's ingredients, prep and steps when resolved.
+// Its own ingredients, prep and steps are added to the inherited ones.
+func NewVariation(parent Recipe, name string, description string) (Recipe, error) {
+	r, err := NewRecipe(name, description, parent.Cuisine(), parent.servings)
+	if err != nil {
+		return Recipe{}, err
+	}
+	r.parent = parent.ID()
+	r.prepTime, r.cookTime = parent.prepTime, parent.cookTime
+	r.dietaryTags = append(r.dietaryTags, parent.dietaryTags...)
+	return r, nil
+}
+
+// Parent is the id of the recipe this one is a variation of, or
+// uuid.Nil.
+func (r Recipe) Parent() uuid.UUID {
+	return r.parent
+}
+
+func (r Recipe) IsVariation() bool {
+	return r.parent != uuid.Nil
+}
+
+func (r Recipe) Overrides() []Override {
+	return r.overrides
+}
+
+// Override records a change to one of parent's ingredients, replacing
+// any earlier override of the same ingredient.
+func (r *Recipe) Override(parent Recipe, o Override) error {
+	if r.parent != parent.ID() {
+		return ErrNotVariationOf
+	}
+	if !parent.hasIngredient(o.ingredientID) {
+		return ErrUnknownIngredient
+	}
+	overrides := make([]Override, 0, len(r.overrides)+1)
+	for _, v := range r.overrides {
+		if v.ingredientID != o.ingredientID {
+			overrides = append(overrides, v)
+		}
+	}
+	r.overrides = append(overrides, o)
+	r.touch()
+	return nil
+}
+
+// Resolve returns the variation as a whole recipe: the parent's
+// ingredient lines with the overrides applied, scaled to the
+// variation's servings, then its own; the parent's prep and steps,
+// dropping those for omitted ingredients and pointing those for
+// swapped ones at the replacement, then its own. parent has to be
+// resolved already when it is a variation itself.
+func (r Recipe) Resolve(parent Recipe) (Recipe, error) {
+	if r.parent != parent.ID() {
+		return Recipe{}, ErrNotVariationOf
+	}
+	inherited := parent
+	if parent.servings > 0 && r.servings > 0 && parent.servings != r.servings {
+		var err error
+		if inherited, err = parent.Scale(r.servings); err != nil {
+			return Recipe{}, err
+		}
+	}
+
+	overrides := make(map[uuid.UUID]Override, len(r.overrides))
+	for _, o := range r.overrides {
+		overrides[o.ingredientID] = o
+	}
+	// replaced maps parent ingredients onto what stands in for them,
+	// uuid.Nil for omitted ones
+	replaced := make(map[uuid.UUID]uuid.UUID)
+
+	lines := make([]domain.IngredientLine, 0, len(inherited.ingredients)+len(r.ingredients))
+	for _, l := range inherited.ingredients {
+		id := l.Ingredient().ID
+		o, ok := overrides[id]
+		if !ok {
+			lines = append(lines, l)
+			continue
+		}
+		if swap, ok := o.Line(); ok {
+			lines = append(lines, swap)
+			replaced[id] = swap.Ingredient().ID
+			continue
+		}
+		replaced[id] = uuid.Nil
+	}
+	lines = append(lines, r.ingredients...)
+
+	prepSteps := make([]domain.Prep, 0, len(inherited.prepSteps)+len(r.prepSteps))
+	for _, p := range inherited.prepSteps {
+		if to, ok := replaced[p.IngredientID()]; ok {
+			if to == uuid.Nil {
+				continue
+			}
+			var err error
+			if p, err = domain.NewPrep(to, p.Action()); err != nil {
+				return Recipe{}, err
+			}
+		}
+		prepSteps = append(prepSteps, p.WithIndex(len(prepSteps)))
+	}
+	for _, p := range r.prepSteps {
+		prepSteps = append(prepSteps, p.WithIndex(len(prepSteps)))
+	}
+
+	// kept maps the positions of the inherited steps onto their
+	// positions in the resolved recipe, -1 for dropped ones
+	kept := make([]int, len(inherited.steps))
+	steps := make([]domain.Step, 0, len(inherited.steps)+len(r.steps))
+	for i, s := range inherited.steps {
+		kept[i] = -1
+		if to, ok := replaced[s.IngredientID()]; ok {
+			if to == uuid.Nil {
+				continue
+			}
+			s = s.WithIngredient(to)
+		}
+		kept[i] = len(steps)
+		steps = append(steps, s)
+	}
+	// the variation's own steps depend on each other only and follow
+	// the inherited ones
+	own := len(steps)
+	steps = append(steps, r.steps...)
+	for i, s := range steps {
+		after := make([]int, 0, len(s.After()))
+		for _, v := range s.After() {
+			if i >= own {
+				v += own
+			} else if v = kept[v]; v < 0 {
+				continue
+			}
+			after = append(after, v)
+		}
+		s, err := s.WithDependencies(after)
+		if err != nil {
+			return Recipe{}, err
+		}
+		steps[i] = s.WithIndex(i)
+	}
+
+	r.ingredients, r.prepSteps, r.steps = lines, prepSteps, steps
+	return r, nil
+}
+
+// VariationIDs lists the recipes deriving from this one. Variations
+// recorded before they were recipes of their own point back at the
+// recipe itself and are left out.
+func (r Recipe) VariationIDs() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(r.variations))
+	for _, v := range r.variations {
+		if v.Item() != r.ID() {
+			ids = append(ids, v.Item())
+		}
+	}
+	return ids
+}
@@ -0,0 +1,22 @@
+package registry
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalize lower cases a name and reports whether it is usable: not
+// empty and made of letters, digits, spaces, hyphens and underscores,
+// which keeps it safe to put in a query string.
+func Normalize(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '-' && r != '_' {
+			return "", false
+		}
+	}
+	return name, true
+}
@@ -0,0 +1,41 @@
+// Package registry holds what cuisines and ingredient types have in
+// common: how their names are checked and how the trees they form by
+// referring to their parent by id are walked.
+package registry
+
+// Expand returns id followed by every id below it at any depth, given
+// the ids directly below each one.
+func Expand[ID ~int](id ID, children map[ID][]ID) []ID {
+	ids := []ID{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+// Ancestors returns id followed by every id above it, nearest first.
+// parent reports the parent of an id and whether the id is known at
+// all; the walk stops at the first unknown one, so an unknown id has
+// no ancestors.
+func Ancestors[ID ~int](id ID, parent func(ID) (ID, bool)) []ID {
+	var ids []ID
+	for p, ok := parent(id); ok; p, ok = parent(p) {
+		ids = append(ids, id)
+		id = p
+	}
+	return ids
+}
+
+// Cyclic reports whether walking up from id goes round in a loop
+// rather than reaching the top of the tree.
+func Cyclic[ID ~int](id ID, parent func(ID) (ID, bool)) bool {
+	seen := make(map[ID]bool)
+	for p, ok := parent(id); ok; p, ok = parent(p) {
+		if seen[id] {
+			return true
+		}
+		seen[id] = true
+		id = p
+	}
+	return false
+}
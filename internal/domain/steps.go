@@ -1,11 +1,81 @@
 package domain
 
-import "github.com/google/uuid"
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain/units"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidStep = errors.New("step needs an action")
+
+var (
+	ErrInvalidMethod          = errors.New("invalid cooking method for step")
+	ErrImplausibleTemperature = errors.New("temperature is implausible for the cooking method")
+)
+
+var ErrInvalidEquipment = errors.New("invalid equipment for step")
+
+var ErrInvalidStepTiming = errors.New("step durations cannot be negative and it can only depend on steps before it")
 
 type Step struct {
 	ingredient  uuid.UUID
 	action      string
-	temperature float64
+	temperature units.Temperature
+	method      CookingMethod
+	index       int
+	// active is the hands-on part of the step and passive the time it
+	// then needs without attention, like simmering or resting
+	active    time.Duration
+	passive   time.Duration
+	equipment Equipment
+	// after holds the positions of the steps that have to be finished
+	// before this one starts
+	after []int
+}
+
+// NewStep creates a cooking step. ingredient may be uuid.Nil for steps
+// that do not act on a single ingredient, like preheating the oven, and
+// temperature the zero Temperature for steps without one.
+func NewStep(ingredient uuid.UUID, action string, temperature units.Temperature) (Step, error) {
+	action = strings.TrimSpace(action)
+	if action == "" {
+		return Step{}, ErrInvalidStep
+	}
+	if !temperature.IsZero() {
+		var err error
+		if temperature, err = units.NewTemperature(temperature.Value, temperature.Unit); err != nil {
+			return Step{}, err
+		}
+	}
+	return Step{
+		ingredient:  ingredient,
+		action:      action,
+		temperature: temperature,
+	}, nil
+}
+
+// StoredStep is NewStep followed by WithMethod for reading a step back
+// from storage. The temperature and method were checked when the step
+// was written and are taken as they are, even where the checks have
+// become stricter since.
+func StoredStep(ingredient uuid.UUID, action string, temperature units.Temperature, method CookingMethod) (Step, error) {
+	action = strings.TrimSpace(action)
+	if action == "" {
+		return Step{}, ErrInvalidStep
+	}
+	if method < UnknownMethod || method > SousVide {
+		return Step{}, ErrInvalidMethod
+	}
+	return Step{
+		ingredient:  ingredient,
+		action:      action,
+		temperature: temperature,
+		method:      method,
+	}, nil
 }
 
 func (s Step) Action() string {
@@ -13,9 +83,123 @@ func (s Step) Action() string {
 }
 
 func (s Step) Ingredient() string {
+	if s.ingredient == uuid.Nil {
+		return ""
+	}
 	return s.ingredient.String()
 }
 
-func (s Step) Temperature() float64 {
+func (s Step) IngredientID() uuid.UUID {
+	return s.ingredient
+}
+
+func (s Step) Temperature() units.Temperature {
 	return s.temperature
 }
+
+func (s Step) Method() CookingMethod {
+	return s.method
+}
+
+// WithMethod returns the step cooking by the given method, which has
+// to be plausible at the step's temperature.
+func (s Step) WithMethod(m CookingMethod) (Step, error) {
+	if m < UnknownMethod || m > SousVide {
+		return Step{}, ErrInvalidMethod
+	}
+	if !m.Plausible(s.temperature) {
+		return Step{}, ErrImplausibleTemperature
+	}
+	s.method = m
+	return s, nil
+}
+
+// InSystem returns the step with its temperature in the unit of the
+// given measurement system.
+func (s Step) InSystem(sys units.System) Step {
+	s.temperature = s.temperature.InSystem(sys)
+	return s
+}
+
+// InTemperatureUnit returns the step with its temperature in the given
+// unit.
+func (s Step) InTemperatureUnit(u units.TemperatureUnit) Step {
+	s.temperature = s.temperature.Convert(u)
+	return s
+}
+
+func (s Step) Index() int {
+	return s.index
+}
+
+// WithIndex returns the step placed at the given position.
+func (s Step) WithIndex(index int) Step {
+	s.index = index
+	return s
+}
+
+// WithIngredient returns the step acting on another ingredient.
+func (s Step) WithIngredient(ingredient uuid.UUID) Step {
+	s.ingredient = ingredient
+	return s
+}
+
+// Active is the hands-on time of the step, zero when not known.
+func (s Step) Active() time.Duration {
+	return s.active
+}
+
+// Passive is the time the step takes unattended after its hands-on
+// part, zero when not known.
+func (s Step) Passive() time.Duration {
+	return s.passive
+}
+
+func (s Step) Duration() time.Duration {
+	return s.active + s.passive
+}
+
+// WithDurations returns the step taking the given hands-on and
+// unattended time.
+func (s Step) WithDurations(active, passive time.Duration) (Step, error) {
+	if active < 0 || passive < 0 {
+		return Step{}, ErrInvalidStepTiming
+	}
+	s.active, s.passive = active, passive
+	return s, nil
+}
+
+// After lists the positions of the steps this one waits for, in
+// ascending order.
+func (s Step) After() []int {
+	return s.after
+}
+
+// WithDependencies returns the step waiting for the steps at the given
+// positions.
+func (s Step) WithDependencies(after []int) (Step, error) {
+	var deps []int
+	for _, v := range after {
+		if v < 0 {
+			return Step{}, ErrInvalidStepTiming
+		}
+		deps = append(deps, v)
+	}
+	slices.Sort(deps)
+	s.after = slices.Compact(deps)
+	return s, nil
+}
+
+// Equipment is what the step occupies for its whole duration. An oven
+// is held at the step's temperature.
+func (s Step) Equipment() Equipment {
+	return s.equipment
+}
+
+func (s Step) WithEquipment(e Equipment) (Step, error) {
+	if e < NoEquipment || e > Hob {
+		return Step{}, ErrInvalidEquipment
+	}
+	s.equipment = e
+	return s, nil
+}
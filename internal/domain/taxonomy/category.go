@@ -0,0 +1,84 @@
+package taxonomy
+
+import (
+	"errors"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/registry"
+)
+
+var (
+	ErrInvalidCategory     = errors.New("invalid ingredient type")
+	ErrCategoryNotFound    = errors.New("ingredient type not found")
+	ErrCategoryExists      = errors.New("ingredient type name is already taken")
+	ErrParentNotFound      = errors.New("parent ingredient type not found")
+	ErrCategoryCycle       = errors.New("ingredient type cannot be below itself")
+	ErrCategoryHasChildren = errors.New("ingredient type still has narrower types")
+	ErrCategoryInUse       = errors.New("ingredient type is still used by an ingredient")
+)
+
+// firstID is the lowest id handed out to a category added at runtime,
+// clear of the built-in ones.
+const firstID = domain.IngredientType(domain.NutsAndSeeds + 1)
+
+// Category is a node of the ingredient taxonomy. Ingredients refer to
+// it by id; a category is narrower than its parent, as shellfish is a
+// kind of fish.
+type Category struct {
+	id     domain.IngredientType
+	name   string
+	parent domain.IngredientType
+}
+
+// NewCategory creates a category. Names are matched without regard to
+// case, so they are kept lower cased.
+func NewCategory(id domain.IngredientType, name string, parent domain.IngredientType) (Category, error) {
+	if id <= domain.UnknownIngredient || parent < domain.UnknownIngredient {
+		return Category{}, ErrInvalidCategory
+	}
+	if id == parent {
+		return Category{}, ErrCategoryCycle
+	}
+	name, ok := registry.Normalize(name)
+	if !ok {
+		return Category{}, ErrInvalidCategory
+	}
+	return Category{id: id, name: name, parent: parent}, nil
+}
+
+func (c Category) ID() domain.IngredientType {
+	return c.id
+}
+
+func (c Category) Name() string {
+	return c.name
+}
+
+// Parent is domain.UnknownIngredient for a category at the top of the
+// tree.
+func (c Category) Parent() domain.IngredientType {
+	return c.parent
+}
+
+// Defaults are the categories a taxonomy is seeded with when nothing
+// else is configured.
+func Defaults() []Category {
+	return []Category{
+		{id: domain.Vegetable, name: "vegetable"},
+		{id: domain.Legumes, name: "legumes", parent: domain.Vegetable},
+		{id: domain.Fruit, name: "fruit"},
+		{id: domain.Protein, name: "protein"},
+		{id: domain.Meat, name: "meat", parent: domain.Protein},
+		{id: domain.RedMeat, name: "red meat", parent: domain.Meat},
+		{id: domain.Poultry, name: "poultry", parent: domain.Meat},
+		{id: domain.Fish, name: "fish", parent: domain.Protein},
+		{id: domain.Shellfish, name: "shellfish", parent: domain.Fish},
+		{id: domain.Eggs, name: "eggs", parent: domain.Protein},
+		{id: domain.Dairy, name: "dairy"},
+		{id: domain.Cheese, name: "cheese", parent: domain.Dairy},
+		{id: domain.Grains, name: "grains"},
+		{id: domain.NutsAndSeeds, name: "nuts and seeds"},
+		{id: domain.Condiments, name: "condiments"},
+		{id: domain.HerbsAndSpices, name: "herbs and spices", parent: domain.Condiments},
+	}
+}
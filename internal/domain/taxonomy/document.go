@@ -0,0 +1,51 @@
+package taxonomy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+)
+
+// document is how a category is stored and how it is written in an
+// ingredient types file. Parents are referred to by id.
+type document struct {
+	ID     int    `bson:"_id" json:"id"`
+	Name   string `bson:"name" json:"name"`
+	Parent int    `bson:"parent,omitempty" json:"parent,omitempty"`
+}
+
+func documentFromCategory(c Category) document {
+	return document{ID: int(c.id), Name: c.name, Parent: int(c.parent)}
+}
+
+func (d document) toCategory() (Category, error) {
+	return NewCategory(domain.IngredientType(d.ID), d.Name, domain.IngredientType(d.Parent))
+}
+
+// LoadFile reads the categories a taxonomy is seeded with from a JSON
+// array of {"id", "name", "parent"} objects. The ids of the default
+// categories should be kept, as stored ingredients refer to them.
+func LoadFile(path string) ([]Category, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var docs []document
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return nil, fmt.Errorf("parsing ingredient types file failed: %w", err)
+	}
+	categories := make([]Category, 0, len(docs))
+	for _, d := range docs {
+		c, err := d.toCategory()
+		if err != nil {
+			return nil, fmt.Errorf("ingredient type %q: %w", d.Name, err)
+		}
+		categories = append(categories, c)
+	}
+	if _, err := New(categories...); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
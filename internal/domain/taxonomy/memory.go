@@ -0,0 +1,89 @@
+package taxonomy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+)
+
+// MemoryRepository keeps categories for as long as the process runs.
+type MemoryRepository struct {
+	categories map[domain.IngredientType]Category
+	next       domain.IngredientType
+	mu         sync.Mutex
+}
+
+func NewMemoryRepository(seed ...Category) *MemoryRepository {
+	mr := &MemoryRepository{
+		categories: make(map[domain.IngredientType]Category, len(seed)),
+		next:       firstID,
+	}
+	for _, c := range seed {
+		mr.categories[c.id] = c
+		mr.next = max(mr.next, c.id+1)
+	}
+	return mr
+}
+
+func (mr *MemoryRepository) NextID(_ context.Context) (domain.IngredientType, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	id := mr.next
+	mr.next++
+	return id, nil
+}
+
+func (mr *MemoryRepository) List(_ context.Context) ([]Category, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	categories := make([]Category, 0, len(mr.categories))
+	for _, c := range mr.categories {
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+func (mr *MemoryRepository) Add(_ context.Context, c Category) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if _, ok := mr.categories[c.id]; ok || mr.taken(c) {
+		return ErrCategoryExists
+	}
+	mr.categories[c.id] = c
+	mr.next = max(mr.next, c.id+1)
+	return nil
+}
+
+func (mr *MemoryRepository) Update(_ context.Context, c Category) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if _, ok := mr.categories[c.id]; !ok {
+		return ErrCategoryNotFound
+	}
+	if mr.taken(c) {
+		return ErrCategoryExists
+	}
+	mr.categories[c.id] = c
+	return nil
+}
+
+// taken reports whether another category has c's name already.
+func (mr *MemoryRepository) taken(c Category) bool {
+	for id, other := range mr.categories {
+		if id != c.id && other.name == c.name {
+			return true
+		}
+	}
+	return false
+}
+
+func (mr *MemoryRepository) Delete(_ context.Context, id domain.IngredientType) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if _, ok := mr.categories[id]; !ok {
+		return ErrCategoryNotFound
+	}
+	delete(mr.categories, id)
+	return nil
+}
@@ -0,0 +1,161 @@
+package taxonomy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type MongoRepository struct {
+	client         *mongo.Client
+	databaseName   string
+	collectionName string
+}
+
+func NewMongoRepository(client *mongo.Client, databaseName, collectionName string) *MongoRepository {
+	return &MongoRepository{
+		client:         client,
+		databaseName:   databaseName,
+		collectionName: collectionName,
+	}
+}
+
+func (mr *MongoRepository) collection() *mongo.Collection {
+	return mr.client.Database(mr.databaseName).Collection(mr.collectionName)
+}
+
+func (mr *MongoRepository) ids() *mongo.Collection {
+	return mr.client.Database(mr.databaseName).Collection(mr.collectionName + "_ids")
+}
+
+type counter struct {
+	Next int `bson:"next"`
+}
+
+func (mr *MongoRepository) reserve(ctx context.Context, id domain.IngredientType) error {
+	_, err := mr.ids().UpdateOne(
+		ctx,
+		bson.M{"_id": "ingredient_type"},
+		bson.M{"$max": bson.M{"next": int(id) + 1}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// Seed creates the unique name index, stores seed when the collection
+// is still empty and sets up the id counter. It is safe to call on
+// every start.
+func (mr *MongoRepository) Seed(ctx context.Context, seed []Category) error {
+	collection := mr.collection()
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetName("ingredient_type_name").SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	n, err := collection.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	if n == 0 && len(seed) > 0 {
+		docs := make([]any, 0, len(seed))
+		for _, c := range seed {
+			docs = append(docs, documentFromCategory(c))
+		}
+		_, err = collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+
+	last := firstID - 1
+	var d document
+	err = collection.FindOne(ctx, bson.D{}, options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})).Decode(&d)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	if err == nil {
+		last = max(last, domain.IngredientType(d.ID))
+	}
+	return mr.reserve(ctx, last)
+}
+
+func (mr *MongoRepository) NextID(ctx context.Context) (domain.IngredientType, error) {
+	var c counter
+	err := mr.ids().FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": "ingredient_type"},
+		bson.M{"$inc": bson.M{"next": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, errors.New("ingredient type id counter is missing, ingredient types were not seeded")
+	}
+	return domain.IngredientType(c.Next), err
+}
+
+func (mr *MongoRepository) List(ctx context.Context) ([]Category, error) {
+	cur, err := mr.collection().Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var categories []Category
+	for cur.Next(ctx) {
+		var d document
+		if err := cur.Decode(&d); err != nil {
+			slog.WarnContext(ctx, "skipping unreadable stored ingredient type", "id", cur.Current.Lookup("_id").String(), "err", err.Error())
+			continue
+		}
+		c, err := d.toCategory()
+		if err != nil {
+			slog.WarnContext(ctx, "skipping unreadable stored ingredient type", "id", d.ID, "err", err.Error())
+			continue
+		}
+		categories = append(categories, c)
+	}
+	return categories, cur.Err()
+}
+
+func (mr *MongoRepository) Add(ctx context.Context, c Category) error {
+	_, err := mr.collection().InsertOne(ctx, documentFromCategory(c))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCategoryExists
+	}
+	if err != nil {
+		return err
+	}
+	return mr.reserve(ctx, c.id)
+}
+
+func (mr *MongoRepository) Update(ctx context.Context, c Category) error {
+	res, err := mr.collection().ReplaceOne(ctx, bson.M{"_id": int(c.id)}, documentFromCategory(c))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrCategoryExists
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+func (mr *MongoRepository) Delete(ctx context.Context, id domain.IngredientType) error {
+	res, err := mr.collection().DeleteOne(ctx, bson.M{"_id": int(id)})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
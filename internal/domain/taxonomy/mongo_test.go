@@ -0,0 +1,19 @@
+package taxonomy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bento01dev/cookbook/internal/db/dbtest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMongoRepository(t *testing.T) {
+	client := dbtest.MongoClient(t)
+	testRepository(t, func(t *testing.T) repository {
+		repo := NewMongoRepository(client, "cookbook_test", "ingredient_type_"+uuid.NewString())
+		require.NoError(t, repo.Seed(context.Background(), Defaults()))
+		return repo
+	})
+}
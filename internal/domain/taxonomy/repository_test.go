@@ -0,0 +1,145 @@
+package taxonomy
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bento01dev/cookbook/internal/db"
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// repository mirrors the taxonomyRepository interface the recipe
+// service uses.
+type repository interface {
+	List(context.Context) ([]Category, error)
+	NextID(context.Context) (domain.IngredientType, error)
+	Add(context.Context, Category) error
+	Update(context.Context, Category) error
+	Delete(context.Context, domain.IngredientType) error
+}
+
+// testRepository runs the repository contract against a fresh
+// repository seeded with the defaults for every case.
+func testRepository(t *testing.T, newRepo func(t *testing.T) repository) {
+	ctx := context.Background()
+
+	t.Run("starts out with the seed", func(t *testing.T) {
+		got, err := newRepo(t).List(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, Defaults(), got)
+	})
+
+	t.Run("add, update and delete", func(t *testing.T) {
+		repo := newRepo(t)
+		c := newCategory(t, 17, "oily fish", domain.Fish)
+		require.NoError(t, repo.Add(ctx, c))
+		assert.ErrorIs(t, repo.Add(ctx, c), ErrCategoryExists)
+
+		moved := newCategory(t, 17, "oily fish", domain.Protein)
+		require.NoError(t, repo.Update(ctx, moved))
+		got, err := repo.List(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, got, moved)
+
+		require.NoError(t, repo.Delete(ctx, 17))
+		assert.ErrorIs(t, repo.Delete(ctx, 17), ErrCategoryNotFound)
+		assert.ErrorIs(t, repo.Update(ctx, moved), ErrCategoryNotFound)
+	})
+
+	t.Run("names are unique across categories", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.Add(ctx, newCategory(t, 17, "oily fish", domain.Fish)))
+		assert.ErrorIs(t, repo.Add(ctx, newCategory(t, 18, "Oily Fish", 0)), ErrCategoryExists)
+		assert.ErrorIs(t, repo.Update(ctx, newCategory(t, domain.Fruit, "oily fish", 0)), ErrCategoryExists)
+
+		// a category keeps its own name when it is moved
+		require.NoError(t, repo.Update(ctx, newCategory(t, 17, "oily fish", domain.Protein)))
+		got, err := repo.List(ctx)
+		require.NoError(t, err)
+		assert.Len(t, got, len(Defaults())+1)
+	})
+
+	t.Run("ids are never handed out twice", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.NextID(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, domain.IngredientType(domain.NutsAndSeeds+1), id)
+
+		require.NoError(t, repo.Add(ctx, newCategory(t, id, "tofu", 0)))
+		require.NoError(t, repo.Delete(ctx, id))
+		next, err := repo.NextID(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, id+1, next)
+
+		// a category added with an id of its own moves the counter past
+		// it
+		require.NoError(t, repo.Add(ctx, newCategory(t, 40, "seaweed", 0)))
+		next, err = repo.NextID(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, domain.IngredientType(41), next)
+	})
+}
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) repository {
+		return NewMemoryRepository(Defaults()...)
+	})
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) repository {
+		sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "cookbook.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			sqlDB.Close()
+		})
+
+		repo := NewSQLiteRepository(sqlDB)
+		require.NoError(t, repo.Migrate(context.Background()))
+		// migrating an up to date schema is a no-op
+		require.NoError(t, repo.Migrate(context.Background()))
+		require.NoError(t, repo.Seed(context.Background(), Defaults()))
+		// seeding a taxonomy that has categories is a no-op
+		require.NoError(t, repo.Seed(context.Background(), []Category{newCategory(t, 17, "tofu", 0)}))
+		return repo
+	})
+}
+
+func TestSQLiteRepositoryUpgrade(t *testing.T) {
+	ctx := context.Background()
+	sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "cookbook.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+	// the table as it was created before there were migrations
+	_, err = sqlDB.ExecContext(ctx, `CREATE TABLE ingredient_types (
+		id     INTEGER PRIMARY KEY,
+		name   TEXT NOT NULL UNIQUE,
+		parent INTEGER NOT NULL
+	)`)
+	require.NoError(t, err)
+	_, err = sqlDB.ExecContext(ctx, `INSERT INTO ingredient_types (id, name, parent) VALUES
+		(4, 'fish', 6),
+		(6, 'protein', 0),
+		(20, 'oily fish', 4)`)
+	require.NoError(t, err)
+
+	repo := NewSQLiteRepository(sqlDB)
+	require.NoError(t, repo.Migrate(ctx))
+	require.NoError(t, repo.Seed(ctx, Defaults()))
+	got, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Category{
+		newCategory(t, domain.Protein, "protein", 0),
+		newCategory(t, domain.Fish, "fish", domain.Protein),
+		newCategory(t, 20, "oily fish", domain.Fish),
+	}, got)
+
+	id, err := repo.NextID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, domain.IngredientType(21), id)
+}
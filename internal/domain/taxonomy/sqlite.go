@@ -0,0 +1,167 @@
+package taxonomy
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/bento01dev/cookbook/internal/db"
+	"github.com/bento01dev/cookbook/internal/domain"
+)
+
+// SQLiteRepository keeps categories in their own table next to the
+// recipes.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{db: db}
+}
+
+var migrations = []db.Migration{
+	// ingredient types used to be created on every start, so the table
+	// may predate the migrations
+	db.Exec(`CREATE TABLE IF NOT EXISTS ingredient_types (
+		id     INTEGER PRIMARY KEY,
+		name   TEXT NOT NULL UNIQUE,
+		parent INTEGER NOT NULL
+	)`),
+	migrateIDs,
+}
+
+func migrateIDs(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE ingredient_type_ids (next INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO ingredient_type_ids (next) SELECT MAX(COALESCE(MAX(id) + 1, 0), ?) FROM ingredient_types`,
+		int(firstID),
+	)
+	return err
+}
+
+// Migrate brings the schema up to date.
+func (sr *SQLiteRepository) Migrate(ctx context.Context) error {
+	return db.Migrate(ctx, sr.db, "ingredient_type_migrations", migrations)
+}
+
+// Seed stores seed when the ingredient types table is still empty. It
+// is safe to call on every start, after Migrate.
+func (sr *SQLiteRepository) Seed(ctx context.Context, seed []Category) error {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM ingredient_types`).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	for _, c := range seed {
+		if err := insertCategory(ctx, tx, c); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type execer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}
+
+func insertCategory(ctx context.Context, ex execer, c Category) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`INSERT INTO ingredient_types (id, name, parent) VALUES (?, ?, ?)`,
+		int(c.id), c.name, int(c.parent),
+	)
+	if db.IsUniqueViolation(err) {
+		return ErrCategoryExists
+	}
+	if err != nil {
+		return err
+	}
+	_, err = ex.ExecContext(ctx, `UPDATE ingredient_type_ids SET next = MAX(next, ?)`, int(c.id)+1)
+	return err
+}
+
+func (sr *SQLiteRepository) List(ctx context.Context) ([]Category, error) {
+	rows, err := sr.db.QueryContext(ctx, `SELECT id, name, parent FROM ingredient_types`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		var d document
+		if err := rows.Scan(&d.ID, &d.Name, &d.Parent); err != nil {
+			return nil, err
+		}
+		c, err := d.toCategory()
+		if err != nil {
+			slog.WarnContext(ctx, "skipping unreadable stored ingredient type", "id", d.ID, "err", err.Error())
+			continue
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (sr *SQLiteRepository) NextID(ctx context.Context) (domain.IngredientType, error) {
+	var id int
+	err := sr.db.QueryRowContext(ctx, `UPDATE ingredient_type_ids SET next = next + 1 RETURNING next - 1`).Scan(&id)
+	return domain.IngredientType(id), err
+}
+
+func (sr *SQLiteRepository) Add(ctx context.Context, c Category) error {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := insertCategory(ctx, tx, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (sr *SQLiteRepository) Update(ctx context.Context, c Category) error {
+	res, err := sr.db.ExecContext(
+		ctx,
+		`UPDATE ingredient_types SET name = ?, parent = ? WHERE id = ?`,
+		c.name, int(c.parent), int(c.id),
+	)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return ErrCategoryExists
+		}
+		return err
+	}
+	return affected(res)
+}
+
+func (sr *SQLiteRepository) Delete(ctx context.Context, id domain.IngredientType) error {
+	res, err := sr.db.ExecContext(ctx, `DELETE FROM ingredient_types WHERE id = ?`, int(id))
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
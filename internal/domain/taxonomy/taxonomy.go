@@ -0,0 +1,218 @@
+package taxonomy
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/registry"
+)
+
+// Taxonomy is a consistent view of every ingredient category: names
+// are unique and the categories form a forest. It is never changed in
+// place; With and Without return a new taxonomy, so one can be shared
+// between goroutines.
+type Taxonomy struct {
+	categories map[domain.IngredientType]Category
+	names      map[string]domain.IngredientType
+	children   map[domain.IngredientType][]domain.IngredientType
+}
+
+// New checks the categories against each other and indexes them.
+func New(categories ...Category) (Taxonomy, error) {
+	tx := Taxonomy{
+		categories: make(map[domain.IngredientType]Category, len(categories)),
+		names:      make(map[string]domain.IngredientType, len(categories)),
+		children:   make(map[domain.IngredientType][]domain.IngredientType),
+	}
+	for _, c := range categories {
+		if _, ok := tx.categories[c.id]; ok {
+			return Taxonomy{}, ErrCategoryExists
+		}
+		if _, ok := tx.names[c.name]; ok {
+			return Taxonomy{}, ErrCategoryExists
+		}
+		tx.categories[c.id] = c
+		tx.names[c.name] = c.id
+	}
+	for _, c := range categories {
+		if c.parent == domain.UnknownIngredient {
+			continue
+		}
+		if _, ok := tx.categories[c.parent]; !ok {
+			return Taxonomy{}, ErrParentNotFound
+		}
+		tx.children[c.parent] = append(tx.children[c.parent], c.id)
+	}
+	for id := range tx.categories {
+		if tx.cyclic(id) {
+			return Taxonomy{}, ErrCategoryCycle
+		}
+		slices.Sort(tx.children[id])
+	}
+	return tx, nil
+}
+
+// Load builds a taxonomy from stored categories, leaving out the ones
+// that do not fit rather than failing: a category whose id or name is
+// taken by one with a lower id already, and categories whose parent is
+// missing or part of a cycle. It returns the categories it left out,
+// so they can be reported and fixed.
+func Load(categories ...Category) (Taxonomy, []Category) {
+	sorted := slices.Clone(categories)
+	slices.SortStableFunc(sorted, func(a, b Category) int {
+		return int(a.id - b.id)
+	})
+
+	var skipped []Category
+	kept := Taxonomy{
+		categories: make(map[domain.IngredientType]Category, len(sorted)),
+		names:      make(map[string]domain.IngredientType, len(sorted)),
+	}
+	for _, c := range sorted {
+		_, taken := kept.categories[c.id]
+		if _, ok := kept.names[c.name]; taken || ok {
+			skipped = append(skipped, c)
+			continue
+		}
+		kept.categories[c.id] = c
+		kept.names[c.name] = c.id
+	}
+	// leaving out a category orphans the narrower ones, so keep going
+	// until every parent is there
+	for changed := true; changed; {
+		changed = false
+		for id, c := range kept.categories {
+			if c.parent == domain.UnknownIngredient {
+				continue
+			}
+			if _, ok := kept.categories[c.parent]; ok && !kept.cyclic(id) {
+				continue
+			}
+			delete(kept.categories, id)
+			delete(kept.names, c.name)
+			skipped = append(skipped, c)
+			changed = true
+		}
+	}
+
+	kept.children = make(map[domain.IngredientType][]domain.IngredientType)
+	for id, c := range kept.categories {
+		if c.parent != domain.UnknownIngredient {
+			kept.children[c.parent] = append(kept.children[c.parent], id)
+		}
+	}
+	for id := range kept.children {
+		slices.Sort(kept.children[id])
+	}
+	return kept, skipped
+}
+
+// parent reports the parent of the category with the given id and
+// whether the taxonomy knows it.
+func (tx Taxonomy) parent(id domain.IngredientType) (domain.IngredientType, bool) {
+	c, ok := tx.categories[id]
+	return c.parent, ok
+}
+
+// cyclic reports whether walking up from id comes back round.
+func (tx Taxonomy) cyclic(id domain.IngredientType) bool {
+	return registry.Cyclic(id, tx.parent)
+}
+
+// Get returns the category with the given id.
+func (tx Taxonomy) Get(id domain.IngredientType) (Category, bool) {
+	c, ok := tx.categories[id]
+	return c, ok
+}
+
+// Lookup finds a category by its name, ignoring case.
+func (tx Taxonomy) Lookup(name string) (Category, bool) {
+	id, ok := tx.names[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Category{}, false
+	}
+	return tx.categories[id], true
+}
+
+// Name is the name of the category with the given id, or "" when the
+// taxonomy does not know it.
+func (tx Taxonomy) Name(id domain.IngredientType) string {
+	return tx.categories[id].name
+}
+
+// All returns every category ordered by id.
+func (tx Taxonomy) All() []Category {
+	all := make([]Category, 0, len(tx.categories))
+	for _, c := range tx.categories {
+		all = append(all, c)
+	}
+	slices.SortFunc(all, func(a, b Category) int {
+		return int(a.id - b.id)
+	})
+	return all
+}
+
+// Children returns the categories directly below the given one.
+func (tx Taxonomy) Children(id domain.IngredientType) []Category {
+	children := make([]Category, 0, len(tx.children[id]))
+	for _, child := range tx.children[id] {
+		children = append(children, tx.categories[child])
+	}
+	return children
+}
+
+// Path returns the names from the top of the tree down to the category
+// with the given id, such as protein, fish, shellfish.
+func (tx Taxonomy) Path(id domain.IngredientType) []string {
+	var path []string
+	for _, a := range tx.Ancestors(id) {
+		path = append(path, tx.categories[a].name)
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// Expand returns id followed by every category below it at any depth,
+// which is what an ingredient has to be to count as of that category:
+// asking for fish finds prawns typed as shellfish too.
+func (tx Taxonomy) Expand(id domain.IngredientType) []domain.IngredientType {
+	return registry.Expand(id, tx.children)
+}
+
+// Ancestors returns id followed by every category it is narrower than,
+// nearest first, which are the categories an ingredient of id counts
+// towards. It is empty when the taxonomy does not know id.
+func (tx Taxonomy) Ancestors(id domain.IngredientType) []domain.IngredientType {
+	return registry.Ancestors(id, tx.parent)
+}
+
+// With adds c, or replaces the category with its id, checking that the
+// taxonomy stays consistent.
+func (tx Taxonomy) With(c Category) (Taxonomy, error) {
+	categories := make([]Category, 0, len(tx.categories)+1)
+	for _, existing := range tx.categories {
+		if existing.id != c.id {
+			categories = append(categories, existing)
+		}
+	}
+	return New(append(categories, c)...)
+}
+
+// Without removes the category with the given id. Narrower categories
+// have to be removed or moved first.
+func (tx Taxonomy) Without(id domain.IngredientType) (Taxonomy, error) {
+	if _, ok := tx.categories[id]; !ok {
+		return Taxonomy{}, ErrCategoryNotFound
+	}
+	if len(tx.children[id]) > 0 {
+		return Taxonomy{}, ErrCategoryHasChildren
+	}
+	categories := make([]Category, 0, len(tx.categories))
+	for _, c := range tx.categories {
+		if c.id != id {
+			categories = append(categories, c)
+		}
+	}
+	return New(categories...)
+}
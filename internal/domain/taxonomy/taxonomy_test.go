@@ -0,0 +1,125 @@
+package taxonomy
+
+import (
+	"testing"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCategory(t *testing.T, id domain.IngredientType, name string, parent domain.IngredientType) Category {
+	t.Helper()
+	c, err := NewCategory(id, name, parent)
+	require.NoError(t, err)
+	return c
+}
+
+func TestNewCategory(t *testing.T) {
+	c, err := NewCategory(17, " Oily Fish ", domain.Fish)
+	require.NoError(t, err)
+	assert.Equal(t, "oily fish", c.Name())
+	assert.Equal(t, domain.IngredientType(domain.Fish), c.Parent())
+
+	for name, fn := range map[string]func() (Category, error){
+		"no id":         func() (Category, error) { return NewCategory(domain.UnknownIngredient, "tofu", 0) },
+		"empty name":    func() (Category, error) { return NewCategory(17, " ", 0) },
+		"unsafe name":   func() (Category, error) { return NewCategory(17, "fish&chips", 0) },
+		"own parent":    func() (Category, error) { return NewCategory(17, "tofu", 17) },
+		"negative root": func() (Category, error) { return NewCategory(17, "tofu", -1) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := fn()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	tx, err := New(Defaults()...)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"protein", "fish", "shellfish"}, tx.Path(domain.Shellfish))
+	assert.Equal(t, []string{"vegetable"}, tx.Path(domain.Vegetable))
+	assert.Nil(t, tx.Path(42))
+	assert.ElementsMatch(t,
+		[]domain.IngredientType{domain.Protein, domain.Meat, domain.Fish, domain.Eggs, domain.RedMeat, domain.Poultry, domain.Shellfish},
+		tx.Expand(domain.Protein),
+	)
+}
+
+func TestTaxonomy(t *testing.T) {
+	tx, err := New(append(Defaults(),
+		newCategory(t, 17, "oily fish", domain.Fish),
+		newCategory(t, 18, "smoked fish", 17),
+	)...)
+	require.NoError(t, err)
+
+	t.Run("lookup by name ignoring case", func(t *testing.T) {
+		c, ok := tx.Lookup("Oily Fish")
+		require.True(t, ok)
+		assert.Equal(t, domain.IngredientType(17), c.ID())
+		_, ok = tx.Lookup("tofu")
+		assert.False(t, ok)
+		assert.Equal(t, "oily fish", tx.Name(17))
+		assert.Equal(t, "", tx.Name(42))
+	})
+
+	t.Run("children and expansion follow the tree", func(t *testing.T) {
+		var children []string
+		for _, c := range tx.Children(domain.Fish) {
+			children = append(children, c.Name())
+		}
+		assert.Equal(t, []string{"shellfish", "oily fish"}, children)
+		assert.Equal(t, []domain.IngredientType{domain.Fish, domain.Shellfish, 17, 18}, tx.Expand(domain.Fish))
+		assert.Equal(t, []domain.IngredientType{domain.Fruit}, tx.Expand(domain.Fruit))
+		assert.Equal(t, []domain.IngredientType{18, 17, domain.Fish, domain.Protein}, tx.Ancestors(18))
+		assert.Empty(t, tx.Ancestors(42))
+	})
+
+	t.Run("names are unique", func(t *testing.T) {
+		_, err := tx.With(newCategory(t, 19, "Shellfish", 0))
+		assert.ErrorIs(t, err, ErrCategoryExists)
+		moved, err := tx.With(newCategory(t, domain.Shellfish, "shellfish", domain.Protein))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"protein", "shellfish"}, moved.Path(domain.Shellfish))
+	})
+
+	t.Run("parents exist and never loop", func(t *testing.T) {
+		_, err := tx.With(newCategory(t, 19, "tofu", 42))
+		assert.ErrorIs(t, err, ErrParentNotFound)
+		_, err = tx.With(newCategory(t, domain.Fish, "fish", 18))
+		assert.ErrorIs(t, err, ErrCategoryCycle)
+	})
+
+	t.Run("only leaves can be removed", func(t *testing.T) {
+		_, err := tx.Without(17)
+		assert.ErrorIs(t, err, ErrCategoryHasChildren)
+		_, err = tx.Without(42)
+		assert.ErrorIs(t, err, ErrCategoryNotFound)
+		smaller, err := tx.Without(18)
+		require.NoError(t, err)
+		assert.Empty(t, smaller.Children(17))
+		assert.Len(t, smaller.All(), len(Defaults())+1)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	tx, skipped := Load(append(Defaults(),
+		newCategory(t, 17, "oily fish", domain.Fish),
+		// takes a name that is taken already
+		newCategory(t, 18, "fish", 0),
+		// below a category that is missing, and so is the one below it
+		newCategory(t, 19, "tofu", 42),
+		newCategory(t, 20, "silken tofu", 19),
+	)...)
+	assert.Len(t, tx.All(), len(Defaults())+1)
+	assert.ElementsMatch(t, []Category{
+		newCategory(t, 18, "fish", 0),
+		newCategory(t, 19, "tofu", 42),
+		newCategory(t, 20, "silken tofu", 19),
+	}, skipped)
+	c, ok := tx.Lookup("fish")
+	require.True(t, ok)
+	assert.Equal(t, domain.IngredientType(domain.Fish), c.ID())
+	assert.Equal(t, []domain.IngredientType{domain.Fish, domain.Shellfish, 17}, tx.Expand(domain.Fish))
+}
@@ -0,0 +1,185 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	ErrUnknownTemperatureUnit = errors.New("unknown temperature unit")
+	ErrInvalidTemperature     = errors.New("invalid temperature")
+)
+
+type TemperatureUnit int
+
+const (
+	UnknownTemperatureUnit TemperatureUnit = iota
+	Celsius
+	Fahrenheit
+	GasMark
+)
+
+// ParseTemperatureUnit maps the user facing names of the temperature
+// units. An empty string yields UnknownTemperatureUnit, meaning "leave
+// temperatures as authored".
+func ParseTemperatureUnit(s string) (TemperatureUnit, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return UnknownTemperatureUnit, nil
+	case "c", "°c", "celsius":
+		return Celsius, nil
+	case "f", "°f", "fahrenheit":
+		return Fahrenheit, nil
+	case "gas", "gas_mark", "gas mark":
+		return GasMark, nil
+	default:
+		return UnknownTemperatureUnit, ErrUnknownTemperatureUnit
+	}
+}
+
+func (u TemperatureUnit) String() string {
+	switch u {
+	case Celsius:
+		return "C"
+	case Fahrenheit:
+		return "F"
+	case GasMark:
+		return "gas_mark"
+	default:
+		return ""
+	}
+}
+
+// gasMarks lists the marks of a gas oven with the temperature each
+// stands for in Fahrenheit. Between marks the temperature is read as
+// lying on a straight line.
+var gasMarks = []struct {
+	mark       float64
+	fahrenheit float64
+}{
+	{0.25, 225}, {0.5, 250}, {1, 275}, {2, 300}, {3, 325}, {4, 350},
+	{5, 375}, {6, 400}, {7, 425}, {8, 450}, {9, 475}, {10, 500},
+}
+
+const (
+	absoluteZeroCelsius    = -273.15
+	absoluteZeroFahrenheit = -459.67
+)
+
+// Temperature is a temperature in a unit. The zero Temperature means
+// no temperature is given.
+type Temperature struct {
+	Value float64
+	Unit  TemperatureUnit
+}
+
+// NewTemperature validates value in the given unit. Nothing is colder
+// than absolute zero, and gas marks only go from 1/4 to 10.
+func NewTemperature(value float64, unit TemperatureUnit) (Temperature, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Temperature{}, ErrInvalidTemperature
+	}
+	switch unit {
+	case Celsius:
+		if value < absoluteZeroCelsius {
+			return Temperature{}, fmt.Errorf("%w: below absolute zero", ErrInvalidTemperature)
+		}
+	case Fahrenheit:
+		if value < absoluteZeroFahrenheit {
+			return Temperature{}, fmt.Errorf("%w: below absolute zero", ErrInvalidTemperature)
+		}
+	case GasMark:
+		if value < gasMarks[0].mark || value > gasMarks[len(gasMarks)-1].mark {
+			return Temperature{}, fmt.Errorf("%w: gas marks go from 1/4 to 10", ErrInvalidTemperature)
+		}
+	default:
+		return Temperature{}, ErrUnknownTemperatureUnit
+	}
+	return Temperature{Value: value, Unit: unit}, nil
+}
+
+func (t Temperature) IsZero() bool {
+	return t == Temperature{}
+}
+
+func (t Temperature) fahrenheit() float64 {
+	switch t.Unit {
+	case Celsius:
+		return t.Value*9/5 + 32
+	case GasMark:
+		return gasMarkFahrenheit(t.Value)
+	default:
+		return t.Value
+	}
+}
+
+// Celsius is the temperature in degrees Celsius, zero for the zero
+// Temperature.
+func (t Temperature) Celsius() float64 {
+	if t.Unit == Celsius || t.IsZero() {
+		return t.Value
+	}
+	return (t.fahrenheit() - 32) * 5 / 9
+}
+
+// Convert expresses t in unit to, rounded the way ovens are set:
+// degrees to the nearest 5 and gas marks to the nearest mark. The zero
+// Temperature and UnknownTemperatureUnit leave t unchanged.
+func (t Temperature) Convert(to TemperatureUnit) Temperature {
+	if t.IsZero() || to == UnknownTemperatureUnit || to == t.Unit {
+		return t
+	}
+	switch to {
+	case Celsius:
+		return Temperature{Value: math.Round(t.Celsius()/5) * 5, Unit: Celsius}
+	case Fahrenheit:
+		return Temperature{Value: math.Round(t.fahrenheit()/5) * 5, Unit: Fahrenheit}
+	default:
+		f := t.fahrenheit()
+		nearest := gasMarks[0]
+		for _, m := range gasMarks[1:] {
+			if math.Abs(m.fahrenheit-f) < math.Abs(nearest.fahrenheit-f) {
+				nearest = m
+			}
+		}
+		return Temperature{Value: nearest.mark, Unit: GasMark}
+	}
+}
+
+// InSystem renders t in the unit of the given measurement system,
+// Celsius for metric and Fahrenheit for imperial.
+func (t Temperature) InSystem(sys System) Temperature {
+	switch sys {
+	case Metric:
+		return t.Convert(Celsius)
+	case Imperial:
+		return t.Convert(Fahrenheit)
+	default:
+		return t
+	}
+}
+
+func (t Temperature) String() string {
+	switch t.Unit {
+	case Celsius, Fahrenheit:
+		return fmt.Sprintf("%g°%s", t.Value, t.Unit)
+	case GasMark:
+		return fmt.Sprintf("gas mark %g", t.Value)
+	default:
+		return ""
+	}
+}
+
+// gasMarkFahrenheit reads the temperature of a mark off the gas mark
+// table, on a straight line between the marks around it.
+func gasMarkFahrenheit(mark float64) float64 {
+	for i := 1; i < len(gasMarks); i++ {
+		lo, hi := gasMarks[i-1], gasMarks[i]
+		if mark <= hi.mark {
+			return lo.fahrenheit + (mark-lo.mark)*(hi.fahrenheit-lo.fahrenheit)/(hi.mark-lo.mark)
+		}
+	}
+	return gasMarks[len(gasMarks)-1].fahrenheit
+}
@@ -0,0 +1,293 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+var (
+	ErrIncompatibleUnits = errors.New("units measure different dimensions")
+	ErrDensityRequired   = errors.New("density required to convert between volume and mass")
+	ErrUnknownSystem     = errors.New("unknown measurement system")
+)
+
+type Dimension int
+
+const (
+	UnknownDimension Dimension = iota
+	Mass
+	Volume
+	Count
+)
+
+type System int
+
+const (
+	UnknownSystem System = iota
+	Metric
+	Imperial
+)
+
+// ParseSystem maps the user facing names of the measurement systems.
+// An empty string is not an error and yields UnknownSystem, meaning
+// "leave quantities as authored".
+func ParseSystem(s string) (System, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return UnknownSystem, nil
+	case "metric":
+		return Metric, nil
+	case "imperial":
+		return Imperial, nil
+	default:
+		return UnknownSystem, ErrUnknownSystem
+	}
+}
+
+// Unit is a unit of measure. factor converts an amount in the unit to
+// the base unit of its dimension (grams, millilitres or pieces).
+// Units that are not known, like "clove" or "pinch", keep their symbol
+// but have an unknown dimension and are never converted.
+type Unit struct {
+	symbol    string
+	dimension Dimension
+	system    System
+	factor    float64
+}
+
+var (
+	Milligram  = Unit{symbol: "mg", dimension: Mass, system: Metric, factor: 0.001}
+	Gram       = Unit{symbol: "g", dimension: Mass, system: Metric, factor: 1}
+	Kilogram   = Unit{symbol: "kg", dimension: Mass, system: Metric, factor: 1000}
+	Ounce      = Unit{symbol: "oz", dimension: Mass, system: Imperial, factor: 28.349523125}
+	Pound      = Unit{symbol: "lb", dimension: Mass, system: Imperial, factor: 453.59237}
+	Millilitre = Unit{symbol: "ml", dimension: Volume, system: Metric, factor: 1}
+	Litre      = Unit{symbol: "l", dimension: Volume, system: Metric, factor: 1000}
+	Teaspoon   = Unit{symbol: "tsp", dimension: Volume, system: Imperial, factor: 4.92892159375}
+	Tablespoon = Unit{symbol: "tbsp", dimension: Volume, system: Imperial, factor: 14.78676478125}
+	FluidOunce = Unit{symbol: "fl oz", dimension: Volume, system: Imperial, factor: 29.5735295625}
+	Cup        = Unit{symbol: "cup", dimension: Volume, system: Imperial, factor: 236.5882365}
+	Pint       = Unit{symbol: "pint", dimension: Volume, system: Imperial, factor: 473.176473}
+	Quart      = Unit{symbol: "quart", dimension: Volume, system: Imperial, factor: 946.352946}
+	Piece      = Unit{symbol: "", dimension: Count, factor: 1}
+)
+
+var aliases = map[string]Unit{
+	"mg":          Milligram,
+	"milligram":   Milligram,
+	"milligrams":  Milligram,
+	"g":           Gram,
+	"gr":          Gram,
+	"gram":        Gram,
+	"grams":       Gram,
+	"kg":          Kilogram,
+	"kilogram":    Kilogram,
+	"kilograms":   Kilogram,
+	"oz":          Ounce,
+	"ounce":       Ounce,
+	"ounces":      Ounce,
+	"lb":          Pound,
+	"lbs":         Pound,
+	"pound":       Pound,
+	"pounds":      Pound,
+	"ml":          Millilitre,
+	"millilitre":  Millilitre,
+	"millilitres": Millilitre,
+	"milliliter":  Millilitre,
+	"milliliters": Millilitre,
+	"l":           Litre,
+	"litre":       Litre,
+	"litres":      Litre,
+	"liter":       Litre,
+	"liters":      Litre,
+	"tsp":         Teaspoon,
+	"teaspoon":    Teaspoon,
+	"teaspoons":   Teaspoon,
+	"tbsp":        Tablespoon,
+	"tablespoon":  Tablespoon,
+	"tablespoons": Tablespoon,
+	"fl oz":       FluidOunce,
+	"floz":        FluidOunce,
+	"fluid ounce": FluidOunce,
+	"cup":         Cup,
+	"cups":        Cup,
+	"pint":        Pint,
+	"pints":       Pint,
+	"quart":       Quart,
+	"quarts":      Quart,
+	"":            Piece,
+	"pc":          Piece,
+	"pcs":         Piece,
+	"piece":       Piece,
+	"pieces":      Piece,
+	"whole":       Piece,
+}
+
+// Parse never fails: unrecognised symbols become a Unit of unknown
+// dimension that is carried through unchanged.
+func Parse(s string) Unit {
+	s = strings.TrimSpace(s)
+	if u, ok := aliases[strings.ToLower(s)]; ok {
+		return u
+	}
+	return Unit{symbol: s}
+}
+
+func (u Unit) String() string {
+	return u.symbol
+}
+
+func (u Unit) Dimension() Dimension {
+	return u.dimension
+}
+
+func (u Unit) System() System {
+	return u.system
+}
+
+// Quantity is an amount of something in a unit.
+type Quantity struct {
+	Amount float64
+	Unit   Unit
+}
+
+// Convert expresses q in unit to. density is in grams per millilitre
+// and is only consulted when converting between volume and mass.
+func (q Quantity) Convert(to Unit, density float64) (Quantity, error) {
+	from := q.Unit
+	if from == to {
+		return q, nil
+	}
+	if from.dimension == UnknownDimension || to.dimension == UnknownDimension {
+		return q, ErrIncompatibleUnits
+	}
+	base := q.Amount * from.factor
+	switch {
+	case from.dimension == to.dimension:
+	case from.dimension == Volume && to.dimension == Mass:
+		if density <= 0 {
+			return q, ErrDensityRequired
+		}
+		base = base * density
+	case from.dimension == Mass && to.dimension == Volume:
+		if density <= 0 {
+			return q, ErrDensityRequired
+		}
+		base = base / density
+	default:
+		return q, ErrIncompatibleUnits
+	}
+	return Quantity{Amount: base / to.factor, Unit: to}, nil
+}
+
+// InSystem renders q in the given system, picking the most readable
+// unit for the amount. Metric cooks weigh rather than measure, so
+// volumes become masses whenever the density is known. Quantities that
+// cannot be converted are returned unchanged.
+func (q Quantity) InSystem(sys System, density float64) Quantity {
+	if sys == UnknownSystem {
+		return q
+	}
+	target := q.Unit.dimension
+	if sys == Metric && target == Volume && density > 0 {
+		target = Mass
+	}
+	var base Unit
+	switch {
+	case target == Mass && sys == Metric:
+		base = Gram
+	case target == Mass && sys == Imperial:
+		base = Ounce
+	case target == Volume && sys == Metric:
+		base = Millilitre
+	case target == Volume && sys == Imperial:
+		base = Teaspoon
+	default:
+		return q
+	}
+	converted, err := q.Convert(base, density)
+	if err != nil {
+		return q
+	}
+	return converted.Normalize().Round()
+}
+
+// ladders list the units of a dimension within a system, largest
+// first, along with the smallest amount worth showing in that unit.
+var ladders = map[Dimension]map[System][]struct {
+	unit Unit
+	min  float64
+}{
+	Mass: {
+		Metric:   {{Kilogram, 1}, {Gram, 1}, {Milligram, 0}},
+		Imperial: {{Pound, 1}, {Ounce, 0}},
+	},
+	Volume: {
+		Metric:   {{Litre, 1}, {Millilitre, 0}},
+		Imperial: {{Cup, 0.25}, {Tablespoon, 1}, {Teaspoon, 0}},
+	},
+}
+
+// Normalize promotes or demotes q to the largest unit of the same
+// system in which the amount still reads naturally, so 48 tsp becomes
+// 1 cup and 1500 g becomes 1.5 kg.
+func (q Quantity) Normalize() Quantity {
+	ladder, ok := ladders[q.Unit.dimension][q.Unit.system]
+	if !ok {
+		return q
+	}
+	base := q.Amount * q.Unit.factor
+	for _, rung := range ladder {
+		amount := base / rung.unit.factor
+		if amount >= rung.min {
+			return Quantity{Amount: amount, Unit: rung.unit}
+		}
+	}
+	return q
+}
+
+// Round trims q to the precision a cook would measure with: imperial
+// amounts to the nearest eighth, small metric amounts to a tenth and
+// larger ones to whole units.
+func (q Quantity) Round() Quantity {
+	switch {
+	case q.Unit.dimension == UnknownDimension || q.Unit.dimension == Count:
+		return q
+	case q.Unit.system == Imperial:
+		q.Amount = roundTo(q.Amount, 0.125)
+	case q.Unit == Kilogram || q.Unit == Litre:
+		q.Amount = roundTo(q.Amount, 0.01)
+	case q.Amount >= 10:
+		q.Amount = math.Round(q.Amount)
+	default:
+		q.Amount = roundTo(q.Amount, 0.1)
+	}
+	return q
+}
+
+// Scale multiplies q by factor and tidies the result for a cook:
+// measured amounts are promoted to a readable unit and rounded, count
+// based items like eggs are rounded to whole pieces (never fewer than
+// one) and unknown units like pinches to the nearest quarter.
+func (q Quantity) Scale(factor float64) Quantity {
+	q.Amount = q.Amount * factor
+	switch q.Unit.dimension {
+	case Count:
+		q.Amount = math.Max(1, math.Round(q.Amount))
+		return q
+	case UnknownDimension:
+		q.Amount = roundTo(q.Amount, 0.25)
+		return q
+	default:
+		return q.Normalize().Round()
+	}
+}
+
+func roundTo(amount, step float64) float64 {
+	rounded := math.Round(amount/step) * step
+	if rounded == 0 && amount > 0 {
+		return step
+	}
+	return rounded
+}
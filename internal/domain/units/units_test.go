@@ -0,0 +1,87 @@
+package units
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	assert.Equal(t, Gram, Parse("Grams"))
+	assert.Equal(t, Tablespoon, Parse(" tbsp "))
+	assert.Equal(t, Piece, Parse(""))
+
+	clove := Parse("clove")
+	assert.Equal(t, UnknownDimension, clove.Dimension())
+	assert.Equal(t, "clove", clove.String())
+}
+
+func TestConvert(t *testing.T) {
+	q, err := Quantity{Amount: 2, Unit: Pound}.Convert(Gram, 0)
+	require.NoError(t, err)
+	assert.InDelta(t, 907.18, q.Amount, 0.01)
+
+	_, err = Quantity{Amount: 1, Unit: Cup}.Convert(Gram, 0)
+	assert.ErrorIs(t, err, ErrDensityRequired)
+
+	q, err = Quantity{Amount: 1, Unit: Cup}.Convert(Gram, 0.53)
+	require.NoError(t, err)
+	assert.InDelta(t, 125.39, q.Amount, 0.01)
+
+	_, err = Quantity{Amount: 1, Unit: Piece}.Convert(Gram, 1)
+	assert.ErrorIs(t, err, ErrIncompatibleUnits)
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, Quantity{Amount: 1, Unit: Cup}, Quantity{Amount: 48, Unit: Teaspoon}.Normalize().Round())
+	assert.Equal(t, Quantity{Amount: 1, Unit: Tablespoon}, Quantity{Amount: 3, Unit: Teaspoon}.Normalize().Round())
+	assert.Equal(t, Quantity{Amount: 1.5, Unit: Kilogram}, Quantity{Amount: 1500, Unit: Gram}.Normalize().Round())
+	assert.Equal(t, Quantity{Amount: 250, Unit: Millilitre}, Quantity{Amount: 0.25, Unit: Litre}.Normalize().Round())
+}
+
+func TestInSystem(t *testing.T) {
+	flour := Quantity{Amount: 2, Unit: Cup}
+	assert.Equal(t, Quantity{Amount: 251, Unit: Gram}, flour.InSystem(Metric, 0.53))
+	assert.Equal(t, Quantity{Amount: 473, Unit: Millilitre}, flour.InSystem(Metric, 0))
+
+	butter := Quantity{Amount: 227, Unit: Gram}
+	assert.Equal(t, Quantity{Amount: 8, Unit: Ounce}, butter.InSystem(Imperial, 0.91))
+
+	eggs := Quantity{Amount: 3, Unit: Piece}
+	assert.Equal(t, eggs, eggs.InSystem(Imperial, 0))
+
+	pinch := Quantity{Amount: 1, Unit: Parse("pinch")}
+	assert.Equal(t, pinch, pinch.InSystem(Metric, 0))
+}
+
+func TestTemperature(t *testing.T) {
+	unit, err := ParseTemperatureUnit("Gas Mark")
+	require.NoError(t, err)
+	assert.Equal(t, GasMark, unit)
+	_, err = ParseTemperatureUnit("kelvin")
+	assert.ErrorIs(t, err, ErrUnknownTemperatureUnit)
+
+	moderate := Temperature{Value: 180, Unit: Celsius}
+	assert.Equal(t, Temperature{Value: 355, Unit: Fahrenheit}, moderate.Convert(Fahrenheit))
+	assert.Equal(t, Temperature{Value: 4, Unit: GasMark}, moderate.Convert(GasMark))
+	assert.Equal(t, Temperature{Value: 175, Unit: Celsius}, Temperature{Value: 4, Unit: GasMark}.Convert(Celsius))
+	assert.Equal(t, Temperature{Value: 0.5, Unit: GasMark}, Temperature{Value: 120, Unit: Celsius}.Convert(GasMark))
+	assert.InDelta(t, 162.8, Temperature{Value: 3, Unit: GasMark}.Celsius(), 0.1)
+	assert.Equal(t, Temperature{Value: 430, Unit: Fahrenheit}, Temperature{Value: 220, Unit: Celsius}.InSystem(Imperial))
+	assert.Equal(t, moderate, moderate.InSystem(UnknownSystem))
+	assert.Equal(t, Temperature{}, Temperature{}.Convert(Fahrenheit))
+	assert.Equal(t, "180°C", moderate.String())
+	assert.Equal(t, "gas mark 0.25", Temperature{Value: 0.25, Unit: GasMark}.String())
+
+	_, err = NewTemperature(11, GasMark)
+	assert.ErrorIs(t, err, ErrInvalidTemperature)
+	_, err = NewTemperature(-274, Celsius)
+	assert.ErrorIs(t, err, ErrInvalidTemperature)
+	_, err = NewTemperature(-460, Fahrenheit)
+	assert.ErrorIs(t, err, ErrInvalidTemperature)
+	_, err = NewTemperature(-273.15, Celsius)
+	assert.NoError(t, err)
+	_, err = NewTemperature(180, UnknownTemperatureUnit)
+	assert.ErrorIs(t, err, ErrUnknownTemperatureUnit)
+}
@@ -1,12 +1,34 @@
 package domain
 
-import "github.com/google/uuid"
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidVariation = errors.New("variation needs an item and a description")
 
 type Variation struct {
 	item      uuid.UUID
 	variation string
 }
 
+func NewVariation(item uuid.UUID, variation string) (Variation, error) {
+	variation = strings.TrimSpace(variation)
+	if item == uuid.Nil || variation == "" {
+		return Variation{}, ErrInvalidVariation
+	}
+	return Variation{
+		item:      item,
+		variation: variation,
+	}, nil
+}
+
+func (v Variation) Item() uuid.UUID {
+	return v.item
+}
+
 func (v Variation) Variation() string {
 	return v.variation
 }
@@ -0,0 +1,205 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Completion is a text that can be suggested, such as a recipe or an
+// ingredient name. Kind says what it names.
+type Completion struct {
+	Text string
+	Kind string
+}
+
+type Suggestion struct {
+	Completion
+	// Distance is the number of edits between the prefix typed and the
+	// closest start of the text, 0 for an exact prefix.
+	Distance int
+}
+
+type completion struct {
+	Completion
+	// starts are the folded text from the start of each word, so a
+	// prefix can match any word and not just the first
+	starts []string
+	// refs counts what contributed the completion, so it stays until
+	// the last of them is removed
+	refs int
+}
+
+// completionKey identifies a completion by its kind and text, ignoring
+// case.
+type completionKey [2]string
+
+func keyOf(c Completion) completionKey {
+	return completionKey{c.Kind, strings.ToLower(c.Text)}
+}
+
+// Completer suggests completions for a prefix, tolerating typos. The
+// completions are contributed by documents, which can be put again or
+// removed as they change. It is safe for concurrent use.
+type Completer struct {
+	mu          sync.RWMutex
+	completions map[completionKey]*completion
+	// docs holds the completions each document contributed
+	docs map[string][]Completion
+}
+
+// NewCompleter builds a completer from completions that belong to no
+// document. Completions of the same kind and text, ignoring case, are
+// merged.
+func NewCompleter(completions []Completion) *Completer {
+	c := &Completer{
+		completions: make(map[completionKey]*completion),
+		docs:        make(map[string][]Completion),
+	}
+	for _, comp := range completions {
+		c.add(comp)
+	}
+	return c
+}
+
+// Put replaces the completions contributed by the document with the
+// given id.
+func (c *Completer) Put(id string, completions []Completion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(id)
+	for _, comp := range completions {
+		c.add(comp)
+	}
+	c.docs[id] = completions
+}
+
+// Remove drops the completions of the document with the given id, if
+// it contributed any.
+func (c *Completer) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(id)
+}
+
+func (c *Completer) add(comp Completion) {
+	key := keyOf(comp)
+	if existing, ok := c.completions[key]; ok {
+		existing.refs++
+		return
+	}
+	var starts []string
+	for _, t := range tokenize(comp.Text) {
+		starts = append(starts, Fold(comp.Text[t.start:]))
+	}
+	if len(starts) == 0 {
+		return
+	}
+	c.completions[key] = &completion{Completion: comp, starts: starts, refs: 1}
+}
+
+func (c *Completer) remove(id string) {
+	for _, comp := range c.docs[id] {
+		key := keyOf(comp)
+		existing, ok := c.completions[key]
+		if !ok {
+			continue
+		}
+		existing.refs--
+		if existing.refs <= 0 {
+			delete(c.completions, key)
+		}
+	}
+	delete(c.docs, id)
+}
+
+// maxDistance is how many typos a prefix of n characters may contain.
+// Short prefixes have to be exact or almost anything would match.
+func maxDistance(n int) int {
+	switch {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Complete returns up to limit completions for prefix, closest first,
+// then shortest. The prefix is folded like the texts, so "creme"
+// completes "Crème fraîche".
+func (c *Completer) Complete(prefix string, limit int) []Suggestion {
+	prefix = Fold(strings.TrimSpace(prefix))
+	if prefix == "" {
+		return nil
+	}
+	allowed := maxDistance(utf8.RuneCountInString(prefix))
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var suggestions []Suggestion
+	for _, comp := range c.completions {
+		best := allowed + 1
+		for _, start := range comp.starts {
+			if d := prefixDistance(prefix, start, allowed); d < best {
+				best = d
+			}
+		}
+		if best <= allowed {
+			suggestions = append(suggestions, Suggestion{Completion: comp.Completion, Distance: best})
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if len(a.Text) != len(b.Text) {
+			return len(a.Text) < len(b.Text)
+		}
+		return a.Text < b.Text
+	})
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// prefixDistance is the smallest Levenshtein distance between prefix
+// and any prefix of text. It gives up early, returning max+1, once no
+// alignment can stay within max.
+func prefixDistance(prefix, text string, max int) int {
+	p, t := []rune(prefix), []rune(text)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	// prev[j] is the distance between the prefix read so far and the
+	// first j runes of text; the last row is minimised over j so text
+	// may carry on past the prefix for free
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(p); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if p[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, curr = curr, prev
+	}
+	best := prev[0]
+	for _, d := range prev {
+		best = min(best, d)
+	}
+	return best
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"strings"
+)
+
+const (
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+	// words of context kept either side of the first match
+	snippetContext = 6
+)
+
+// Highlight returns a snippet of text around the first match of q with
+// every matching word wrapped in <mark></mark>. Text is cut down to a
+// window of words around that match and elided with "…". ok is false
+// when nothing in text matches.
+func Highlight(text string, q Query) (snippet string, ok bool) {
+	tokens := tokenize(text)
+	stems := q.stems()
+	first := -1
+	for i, t := range tokens {
+		if stems[t.stem] {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	from, to := first-snippetContext, first+snippetContext
+	if from < 0 {
+		from = 0
+	}
+	if to >= len(tokens) {
+		to = len(tokens) - 1
+	}
+	start, end := tokens[from].start, tokens[to].end
+	if from == 0 {
+		start = 0
+	}
+	if to == len(tokens)-1 {
+		end = len(text)
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	last := start
+	for _, t := range tokens[from : to+1] {
+		if !stems[t.stem] {
+			continue
+		}
+		sb.WriteString(text[last:t.start])
+		sb.WriteString(markOpen)
+		sb.WriteString(text[t.start:t.end])
+		sb.WriteString(markClose)
+		last = t.end
+	}
+	sb.WriteString(text[last:end])
+	if end < len(text) {
+		sb.WriteString("…")
+	}
+	return sb.String(), true
+}
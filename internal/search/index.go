@@ -0,0 +1,295 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// BM25 tuning, the usual defaults.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Field is a named piece of text of a document. Weight scales how much
+// a match in the field counts towards the score, so a word in a title
+// can count for more than the same word in a description.
+type Field struct {
+	Name   string
+	Text   string
+	Weight float64
+}
+
+type Document struct {
+	ID     string
+	Fields []Field
+}
+
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// posting is where a stem occurs in one field of one document.
+type posting struct {
+	field     int
+	positions []int
+}
+
+type indexedDocument struct {
+	weights []float64
+	lengths []int
+	// stems per field in order, used to match phrases
+	stems [][]string
+}
+
+// Index is an in-memory inverted index ranking matches by BM25. It is
+// safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[string][]posting
+	docs     map[string]indexedDocument
+	// total tokens per field name, for the average field length
+	totals map[int]int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string][]posting),
+		docs:     make(map[string]indexedDocument),
+		totals:   make(map[int]int),
+	}
+}
+
+// Put indexes doc, replacing any document with the same ID.
+func (idx *Index) Put(doc Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(doc.ID)
+
+	indexed := indexedDocument{
+		weights: make([]float64, len(doc.Fields)),
+		lengths: make([]int, len(doc.Fields)),
+		stems:   make([][]string, len(doc.Fields)),
+	}
+	for f, field := range doc.Fields {
+		tokens := tokenize(field.Text)
+		indexed.weights[f] = field.Weight
+		indexed.lengths[f] = len(tokens)
+		idx.totals[f] += len(tokens)
+		stems := make([]string, len(tokens))
+		positions := make(map[string][]int)
+		for i, t := range tokens {
+			stems[i] = t.stem
+			positions[t.stem] = append(positions[t.stem], i)
+		}
+		indexed.stems[f] = stems
+		for stem, ps := range positions {
+			byDoc, ok := idx.postings[stem]
+			if !ok {
+				byDoc = make(map[string][]posting)
+				idx.postings[stem] = byDoc
+			}
+			byDoc[doc.ID] = append(byDoc[doc.ID], posting{field: f, positions: ps})
+		}
+	}
+	idx.docs[doc.ID] = indexed
+}
+
+// Remove drops the document with the given ID, if indexed.
+func (idx *Index) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *Index) remove(id string) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for f, stems := range doc.stems {
+		idx.totals[f] -= doc.lengths[f]
+		for _, stem := range stems {
+			byDoc := idx.postings[stem]
+			delete(byDoc, id)
+			if len(byDoc) == 0 {
+				delete(idx.postings, stem)
+			}
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// containing is the set of documents with stem somewhere.
+func (idx *Index) containing(stem string) map[string]bool {
+	ids := make(map[string]bool, len(idx.postings[stem]))
+	for id := range idx.postings[stem] {
+		ids[id] = true
+	}
+	return ids
+}
+
+// Search returns up to limit documents matching every term and phrase
+// of q, best first. A limit of zero or less returns every match.
+func (idx *Index) Search(q Query, limit int) []Hit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	stems := make([]string, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		stems = append(stems, t.Stem)
+	}
+	for _, p := range q.Phrases {
+		for _, t := range p {
+			stems = append(stems, t.Stem)
+		}
+	}
+
+	// candidates have to contain every stem somewhere
+	var candidates map[string]bool
+	narrow := func(ids map[string]bool) bool {
+		next := make(map[string]bool, len(ids))
+		for id := range ids {
+			if candidates == nil || candidates[id] {
+				next[id] = true
+			}
+		}
+		candidates = next
+		return len(candidates) > 0
+	}
+	for _, stem := range stems {
+		if !narrow(idx.containing(stem)) {
+			return nil
+		}
+	}
+	// and every stem of one alternative of each group of synonyms
+	for _, group := range q.Synonyms {
+		ids := make(map[string]bool)
+		for _, alt := range group {
+			var all map[string]bool
+			for _, t := range alt {
+				byStem := idx.containing(t.Stem)
+				if all != nil {
+					for id := range all {
+						if !byStem[id] {
+							delete(all, id)
+						}
+					}
+					continue
+				}
+				all = byStem
+			}
+			for id := range all {
+				ids[id] = true
+			}
+			for _, t := range alt {
+				stems = append(stems, t.Stem)
+			}
+		}
+		if !narrow(ids) {
+			return nil
+		}
+	}
+
+	n := float64(len(idx.docs))
+	var hits []Hit
+	for id := range candidates {
+		doc := idx.docs[id]
+		if !matchPhrases(doc.stems, q.Phrases) {
+			continue
+		}
+		var score float64
+		for _, stem := range stems {
+			byDoc := idx.postings[stem]
+			idf := math.Log(1 + (n-float64(len(byDoc))+0.5)/(float64(len(byDoc))+0.5))
+			for _, p := range byDoc[id] {
+				avg := float64(idx.totals[p.field]) / n
+				if avg == 0 {
+					avg = 1
+				}
+				tf := float64(len(p.positions))
+				norm := tf * (k1 + 1) / (tf + k1*(1-b+b*float64(doc.lengths[p.field])/avg))
+				score += doc.weights[p.field] * idf * norm
+			}
+		}
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Match reports whether doc contains every term and phrase of q and
+// one alternative of each group of synonyms. It is
+// for stores whose own text search is looser than the index's, to hold
+// them to the same semantics.
+func Match(doc Document, q Query) bool {
+	fields := make([][]string, len(doc.Fields))
+	found := make(map[string]bool)
+	for f, field := range doc.Fields {
+		for _, t := range tokenize(field.Text) {
+			fields[f] = append(fields[f], t.stem)
+			found[t.stem] = true
+		}
+	}
+	for _, t := range q.Terms {
+		if !found[t.Stem] {
+			return false
+		}
+	}
+	for _, group := range q.Synonyms {
+		if !slices.ContainsFunc(group, func(alt []Term) bool {
+			return !slices.ContainsFunc(alt, func(t Term) bool { return !found[t.Stem] })
+		}) {
+			return false
+		}
+	}
+	return matchPhrases(fields, q.Phrases)
+}
+
+// matchPhrases reports whether every phrase occurs, in order, within a
+// single field.
+func matchPhrases(fields [][]string, phrases [][]Term) bool {
+	for _, phrase := range phrases {
+		found := false
+		for _, stems := range fields {
+			if phraseAt(stems, phrase) >= 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// phraseAt returns the position of the first occurrence of phrase in
+// stems or -1.
+func phraseAt(stems []string, phrase []Term) int {
+	for i := 0; i+len(phrase) <= len(stems); i++ {
+		matched := true
+		for j, t := range phrase {
+			if stems[i+j] != t.Stem {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,78 @@
+package search
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrEmptyQuery = errors.New("search query has no words")
+
+// Query is a parsed search. Every term and every phrase has to match
+// for a document to be found, and one alternative of every group of
+// synonyms. Words are kept both as typed and as stems, as stores with
+// their own stemming want the former.
+type Query struct {
+	Terms    []Term
+	Phrases  [][]Term
+	Synonyms []Synonyms
+}
+
+// Synonyms are alternative ways of saying the same thing. Each one is
+// a run of words that all have to match for it to.
+type Synonyms [][]Term
+
+type Term struct {
+	Word string
+	Stem string
+}
+
+// ParseQuery splits q into terms and "quoted phrases". Stop words are
+// dropped from loose terms but kept in phrases, where they matter.
+func ParseQuery(q string) (Query, error) {
+	var query Query
+	parts := strings.Split(q, `"`)
+	for i, part := range parts {
+		tokens := tokenize(part)
+		// odd parts sit between quotes; an unbalanced last quote is
+		// treated as if it were closed
+		if i%2 == 1 && len(tokens) > 1 {
+			phrase := make([]Term, 0, len(tokens))
+			for _, t := range tokens {
+				phrase = append(phrase, Term{Word: t.text, Stem: t.stem})
+			}
+			query.Phrases = append(query.Phrases, phrase)
+			continue
+		}
+		for _, t := range tokens {
+			if stopWords[t.text] && i%2 == 0 {
+				continue
+			}
+			query.Terms = append(query.Terms, Term{Word: t.text, Stem: t.stem})
+		}
+	}
+	if len(query.Terms) == 0 && len(query.Phrases) == 0 {
+		return query, ErrEmptyQuery
+	}
+	return query, nil
+}
+
+// stems is every stem the query looks for, used for highlighting.
+func (q Query) stems() map[string]bool {
+	stems := make(map[string]bool)
+	for _, t := range q.Terms {
+		stems[t.Stem] = true
+	}
+	for _, p := range q.Phrases {
+		for _, t := range p {
+			stems[t.Stem] = true
+		}
+	}
+	for _, group := range q.Synonyms {
+		for _, alt := range group {
+			for _, t := range alt {
+				stems[t.Stem] = true
+			}
+		}
+	}
+	return stems
+}
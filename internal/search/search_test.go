@@ -0,0 +1,195 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStem(t *testing.T) {
+	cases := map[string]string{
+		"tomatoes": "tomato",
+		"chopped":  "chop",
+		"chopping": "chop",
+		"chops":    "chop",
+		"baked":    "bake",
+		"baking":   "bake",
+		"berries":  "berri",
+		"berry":    "berri",
+		"glass":    "glass",
+		"agreed":   "agre",
+		"olives":   "oliv",
+		"leaves":   "leaf",
+		"leaf":     "leaf",
+	}
+	for word, stem := range cases {
+		assert.Equal(t, stem, Stem(word), word)
+	}
+}
+
+func TestFold(t *testing.T) {
+	assert.Equal(t, "creme fraiche", Fold("Crème Fraîche"))
+	assert.Equal(t, "fig", Fold("ﬁg"))
+	assert.Equal(t, "strasse", Fold("STRASSE"))
+	assert.Equal(t, []string{"jalapeno", "pepper"}, Stems("Jalapeños, peppers"))
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery(`the Chopped tomatoes "olive oil"`)
+	require.NoError(t, err)
+	assert.Equal(t, []Term{{Word: "chopped", Stem: "chop"}, {Word: "tomatoes", Stem: "tomato"}}, q.Terms)
+	assert.Equal(t, [][]Term{{{Word: "olive", Stem: "oliv"}, {Word: "oil", Stem: "oil"}}}, q.Phrases)
+
+	_, err = ParseQuery(` "" the `)
+	assert.ErrorIs(t, err, ErrEmptyQuery)
+}
+
+func doc(id, name, description string) Document {
+	return Document{ID: id, Fields: []Field{
+		{Name: "name", Text: name, Weight: 3},
+		{Name: "description", Text: description, Weight: 1},
+	}}
+}
+
+func TestIndex(t *testing.T) {
+	idx := NewIndex()
+	idx.Put(doc("1", "Tomato soup", "Roasted tomatoes blended with olive oil"))
+	idx.Put(doc("2", "Bruschetta", "Bread topped with chopped tomato and oil of olives"))
+	idx.Put(doc("3", "Pancakes", "Fluffy and sweet"))
+
+	search := func(s string) []string {
+		q, err := ParseQuery(s)
+		require.NoError(t, err)
+		var ids []string
+		for _, h := range idx.Search(q, 0) {
+			ids = append(ids, h.ID)
+		}
+		return ids
+	}
+
+	// a match in the name outranks one in the description
+	assert.Equal(t, []string{"1", "2"}, search("tomatoes"))
+	assert.Equal(t, []string{"2"}, search("chopping tomato"))
+	assert.Equal(t, []string{"1"}, search(`"olive oil"`))
+	assert.Empty(t, search("tomato pancakes"))
+
+	// one alternative of a group of synonyms is enough
+	q, err := ParseQuery("oil")
+	require.NoError(t, err)
+	q.Synonyms = []Synonyms{{terms(t, "fluffy"), terms(t, "blended tomato")}}
+	var ids []string
+	for _, h := range idx.Search(q, 0) {
+		ids = append(ids, h.ID)
+	}
+	assert.Equal(t, []string{"1"}, ids)
+	q.Terms = nil
+	assert.Len(t, idx.Search(q, 0), 2)
+
+	idx.Put(doc("1", "Leek soup", "Leeks and potatoes"))
+	assert.Equal(t, []string{"2"}, search("tomato"))
+	idx.Remove("2")
+	assert.Empty(t, search("tomato"))
+	assert.Equal(t, []string{"1"}, search("leek"))
+}
+
+func TestMatch(t *testing.T) {
+	d := doc("1", "Tomato soup", "Roasted tomatoes blended with olive oil")
+	match := func(s string) bool {
+		q, err := ParseQuery(s)
+		require.NoError(t, err)
+		return Match(d, q)
+	}
+	assert.True(t, match("roast tomato"))
+	assert.True(t, match(`"olive oils"`))
+	assert.False(t, match(`"oil olive"`))
+	assert.False(t, match("tomato basil"))
+
+	q, err := ParseQuery("soup")
+	require.NoError(t, err)
+	q.Synonyms = []Synonyms{{terms(t, "basil"), terms(t, "olive oil")}}
+	assert.True(t, Match(d, q))
+	q.Synonyms = []Synonyms{{terms(t, "basil"), terms(t, "olive butter")}}
+	assert.False(t, Match(d, q))
+}
+
+func terms(t *testing.T, s string) []Term {
+	t.Helper()
+	q, err := ParseQuery(s)
+	require.NoError(t, err)
+	return q.Terms
+}
+
+func TestHighlight(t *testing.T) {
+	q, err := ParseQuery("tomato")
+	require.NoError(t, err)
+
+	snippet, ok := Highlight("Roasted tomatoes, blended.", q)
+	require.True(t, ok)
+	assert.Equal(t, "Roasted <mark>tomatoes</mark>, blended.", snippet)
+
+	snippet, ok = Highlight("one two three four five six seven eight tomato nine ten eleven twelve thirteen fourteen fifteen", q)
+	require.True(t, ok)
+	assert.Equal(t, "…three four five six seven eight <mark>tomato</mark> nine ten eleven twelve thirteen fourteen…", snippet)
+
+	// accents are matched but kept in the snippet
+	q, err = ParseQuery("creme")
+	require.NoError(t, err)
+	snippet, ok = Highlight("A spoon of crème fraîche", q)
+	require.True(t, ok)
+	assert.Equal(t, "A spoon of <mark>crème</mark> fraîche", snippet)
+
+	_, ok = Highlight("Pancakes", q)
+	assert.False(t, ok)
+}
+
+func TestComplete(t *testing.T) {
+	c := NewCompleter([]Completion{
+		{Text: "Tomato soup", Kind: "recipe"},
+		{Text: "tomato", Kind: "ingredient"},
+		{Text: "Tomatillo salsa", Kind: "recipe"},
+		{Text: "Tomato Soup", Kind: "recipe"},
+		{Text: "Potato gratin", Kind: "recipe"},
+		{Text: "Miso soup", Kind: "recipe"},
+		{Text: "Crème brûlée", Kind: "recipe"},
+	})
+	texts := func(prefix string, limit int) []string {
+		var texts []string
+		for _, s := range c.Complete(prefix, limit) {
+			texts = append(texts, s.Text)
+		}
+		return texts
+	}
+
+	assert.Equal(t, []string{"tomato", "Tomato soup", "Tomatillo salsa"}, texts("toma", 0))
+	// the same recipe name in different case is merged
+	assert.Equal(t, []string{"Tomato soup", "tomato"}, texts("tomato s", 0))
+	// closer matches come first, then the shorter
+	assert.Equal(t, []string{"tomato", "Tomato soup", "Potato gratin", "Tomatillo salsa"}, texts("tonato", 0))
+	assert.Equal(t, []string{"tomato", "Tomato soup"}, texts("tomsto", 2))
+	assert.Equal(t, []string{"Miso soup", "Tomato soup"}, texts("soup", 0))
+	// short prefixes have to be exact
+	assert.Equal(t, []string{"Potato gratin"}, texts("po", 0))
+	assert.Empty(t, texts("pi", 0))
+	assert.Empty(t, texts("  ", 0))
+	// accents are folded on both sides
+	assert.Equal(t, []string{"Crème brûlée"}, texts("cre", 0))
+	assert.Equal(t, []string{"Crème brûlée"}, texts("crè", 0))
+	assert.Equal(t, []string{"Crème brûlée"}, texts("CRÈME BRU", 0))
+}
+
+func TestCompleterPutRemove(t *testing.T) {
+	c := NewCompleter(nil)
+	c.Put("1", []Completion{{Text: "Tomato soup", Kind: "recipe"}, {Text: "tomato", Kind: "ingredient"}})
+	c.Put("2", []Completion{{Text: "Tomato salad", Kind: "recipe"}, {Text: "Tomato", Kind: "ingredient"}})
+	assert.Len(t, c.Complete("tomato", 0), 3)
+
+	// putting a document again replaces what it contributed, and a
+	// completion another document shares stays
+	c.Put("1", []Completion{{Text: "Miso soup", Kind: "recipe"}})
+	assert.Len(t, c.Complete("tomato", 0), 2)
+
+	c.Remove("2")
+	assert.Empty(t, c.Complete("tomato", 0))
+	assert.Len(t, c.Complete("miso", 0), 1)
+}
@@ -0,0 +1,199 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// token is a word of a text along with where it was found, so matches
+// can be highlighted in the original text.
+type token struct {
+	text  string
+	stem  string
+	start int
+	end   int
+}
+
+// Fold brings text to the form words are compared in: compatibility
+// characters such as ligatures are spelt out, accents dropped and case
+// folded, so "Crème Fraîche" and "creme fraiche" read the same.
+func Fold(text string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, text)
+	if err != nil {
+		folded = text
+	}
+	return cases.Fold().String(folded)
+}
+
+// tokenize splits text into folded words of letters and digits.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := Fold(text[start:end])
+		tokens = append(tokens, token{text: word, stem: Stem(word), start: start, end: end})
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return tokens
+}
+
+// Stems returns the stems of the words of text in order, for comparing
+// texts that word the same thing differently.
+func Stems(text string) []string {
+	tokens := tokenize(text)
+	stems := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		stems = append(stems, t.stem)
+	}
+	return stems
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "into": true,
+	"is": true, "it": true, "of": true, "on": true, "or": true, "the": true,
+	"to": true, "with": true,
+}
+
+// irregularPlurals are the plurals common in recipes that stemming
+// would not bring back to their singular.
+var irregularPlurals = map[string]string{
+	"leaves": "leaf", "loaves": "loaf", "halves": "half", "calves": "calf",
+	"knives": "knife", "geese": "goose",
+}
+
+func isVowel(word string, i int) bool {
+	switch word[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return true
+	case 'y':
+		return i > 0 && !isVowel(word, i-1)
+	default:
+		return false
+	}
+}
+
+// measure counts the vowel-consonant sequences in word, the m of the
+// Porter stemmer.
+func measure(word string) int {
+	m := 0
+	prevVowel := false
+	for i := range len(word) {
+		v := isVowel(word, i)
+		if prevVowel && !v {
+			m++
+		}
+		prevVowel = v
+	}
+	return m
+}
+
+func hasVowel(word string) bool {
+	for i := range len(word) {
+		if isVowel(word, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func endsDoubleConsonant(word string) bool {
+	n := len(word)
+	return n >= 2 && word[n-1] == word[n-2] && !isVowel(word, n-1)
+}
+
+// endsCVC reports whether word ends consonant-vowel-consonant where
+// the last consonant is not w, x or y, as in "hop" or "bak".
+func endsCVC(word string) bool {
+	n := len(word)
+	if n < 3 || isVowel(word, n-3) || !isVowel(word, n-2) || isVowel(word, n-1) {
+		return false
+	}
+	switch word[n-1] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// Stem reduces an English word to its stem using steps 1 and 5a of the
+// Porter algorithm, which fold plurals, -ed/-ing forms and a trailing
+// e: that covers most of how recipes vary a word ("chopped", "chops",
+// "tomatoes") without over-stemming ingredient names.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	if singular, ok := irregularPlurals[word]; ok {
+		word = singular
+	}
+
+	// step 1a
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ies"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ss"):
+	case strings.HasSuffix(word, "s"):
+		word = word[:len(word)-1]
+	}
+
+	// step 1b
+	cleanup := false
+	switch {
+	case strings.HasSuffix(word, "eed"):
+		if measure(word[:len(word)-3]) > 0 {
+			word = word[:len(word)-1]
+		}
+	case strings.HasSuffix(word, "ed") && hasVowel(word[:len(word)-2]):
+		word = word[:len(word)-2]
+		cleanup = true
+	case strings.HasSuffix(word, "ing") && hasVowel(word[:len(word)-3]):
+		word = word[:len(word)-3]
+		cleanup = true
+	}
+	if cleanup {
+		switch {
+		case strings.HasSuffix(word, "at"), strings.HasSuffix(word, "bl"), strings.HasSuffix(word, "iz"):
+			word += "e"
+		case endsDoubleConsonant(word) && !strings.ContainsAny(word[len(word)-1:], "lsz"):
+			word = word[:len(word)-1]
+		case measure(word) == 1 && endsCVC(word):
+			word += "e"
+		}
+	}
+
+	// step 1c
+	if strings.HasSuffix(word, "y") && hasVowel(word[:len(word)-1]) {
+		word = word[:len(word)-1] + "i"
+	}
+
+	// step 5a
+	if strings.HasSuffix(word, "e") {
+		stem := word[:len(word)-1]
+		if m := measure(stem); m > 1 || m == 1 && !endsCVC(stem) {
+			word = stem
+		}
+	}
+	return word
+}
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/catalogue"
+	"github.com/bento01dev/cookbook/internal/domain/cuisine"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/domain/taxonomy"
+	"github.com/bento01dev/cookbook/internal/domain/units"
+	"github.com/bento01dev/cookbook/internal/services"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+// recipeErrResponse maps errors from the recipe service onto a status
+// code and error body, counting bad requests against endpoint.
+func recipeErrResponse(ctx context.Context, statsCollection *stats.StatsCollection, endpoint string, id string, err error) (int, errResponse) {
+	var paramErr *services.ParamError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.ErrorContext(ctx, "request exceeded timeout", "recipe_id", id, "endpoint", endpoint)
+		return http.StatusGatewayTimeout, errResponse{ErrCode: 50001, Msg: "service time out"}
+	case errors.Is(err, recipe.ErrRecipeNotFound):
+		slog.ErrorContext(ctx, "recipe not found for given id", "recipe_id", id)
+		return http.StatusNotFound, errResponse{ErrCode: 40401, Msg: fmt.Sprintf("recipe not found for id: %s", id)}
+	case errors.Is(err, recipe.ErrInvalidID):
+		slog.ErrorContext(ctx, "invalid id format", "recipe_id", id)
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusBadRequest, errResponse{ErrCode: 40001, Msg: fmt.Sprintf("invalid format for id: %s", id)}
+	case errors.As(err, &paramErr):
+		slog.ErrorContext(ctx, "invalid id format", "recipe_id", id, "param", paramErr.Param)
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusBadRequest, errResponse{ErrCode: 40001, Msg: paramErr.Error()}
+	case errors.Is(err, recipe.ErrRecipeConflict):
+		slog.ErrorContext(ctx, "recipe changed concurrently", "recipe_id", id)
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusConflict, errResponse{ErrCode: 40905, Msg: "recipe was changed by another request, retry"}
+	case errors.Is(err, recipe.ErrHasVariations),
+		errors.Is(err, recipe.ErrParentDeleted):
+		slog.ErrorContext(ctx, "recipe still linked to its variations", "recipe_id", id, "err", err.Error())
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusConflict, errResponse{ErrCode: 40906, Msg: err.Error()}
+	case errors.Is(err, cuisine.ErrCuisineNotFound):
+		slog.ErrorContext(ctx, "unknown cuisine", "recipe_id", id)
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusBadRequest, errResponse{ErrCode: 40003, Msg: "Unknown cuisine"}
+	case errors.Is(err, recipe.ErrInvalidItemName),
+		errors.Is(err, recipe.ErrInvalidServings),
+		errors.Is(err, recipe.ErrInvalidDuration),
+		errors.Is(err, recipe.ErrInvalidDietaryTag),
+		errors.Is(err, domain.ErrInvalidIngredient),
+		errors.Is(err, domain.ErrInvalidQuantity),
+		errors.Is(err, domain.ErrInvalidPrep),
+		errors.Is(err, domain.ErrInvalidStep),
+		errors.Is(err, domain.ErrInvalidStepTiming),
+		errors.Is(err, domain.ErrInvalidEquipment),
+		errors.Is(err, domain.ErrInvalidMethod),
+		errors.Is(err, domain.ErrImplausibleTemperature),
+		errors.Is(err, units.ErrInvalidTemperature),
+		errors.Is(err, domain.ErrInvalidVariation),
+		errors.Is(err, domain.ErrInvalidPairing),
+		errors.Is(err, catalogue.ErrEntryNotFound),
+		errors.Is(err, taxonomy.ErrCategoryNotFound),
+		errors.Is(err, recipe.ErrUnknownIngredient),
+		errors.Is(err, recipe.ErrStepNotFound),
+		errors.Is(err, recipe.ErrStepInUse),
+		errors.Is(err, recipe.ErrStepOrder),
+		errors.Is(err, recipe.ErrStepInherited),
+		errors.Is(err, recipe.ErrPairingExists),
+		errors.Is(err, recipe.ErrPairedNotFound):
+		slog.ErrorContext(ctx, "invalid recipe change", "recipe_id", id, "err", err.Error())
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusBadRequest, errResponse{ErrCode: 40007, Msg: err.Error()}
+	default:
+		slog.ErrorContext(ctx, "recipe request failed", "recipe_id", id, "err", err.Error())
+		statsCollection.InternalServerErrorInc(endpoint)
+		return http.StatusInternalServerError, errResponse{ErrCode: 50002, Msg: "Uncaught exception"}
+	}
+}
+
+// handleRecipeChange wires up the nested authoring endpoints. Each one
+// decodes its own request type and hands it to apply, which performs
+// the change through the recipe service.
+func handleRecipeChange[T any](
+	rs recipeService,
+	statsCollection *stats.StatsCollection,
+	endpoint string,
+	apply func(ctx context.Context, id string, req T) (recipe.Recipe, error),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		reqObj, err := decode[T](r)
+		if err != nil {
+			slog.ErrorContext(ctx, "parsing request object failed", "endpoint", endpoint)
+			statsCollection.BadRequestInc(endpoint)
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40002, Msg: "Issue in parsing request body"})
+			return
+		}
+
+		res, err := apply(ctx, id, reqObj)
+		if err != nil {
+			status, errRes := recipeErrResponse(ctx, statsCollection, endpoint, id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, endpoint)
+		if !ok {
+			return
+		}
+
+		statsCollection.StatusOkInc(endpoint)
+		statsCollection.ResponseTime(endpoint, time.Since(start).Milliseconds())
+		encode[recipeResponse](w, http.StatusOK, newRecipeResponse(res, cuisines))
+	})
+}
+
+func handleAddIngredient(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type request struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description"`
+		Type        int     `json:"type"`
+		Density     float64 `json:"density"`
+		Quantity    float64 `json:"quantity"`
+		Unit        string  `json:"unit"`
+		Note        string  `json:"note"`
+		// IngredientID picks a catalogue entry, in place of the
+		// name and the rest
+		IngredientID string `json:"ingredient_id"`
+	}
+
+	return handleRecipeChange(rs, statsCollection, "add_ingredient", func(ctx context.Context, id string, req request) (recipe.Recipe, error) {
+		if req.Type < domain.UnknownIngredient {
+			return recipe.Recipe{}, domain.ErrInvalidIngredient
+		}
+		ingredientID, err := catalogueID(req.IngredientID)
+		if err != nil {
+			return recipe.Recipe{}, err
+		}
+		ingredient := domain.Ingredient{
+			ID:          ingredientID,
+			Name:        req.Name,
+			Description: req.Description,
+			Type:        domain.IngredientType(req.Type),
+			Density:     req.Density,
+		}
+		return rs.AddIngredient(ctx, id, ingredient, req.Quantity, req.Unit, req.Note)
+	})
+}
+
+// handleAddPrep appends a prep step, or inserts it when the request
+// gives a position.
+func handleAddPrep(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type request struct {
+		IngredientID string `json:"ingredient_id"`
+		Action       string `json:"action"`
+		Position     *int   `json:"position"`
+	}
+
+	return handleRecipeChange(rs, statsCollection, "add_prep", func(ctx context.Context, id string, req request) (recipe.Recipe, error) {
+		if req.Position != nil {
+			return rs.InsertPrep(ctx, id, *req.Position, req.IngredientID, req.Action)
+		}
+		return rs.AddPrep(ctx, id, req.IngredientID, req.Action)
+	})
+}
+
+// handleAddStep appends a cooking step, or inserts it when the request
+// gives a position.
+func handleAddStep(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type request struct {
+		IngredientID    string          `json:"ingredient_id"`
+		Action          string          `json:"action"`
+		Temperature     float64         `json:"temperature"`
+		TemperatureUnit temperatureUnit `json:"temperature_unit"`
+		Method          method          `json:"method"`
+		ActiveMinutes   int             `json:"active_minutes"`
+		PassiveMinutes  int             `json:"passive_minutes"`
+		After           []int           `json:"after"`
+		Equipment       equipment       `json:"equipment"`
+		Position        *int            `json:"position"`
+	}
+
+	return handleRecipeChange(rs, statsCollection, "add_step", func(ctx context.Context, id string, req request) (recipe.Recipe, error) {
+		var temperature units.Temperature
+		if req.Temperature != 0 {
+			// temperatures are in Celsius unless said otherwise
+			temperature = units.Temperature{Value: req.Temperature, Unit: units.Celsius}
+			if req.TemperatureUnit.unit != units.UnknownTemperatureUnit {
+				temperature.Unit = req.TemperatureUnit.unit
+			}
+		}
+		details := services.StepDetails{
+			IngredientID: req.IngredientID,
+			Action:       req.Action,
+			Temperature:  temperature,
+			Method:       req.Method.ToDomain(),
+			Active:       time.Duration(req.ActiveMinutes) * time.Minute,
+			Passive:      time.Duration(req.PassiveMinutes) * time.Minute,
+			After:        req.After,
+			Equipment:    req.Equipment.ToDomain(),
+		}
+		if req.Position != nil {
+			return rs.InsertStep(ctx, id, *req.Position, details)
+		}
+		return rs.AddStep(ctx, id, details)
+	})
+}
+
+// handleAddVariation creates a variation of the recipe as a recipe of
+// its own, responding with the variation resolved against its parent.
+func handleAddVariation(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type swap struct {
+		IngredientID string `json:"ingredient_id"`
+		// SwapIngredientID picks the catalogue entry swapped in, in
+		// place of the name and the rest
+		SwapIngredientID string  `json:"swap_ingredient_id"`
+		Name             string  `json:"name"`
+		Description      string  `json:"description"`
+		Type             int     `json:"type"`
+		Density          float64 `json:"density"`
+		Quantity         float64 `json:"quantity"`
+		Unit             string  `json:"unit"`
+		Note             string  `json:"note"`
+	}
+	type request struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Swap        []swap   `json:"swap"`
+		Omit        []string `json:"omit"`
+	}
+
+	return handleRecipeChange(rs, statsCollection, "add_variation", func(ctx context.Context, id string, req request) (recipe.Recipe, error) {
+		swaps := make([]services.IngredientSwap, 0, len(req.Swap))
+		for _, s := range req.Swap {
+			if s.Type < domain.UnknownIngredient {
+				return recipe.Recipe{}, domain.ErrInvalidIngredient
+			}
+			swapID, err := catalogueID(s.SwapIngredientID)
+			if err != nil {
+				return recipe.Recipe{}, err
+			}
+			swaps = append(swaps, services.IngredientSwap{
+				IngredientID: s.IngredientID,
+				Ingredient: domain.Ingredient{
+					ID:          swapID,
+					Name:        s.Name,
+					Description: s.Description,
+					Type:        domain.IngredientType(s.Type),
+					Density:     s.Density,
+				},
+				Amount: s.Quantity,
+				Unit:   s.Unit,
+				Note:   s.Note,
+			})
+		}
+		return rs.CreateVariation(ctx, id, req.Name, req.Description, swaps, req.Omit)
+	})
+}
+
+func handleAddPairing(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type request struct {
+		With        string `json:"with"`
+		Description string `json:"description"`
+	}
+
+	return handleRecipeChange(rs, statsCollection, "add_pairing", func(ctx context.Context, id string, req request) (recipe.Recipe, error) {
+		return rs.AddPairing(ctx, id, req.With, req.Description)
+	})
+}
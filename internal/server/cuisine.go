@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain/cuisine"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+// loadCuisines fetches the registry that names cuisines in responses
+// and resolves them in requests. On failure it writes the error
+// response itself and reports false.
+func loadCuisines(ctx context.Context, w http.ResponseWriter, rs recipeService, statsCollection *stats.StatsCollection, endpoint string) (cuisine.Registry, bool) {
+	cuisines, err := rs.Cuisines(ctx)
+	if err != nil {
+		status, errRes := recipeErrResponse(ctx, statsCollection, endpoint, "", err)
+		encode[errResponse](w, status, errRes)
+		return cuisine.Registry{}, false
+	}
+	return cuisines, true
+}
+
+type cuisineResponse struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	Parent  string   `json:"parent,omitempty"`
+	Regions []string `json:"regions,omitempty"`
+}
+
+func newCuisineResponse(c cuisine.Cuisine, cuisines cuisine.Registry) cuisineResponse {
+	res := cuisineResponse{
+		ID:      int(c.ID()),
+		Name:    c.Name(),
+		Aliases: c.Aliases(),
+		Parent:  cuisines.Name(c.Parent()),
+	}
+	for _, r := range cuisines.Regions(c.ID()) {
+		res.Regions = append(res.Regions, r.Name())
+	}
+	return res
+}
+
+func cuisineErrResponse(ctx context.Context, statsCollection *stats.StatsCollection, endpoint string, name string, err error) (int, errResponse) {
+	switch {
+	case errors.Is(err, cuisine.ErrCuisineNotFound):
+		slog.ErrorContext(ctx, "cuisine not found", "cuisine", name)
+		return http.StatusNotFound, errResponse{ErrCode: 40402, Msg: fmt.Sprintf("cuisine not found: %s", name)}
+	case errors.Is(err, cuisine.ErrInvalidCuisine),
+		errors.Is(err, cuisine.ErrCuisineExists),
+		errors.Is(err, cuisine.ErrParentNotFound),
+		errors.Is(err, cuisine.ErrCuisineCycle):
+		slog.ErrorContext(ctx, "invalid cuisine change", "cuisine", name, "err", err.Error())
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusBadRequest, errResponse{ErrCode: 40014, Msg: err.Error()}
+	case errors.Is(err, cuisine.ErrCuisineHasRegion),
+		errors.Is(err, cuisine.ErrCuisineInUse):
+		slog.ErrorContext(ctx, "cuisine still in use", "cuisine", name, "err", err.Error())
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusConflict, errResponse{ErrCode: 40902, Msg: err.Error()}
+	default:
+		return recipeErrResponse(ctx, statsCollection, endpoint, "", err)
+	}
+}
+
+func handleListCuisines(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type response struct {
+		Items []cuisineResponse `json:"items"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := r.Context()
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "list_cuisines")
+		if !ok {
+			return
+		}
+
+		all := cuisines.All()
+		res := response{Items: make([]cuisineResponse, 0, len(all))}
+		for _, c := range all {
+			res.Items = append(res.Items, newCuisineResponse(c, cuisines))
+		}
+
+		statsCollection.StatusOkInc("list_cuisines")
+		statsCollection.ResponseTime("list_cuisines", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
+
+// handleGetCuisine finds a cuisine by its name or any of its aliases.
+func handleGetCuisine(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		name := r.PathValue("name")
+		ctx := r.Context()
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "get_cuisine")
+		if !ok {
+			return
+		}
+		c, ok := cuisines.Lookup(name)
+		if !ok {
+			status, errRes := cuisineErrResponse(ctx, statsCollection, "get_cuisine", name, cuisine.ErrCuisineNotFound)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		statsCollection.StatusOkInc("get_cuisine")
+		statsCollection.ResponseTime("get_cuisine", time.Since(start).Milliseconds())
+		encode[cuisineResponse](w, http.StatusOK, newCuisineResponse(c, cuisines))
+	})
+}
+
+type cuisineRequest struct {
+	Name    string   `json:"name"`
+	Parent  string   `json:"parent"`
+	Aliases []string `json:"aliases"`
+}
+
+// handleCuisineChange wires up the endpoints that create and update
+// cuisines, which share their request and response.
+func handleCuisineChange(
+	rs recipeService,
+	statsCollection *stats.StatsCollection,
+	endpoint string,
+	apply func(ctx context.Context, name string, req cuisineRequest) (cuisine.Cuisine, error),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		name := r.PathValue("name")
+		ctx := r.Context()
+
+		reqObj, err := decode[cuisineRequest](r)
+		if err != nil {
+			slog.ErrorContext(ctx, "parsing request object failed", "endpoint", endpoint)
+			statsCollection.BadRequestInc(endpoint)
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40002, Msg: "Issue in parsing request body"})
+			return
+		}
+
+		c, err := apply(ctx, name, reqObj)
+		if err != nil {
+			status, errRes := cuisineErrResponse(ctx, statsCollection, endpoint, name, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, endpoint)
+		if !ok {
+			return
+		}
+
+		statsCollection.StatusOkInc(endpoint)
+		statsCollection.ResponseTime(endpoint, time.Since(start).Milliseconds())
+		encode[cuisineResponse](w, http.StatusOK, newCuisineResponse(c, cuisines))
+	})
+}
+
+func handleCreateCuisine(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return handleCuisineChange(rs, statsCollection, "create_cuisine", func(ctx context.Context, _ string, req cuisineRequest) (cuisine.Cuisine, error) {
+		return rs.CreateCuisine(ctx, req.Name, req.Parent, req.Aliases)
+	})
+}
+
+// handleUpdateCuisine replaces the name, parent and aliases of a
+// cuisine. Leaving the name out keeps the current one.
+func handleUpdateCuisine(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return handleCuisineChange(rs, statsCollection, "update_cuisine", func(ctx context.Context, name string, req cuisineRequest) (cuisine.Cuisine, error) {
+		return rs.UpdateCuisine(ctx, name, req.Name, req.Parent, req.Aliases)
+	})
+}
+
+func handleDeleteCuisine(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		name := r.PathValue("name")
+		ctx := r.Context()
+
+		if err := rs.DeleteCuisine(ctx, name); err != nil {
+			status, errRes := cuisineErrResponse(ctx, statsCollection, "delete_cuisine", name, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		slog.InfoContext(ctx, "cuisine deleted", "cuisine", name)
+		statsCollection.StatusOkInc("delete_cuisine")
+		statsCollection.ResponseTime("delete_cuisine", time.Since(start).Milliseconds())
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
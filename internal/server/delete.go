@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+func handleDeleteRecipe(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		if err := rs.DeleteRecipe(ctx, id); err != nil {
+			status, errRes := recipeErrResponse(ctx, statsCollection, "delete_recipe", id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		slog.InfoContext(ctx, "recipe deleted", "recipe_id", id)
+		statsCollection.StatusOkInc("delete_recipe")
+		statsCollection.ResponseTime("delete_recipe", time.Since(start).Milliseconds())
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func handleRestoreRecipe(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		res, err := rs.RestoreRecipe(ctx, id)
+		if err != nil {
+			if errors.Is(err, recipe.ErrRecipeNotDeleted) {
+				slog.ErrorContext(ctx, "restoring recipe that is not deleted", "recipe_id", id)
+				statsCollection.BadRequestInc("restore_recipe")
+				encode[errResponse](w, http.StatusConflict, errResponse{ErrCode: 40901, Msg: "recipe is not deleted"})
+				return
+			}
+			status, errRes := recipeErrResponse(ctx, statsCollection, "restore_recipe", id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "restore_recipe")
+		if !ok {
+			return
+		}
+
+		statsCollection.StatusOkInc("restore_recipe")
+		statsCollection.ResponseTime("restore_recipe", time.Since(start).Milliseconds())
+		encode[recipeResponse](w, http.StatusOK, newRecipeResponse(res, cuisines))
+	})
+}
+
+// purgeDeleted hard deletes tombstoned recipes older than retention
+// every interval until ctx is done.
+func purgeDeleted(ctx context.Context, rs recipeService, interval time.Duration, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := rs.PurgeDeleted(ctx, retention)
+			if err != nil {
+				slog.Error("purging deleted recipes failed", "err", err.Error())
+				continue
+			}
+			slog.Info("purged deleted recipes", "count", purged)
+		}
+	}
+}
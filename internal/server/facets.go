@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/cuisine"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/domain/taxonomy"
+)
+
+type dietaryTag string
+
+const (
+	vegetarian dietaryTag = "vegetarian"
+	vegan      dietaryTag = "vegan"
+	glutenFree dietaryTag = "gluten_free"
+	dairyFree  dietaryTag = "dairy_free"
+	nutFree    dietaryTag = "nut_free"
+	halal      dietaryTag = "halal"
+	kosher     dietaryTag = "kosher"
+)
+
+var dietaryTags = map[dietaryTag]domain.DietaryTag{
+	vegetarian: domain.Vegetarian,
+	vegan:      domain.Vegan,
+	glutenFree: domain.GlutenFree,
+	dairyFree:  domain.DairyFree,
+	nutFree:    domain.NutFree,
+	halal:      domain.Halal,
+	kosher:     domain.Kosher,
+}
+
+func (t *dietaryTag) UnmarshalText(data []byte) error {
+	s := dietaryTag(strings.ToLower(string(data)))
+	if _, ok := dietaryTags[s]; !ok {
+		return fmt.Errorf("unknown dietary tag: %s", data)
+	}
+	*t = s
+	return nil
+}
+
+func (t dietaryTag) ToDomain() domain.DietaryTag {
+	return dietaryTags[t]
+}
+
+func (t *dietaryTag) FromDomain(dt domain.DietaryTag) {
+	for k, v := range dietaryTags {
+		if v == dt {
+			*t = k
+			return
+		}
+	}
+}
+
+type timeBucket string
+
+var timeBuckets = map[timeBucket]recipe.TimeBucket{
+	"under_15m": recipe.Under15Minutes,
+	"15_30m":    recipe.Under30Minutes,
+	"30_60m":    recipe.Under1Hour,
+	"1_2h":      recipe.Under2Hours,
+	"over_2h":   recipe.Over2Hours,
+}
+
+func (b *timeBucket) UnmarshalText(data []byte) error {
+	s := timeBucket(strings.ToLower(string(data)))
+	if _, ok := timeBuckets[s]; !ok {
+		return fmt.Errorf("unknown total time: %s", data)
+	}
+	*b = s
+	return nil
+}
+
+func (b timeBucket) ToDomain() recipe.TimeBucket {
+	return timeBuckets[b]
+}
+
+func (b *timeBucket) FromDomain(tb recipe.TimeBucket) {
+	for k, v := range timeBuckets {
+		if v == tb {
+			*b = k
+			return
+		}
+	}
+}
+
+// facetsResponse keys every count by the value it is filtered on in a
+// listing query. Cuisines and ingredient types count the recipes of
+// the ones below them too, as filtering on them finds those.
+type facetsResponse struct {
+	Cuisine        map[string]int     `json:"cuisine"`
+	IngredientType map[string]int     `json:"ingredient_type"`
+	TotalTime      map[timeBucket]int `json:"total_time"`
+	DietaryTag     map[dietaryTag]int `json:"dietary_tag"`
+}
+
+func newFacetsResponse(f recipe.Facets, cuisines cuisine.Registry, types taxonomy.Taxonomy) *facetsResponse {
+	res := &facetsResponse{
+		Cuisine:        make(map[string]int, len(f.Cuisines)),
+		IngredientType: make(map[string]int, len(f.IngredientTypes)),
+		TotalTime:      make(map[timeBucket]int, len(f.TotalTimes)),
+		DietaryTag:     make(map[dietaryTag]int, len(f.DietaryTags)),
+	}
+	for k, n := range f.Cuisines {
+		res.Cuisine[nameOrUnknown(cuisines.Name(k))] += n
+	}
+	for k, n := range f.IngredientTypes {
+		res.IngredientType[nameOrUnknown(types.Name(k))] += n
+	}
+	for k, n := range f.TotalTimes {
+		var b timeBucket
+		b.FromDomain(k)
+		res.TotalTime[b] += n
+	}
+	for k, n := range f.DietaryTags {
+		var t dietaryTag
+		t.FromDomain(k)
+		res.DietaryTag[t] += n
+	}
+	return res
+}
+
+// nameOrUnknown is the key of a facet the registry has no name for.
+func nameOrUnknown(name string) string {
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
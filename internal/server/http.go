@@ -13,6 +13,8 @@ import (
 
 	"github.com/bento01dev/cookbook/internal/config"
 	"github.com/bento01dev/cookbook/internal/db"
+	"github.com/bento01dev/cookbook/internal/domain/cuisine"
+	"github.com/bento01dev/cookbook/internal/domain/taxonomy"
 	"github.com/bento01dev/cookbook/internal/services"
 	"github.com/bento01dev/cookbook/internal/stats"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -30,30 +32,72 @@ func startHttp(ctx context.Context, getEnv func(string) string) error {
 
 	statsCollection := stats.Stats(getEnv)
 
+	cuisines := cuisine.Defaults()
+	if conf.CuisinesFile != "" {
+		cuisines, err = cuisine.LoadFile(conf.CuisinesFile)
+		if err != nil {
+			return fmt.Errorf("loading cuisines failed: %w", err)
+		}
+	}
+	types := taxonomy.Defaults()
+	if conf.IngredientTypesFile != "" {
+		types, err = taxonomy.LoadFile(conf.IngredientTypesFile)
+		if err != nil {
+			return fmt.Errorf("loading ingredient types failed: %w", err)
+		}
+	}
+
 	// initialising and starting server..
 	var rs recipeService
 	switch strings.ToLower(getEnv("DB_TYPE")) {
-	case "memory":
-		rs, err = services.NewRecipeService(services.WithMemoryRepository())
-		if err != nil {
-			return err
-		}
 	case "mongo":
 		client, err := db.MongoClient(getEnv)
 		if err != nil {
 			return fmt.Errorf("mongo client initialisation failed: %w", err)
 		}
-		rs, err = services.NewRecipeService(services.WithMongoRepository(client, getEnv))
+		rs, err = services.NewRecipeService(
+			services.WithMongoRepository(client, getEnv),
+			services.WithMongoCuisines(client, getEnv, cuisines),
+			services.WithMongoTaxonomy(client, getEnv, types),
+			services.WithMongoCatalogue(client, getEnv),
+		)
+		if err != nil {
+			return err
+		}
+	case "sqlite":
+		sqlDB, err := db.SQLiteDB(getEnv)
+		if err != nil {
+			return fmt.Errorf("sqlite initialisation failed: %w", err)
+		}
+		rs, err = services.NewRecipeService(
+			services.WithSQLiteRepository(sqlDB),
+			services.WithSQLiteCuisines(sqlDB, cuisines),
+			services.WithSQLiteTaxonomy(sqlDB, types),
+			services.WithSQLiteCatalogue(sqlDB),
+		)
 		if err != nil {
 			return err
 		}
 	default:
-		rs, err = services.NewRecipeService(services.WithMemoryRepository())
+		memoryRepository := services.WithMemoryRepository()
+		memoryCatalogue := services.WithMemoryCatalogue()
+		if conf.MemoryDataDir != "" {
+			memoryRepository = services.WithDurableMemoryRepository(conf.MemoryDataDir, conf.CompactInterval)
+			memoryCatalogue = services.WithDurableMemoryCatalogue(conf.MemoryDataDir)
+		}
+		rs, err = services.NewRecipeService(
+			memoryRepository,
+			services.WithMemoryCuisines(cuisines),
+			services.WithMemoryTaxonomy(types),
+			memoryCatalogue,
+		)
 		if err != nil {
 			return err
 		}
 	}
 
+	go purgeDeleted(ctx, rs, conf.PurgeInterval, conf.TombstoneRetention)
+
 	srv := NewServer(rs, statsCollection, conf)
 	httpServer := &http.Server{
 		Addr:    net.JoinHostPort(conf.Host, conf.Port),
@@ -79,16 +123,22 @@ func startHttp(ctx context.Context, getEnv func(string) string) error {
 		shutdownCtx := context.Background()
 		shutdownCtx, cancel := context.WithTimeout(shutdownCtx, 60*time.Second)
 		defer cancel()
+		// stop taking requests before the store they write to goes away
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("shutdown of server was not graceful", "err", err.Error())
+		}
+		if err := rs.Close(); err != nil {
+			slog.Error("did not successfully close recipe service", "err", err.Error())
+		}
 		switch strings.ToLower(getEnv("DB_TYPE")) {
-		case "memory":
-			slog.Info("nothing to clean up for memory db..")
 		case "mongo":
 			if err := db.Close(shutdownCtx); err != nil {
 				slog.Error("did not successfully close mongo connection", "err", err.Error())
 			}
-		}
-		if err := httpServer.Shutdown(shutdownCtx); err != nil {
-			slog.Error("shutdown of server was not graceful", "err", err.Error())
+		case "sqlite":
+			if err := db.CloseSQLite(); err != nil {
+				slog.Error("did not successfully close sqlite database", "err", err.Error())
+			}
 		}
 	}()
 	wg.Wait()
@@ -118,8 +168,43 @@ func addRoutes(
 ) {
 	mux.Handle("GET /healthz", handleHealthz())
 
+	mux.Handle("GET /recipes", timeoutMiddleware(handleListRecipes(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /recipes/search", timeoutMiddleware(handleSearchRecipes(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /recipes/suggest", timeoutMiddleware(handleSuggestRecipes(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /meals/plan", timeoutMiddleware(handlePlanMeal(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /cuisines", timeoutMiddleware(handleListCuisines(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /cuisine/{name}", timeoutMiddleware(handleGetCuisine(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("POST /cuisine", timeoutMiddleware(handleCreateCuisine(rs, statsCollection), conf.CreateRecipeTimeout))
+	mux.Handle("PUT /cuisine/{name}", timeoutMiddleware(handleUpdateCuisine(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("DELETE /cuisine/{name}", timeoutMiddleware(handleDeleteCuisine(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("GET /ingredients", timeoutMiddleware(handleListIngredients(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /ingredient/{id}", timeoutMiddleware(handleGetIngredient(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("POST /ingredient", timeoutMiddleware(handleCreateIngredient(rs, statsCollection), conf.CreateRecipeTimeout))
+	mux.Handle("PATCH /ingredient/{id}", timeoutMiddleware(handleUpdateIngredient(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("DELETE /ingredient/{id}", timeoutMiddleware(handleDeleteIngredient(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("GET /ingredient-types", timeoutMiddleware(handleListIngredientTypes(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /ingredient-type/{name}", timeoutMiddleware(handleGetIngredientType(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("POST /ingredient-type", timeoutMiddleware(handleCreateIngredientType(rs, statsCollection), conf.CreateRecipeTimeout))
+	mux.Handle("PUT /ingredient-type/{name}", timeoutMiddleware(handleUpdateIngredientType(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("DELETE /ingredient-type/{name}", timeoutMiddleware(handleDeleteIngredientType(rs, statsCollection), conf.UpdateRecipeTimeout))
 	mux.Handle("GET /recipe/{id}", timeoutMiddleware(handleGetRecipe(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /recipe/{id}/similar", timeoutMiddleware(handleSimilarRecipes(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /recipe/{id}/pairings", timeoutMiddleware(handleGetPairings(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /recipe/{id}/variations", timeoutMiddleware(handleGetVariations(rs, statsCollection), conf.GetRecipeTimeout))
+	mux.Handle("GET /recipe/{id}/timeline", timeoutMiddleware(handleGetTimeline(rs, statsCollection), conf.GetRecipeTimeout))
 	mux.Handle("POST /recipe", timeoutMiddleware(handleCreateRecipe(rs, statsCollection), conf.CreateRecipeTimeout))
+	mux.Handle("PATCH /recipe/{id}", timeoutMiddleware(handlePatchRecipe(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("DELETE /recipe/{id}", timeoutMiddleware(handleDeleteRecipe(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("POST /recipe/{id}/restore", timeoutMiddleware(handleRestoreRecipe(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("POST /recipe/{id}/ingredients", timeoutMiddleware(handleAddIngredient(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("POST /recipe/{id}/prep", timeoutMiddleware(handleAddPrep(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("POST /recipe/{id}/prep/move", timeoutMiddleware(handleMovePrep(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("DELETE /recipe/{id}/prep/{pos}", timeoutMiddleware(handleRemovePrep(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("POST /recipe/{id}/steps", timeoutMiddleware(handleAddStep(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("POST /recipe/{id}/steps/move", timeoutMiddleware(handleMoveStep(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("DELETE /recipe/{id}/steps/{pos}", timeoutMiddleware(handleRemoveStep(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("POST /recipe/{id}/variations", timeoutMiddleware(handleAddVariation(rs, statsCollection), conf.UpdateRecipeTimeout))
+	mux.Handle("POST /recipe/{id}/pairings", timeoutMiddleware(handleAddPairing(rs, statsCollection), conf.UpdateRecipeTimeout))
 
 	mux.Handle("/metrics", promhttp.Handler())
 }
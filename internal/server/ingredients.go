@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/catalogue"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/domain/taxonomy"
+	"github.com/bento01dev/cookbook/internal/stats"
+	"github.com/google/uuid"
+)
+
+// catalogueID parses the optional id of a catalogue entry in a request
+// body. A malformed one makes the ingredient invalid rather than the
+// recipe id.
+func catalogueID(s string) (uuid.UUID, error) {
+	if s == "" {
+		return uuid.Nil, nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, domain.ErrInvalidIngredient
+	}
+	return id, nil
+}
+
+type catalogueResponse struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Type        int      `json:"type,omitempty"`
+	Density     float64  `json:"density,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at,omitempty"`
+}
+
+func newCatalogueResponse(e catalogue.Entry) catalogueResponse {
+	i := e.Ingredient()
+	res := catalogueResponse{
+		ID:          i.ID.String(),
+		Name:        i.Name,
+		Description: i.Description,
+		Type:        int(i.Type),
+		Density:     i.Density,
+		Aliases:     e.Aliases(),
+		CreatedAt:   e.CreatedAt().Format(time.RFC3339),
+	}
+	if !e.UpdatedAt().IsZero() {
+		res.UpdatedAt = e.UpdatedAt().Format(time.RFC3339)
+	}
+	return res
+}
+
+func catalogueErrResponse(ctx context.Context, statsCollection *stats.StatsCollection, endpoint string, id string, err error) (int, errResponse) {
+	switch {
+	case errors.Is(err, catalogue.ErrEntryNotFound):
+		slog.ErrorContext(ctx, "ingredient not found for given id", "ingredient_id", id)
+		return http.StatusNotFound, errResponse{ErrCode: 40403, Msg: fmt.Sprintf("ingredient not found for id: %s", id)}
+	case errors.Is(err, recipe.ErrInvalidID):
+		slog.ErrorContext(ctx, "invalid id format", "ingredient_id", id)
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusBadRequest, errResponse{ErrCode: 40001, Msg: fmt.Sprintf("invalid format for id: %s", id)}
+	case errors.Is(err, catalogue.ErrInvalidQuery):
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusBadRequest, errResponse{ErrCode: 40009, Msg: fmt.Sprintf("limit must be between 1 and %d", catalogue.MaxPageSize)}
+	case errors.Is(err, domain.ErrInvalidIngredient),
+		errors.Is(err, domain.ErrInvalidQuantity),
+		errors.Is(err, catalogue.ErrEntryExists),
+		errors.Is(err, taxonomy.ErrCategoryNotFound):
+		slog.ErrorContext(ctx, "invalid ingredient change", "ingredient_id", id, "err", err.Error())
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusBadRequest, errResponse{ErrCode: 40015, Msg: err.Error()}
+	case errors.Is(err, catalogue.ErrEntryInUse):
+		slog.ErrorContext(ctx, "ingredient still in use", "ingredient_id", id)
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusConflict, errResponse{ErrCode: 40903, Msg: err.Error()}
+	default:
+		return recipeErrResponse(ctx, statsCollection, endpoint, id, err)
+	}
+}
+
+// handleListIngredients lists the catalogue by name, optionally only
+// the ingredients with a name or alias starting with q.
+func handleListIngredients(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type response struct {
+		Items []catalogueResponse `json:"items"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := r.Context()
+
+		var limit int
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			if limit, err = strconv.Atoi(v); err != nil {
+				limit = -1
+			}
+		}
+
+		entries, err := rs.ListIngredients(ctx, r.URL.Query().Get("q"), limit)
+		if err != nil {
+			status, errRes := catalogueErrResponse(ctx, statsCollection, "list_ingredients", "", err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		res := response{Items: make([]catalogueResponse, 0, len(entries))}
+		for _, e := range entries {
+			res.Items = append(res.Items, newCatalogueResponse(e))
+		}
+
+		statsCollection.StatusOkInc("list_ingredients")
+		statsCollection.ResponseTime("list_ingredients", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
+
+func handleGetIngredient(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		e, err := rs.GetIngredient(ctx, id)
+		if err != nil {
+			status, errRes := catalogueErrResponse(ctx, statsCollection, "get_ingredient", id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		statsCollection.StatusOkInc("get_ingredient")
+		statsCollection.ResponseTime("get_ingredient", time.Since(start).Milliseconds())
+		encode[catalogueResponse](w, http.StatusOK, newCatalogueResponse(e))
+	})
+}
+
+// catalogueRequest creates an entry from every field, or changes the
+// fields that are present.
+type catalogueRequest struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Type        *int      `json:"type"`
+	Density     *float64  `json:"density"`
+	Aliases     *[]string `json:"aliases"`
+}
+
+func (req catalogueRequest) patch() catalogue.Patch {
+	p := catalogue.Patch{Name: req.Name, Description: req.Description, Density: req.Density, Aliases: req.Aliases}
+	if req.Type != nil {
+		t := domain.IngredientType(*req.Type)
+		p.Type = &t
+	}
+	return p
+}
+
+// handleCatalogueChange wires up the endpoints that create and update
+// catalogue entries, which share their request and response.
+func handleCatalogueChange(
+	statsCollection *stats.StatsCollection,
+	endpoint string,
+	apply func(ctx context.Context, id string, req catalogueRequest) (catalogue.Entry, error),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		reqObj, err := decode[catalogueRequest](r)
+		if err != nil {
+			slog.ErrorContext(ctx, "parsing request object failed", "endpoint", endpoint)
+			statsCollection.BadRequestInc(endpoint)
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40002, Msg: "Issue in parsing request body"})
+			return
+		}
+
+		e, err := apply(ctx, id, reqObj)
+		if err != nil {
+			status, errRes := catalogueErrResponse(ctx, statsCollection, endpoint, id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		statsCollection.StatusOkInc(endpoint)
+		statsCollection.ResponseTime(endpoint, time.Since(start).Milliseconds())
+		encode[catalogueResponse](w, http.StatusOK, newCatalogueResponse(e))
+	})
+}
+
+func handleCreateIngredient(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return handleCatalogueChange(statsCollection, "create_ingredient", func(ctx context.Context, _ string, req catalogueRequest) (catalogue.Entry, error) {
+		var ingredient domain.Ingredient
+		p := req.patch()
+		if p.Name != nil {
+			ingredient.Name = *p.Name
+		}
+		if p.Description != nil {
+			ingredient.Description = *p.Description
+		}
+		if p.Type != nil {
+			ingredient.Type = *p.Type
+		}
+		if p.Density != nil {
+			ingredient.Density = *p.Density
+		}
+		var aliases []string
+		if p.Aliases != nil {
+			aliases = *p.Aliases
+		}
+		return rs.CreateIngredient(ctx, ingredient, aliases)
+	})
+}
+
+// handleUpdateIngredient changes a catalogue entry. The recipes using
+// it are updated to match before it responds.
+func handleUpdateIngredient(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return handleCatalogueChange(statsCollection, "update_ingredient", func(ctx context.Context, id string, req catalogueRequest) (catalogue.Entry, error) {
+		return rs.UpdateIngredient(ctx, id, req.patch())
+	})
+}
+
+func handleDeleteIngredient(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		if err := rs.DeleteIngredient(ctx, id); err != nil {
+			status, errRes := catalogueErrResponse(ctx, statsCollection, "delete_ingredient", id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		slog.InfoContext(ctx, "ingredient deleted", "ingredient_id", id)
+		statsCollection.StatusOkInc("delete_ingredient")
+		statsCollection.ResponseTime("delete_ingredient", time.Since(start).Milliseconds())
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
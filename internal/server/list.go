@@ -0,0 +1,164 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/cuisine"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/domain/taxonomy"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+// parseRecipeQuery reads the listing filters from the query string.
+// cuisine, ingredient_type and total_time may be repeated to match any
+// of them, diet to match all of them. An ingredient type may be given
+// by name or id.
+func parseRecipeQuery(values url.Values, cuisines cuisine.Registry, types taxonomy.Taxonomy) (recipe.Query, error) {
+	var q recipe.Query
+	for _, v := range values["cuisine"] {
+		c, ok := cuisines.Lookup(v)
+		if !ok {
+			return q, fmt.Errorf("unknown cuisine: %s", v)
+		}
+		q.Cuisines = append(q.Cuisines, c.ID())
+	}
+	for _, v := range values["ingredient_type"] {
+		c, ok := types.Lookup(v)
+		if id, err := strconv.Atoi(v); err == nil && !ok {
+			c, ok = types.Get(domain.IngredientType(id))
+		}
+		if !ok {
+			return q, fmt.Errorf("unknown ingredient type: %s", v)
+		}
+		q.IngredientTypes = append(q.IngredientTypes, c.ID())
+	}
+	for _, v := range values["diet"] {
+		var t dietaryTag
+		if err := t.UnmarshalText([]byte(v)); err != nil {
+			return q, err
+		}
+		q.DietaryTags = append(q.DietaryTags, t.ToDomain())
+	}
+	for _, v := range values["total_time"] {
+		var b timeBucket
+		if err := b.UnmarshalText([]byte(v)); err != nil {
+			return q, err
+		}
+		q.TotalTimes = append(q.TotalTimes, b.ToDomain())
+	}
+	for _, f := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"created_after", &q.CreatedAfter},
+		{"created_before", &q.CreatedBefore},
+		{"updated_after", &q.UpdatedAfter},
+		{"updated_before", &q.UpdatedBefore},
+	} {
+		v := values.Get(f.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, fmt.Errorf("%s must be an RFC 3339 time", f.name)
+		}
+		*f.dst = t
+	}
+	switch values.Get("sort") {
+	case "", "created_at":
+		q.Sort = recipe.SortByCreatedAt
+	case "updated_at":
+		q.Sort = recipe.SortByUpdatedAt
+	case "name":
+		q.Sort = recipe.SortByName
+	default:
+		return q, fmt.Errorf("sort must be created_at, updated_at or name")
+	}
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		return q, fmt.Errorf("order must be asc or desc")
+	}
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > recipe.MaxPageSize {
+			return q, fmt.Errorf("limit must be between 1 and %d", recipe.MaxPageSize)
+		}
+		q.Limit = limit
+	}
+	q.Cursor = values.Get("cursor")
+	switch values.Get("facets") {
+	case "", "false":
+	case "true":
+		q.Facets = true
+	default:
+		return q, fmt.Errorf("facets must be true or false")
+	}
+	return q, nil
+}
+
+func handleListRecipes(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type response struct {
+		Items      []itemResponse  `json:"items"`
+		NextCursor string          `json:"next_cursor,omitempty"`
+		Facets     *facetsResponse `json:"facets,omitempty"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := r.Context()
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "list_recipes")
+		if !ok {
+			return
+		}
+
+		types, ok := loadTaxonomy(ctx, w, rs, statsCollection, "list_recipes")
+		if !ok {
+			return
+		}
+
+		q, err := parseRecipeQuery(r.URL.Query(), cuisines, types)
+		if err != nil {
+			slog.ErrorContext(ctx, "invalid recipe query", "query", r.URL.RawQuery, "err", err.Error())
+			statsCollection.BadRequestInc("list_recipes")
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40009, Msg: err.Error()})
+			return
+		}
+
+		page, err := rs.ListRecipes(ctx, q)
+		if err != nil {
+			if errors.Is(err, recipe.ErrInvalidCursor) || errors.Is(err, recipe.ErrInvalidQuery) {
+				slog.ErrorContext(ctx, "invalid recipe query", "query", r.URL.RawQuery, "err", err.Error())
+				statsCollection.BadRequestInc("list_recipes")
+				encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40009, Msg: err.Error()})
+				return
+			}
+			status, errRes := recipeErrResponse(ctx, statsCollection, "list_recipes", "", err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		res := response{Items: make([]itemResponse, 0, len(page.Recipes)), NextCursor: page.NextCursor}
+		for _, v := range page.Recipes {
+			res.Items = append(res.Items, newItemResponse(v, cuisines))
+		}
+		if page.Facets != nil {
+			res.Facets = newFacetsResponse(*page.Facets, cuisines, types)
+		}
+
+		statsCollection.StatusOkInc("list_recipes")
+		statsCollection.ResponseTime("list_recipes", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
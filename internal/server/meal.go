@@ -0,0 +1,143 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+var errInvalidMealQuery = errors.New("invalid meal plan query")
+
+type equipment string
+
+var equipments = map[equipment]domain.Equipment{
+	"oven": domain.Oven,
+	"hob":  domain.Hob,
+}
+
+func (e *equipment) UnmarshalText(data []byte) error {
+	s := equipment(strings.ToLower(string(data)))
+	if _, ok := equipments[s]; !ok {
+		return fmt.Errorf("unknown equipment: %s", data)
+	}
+	*e = s
+	return nil
+}
+
+// ToDomain maps the empty equipment onto domain.NoEquipment.
+func (e equipment) ToDomain() domain.Equipment {
+	return equipments[e]
+}
+
+func (e *equipment) FromDomain(de domain.Equipment) {
+	for k, v := range equipments {
+		if v == de {
+			*e = k
+			return
+		}
+	}
+}
+
+// mealQuery reads the recipes, serve time and kitchen of a meal plan
+// from the query string. The kitchen defaults to one oven and four
+// burners.
+func mealQuery(values url.Values) ([]string, time.Time, recipe.Kitchen, error) {
+	kitchen := recipe.DefaultKitchen
+	ids := values["recipe"]
+	if len(ids) == 0 {
+		return nil, time.Time{}, kitchen, fmt.Errorf("%w: at least one recipe is needed", errInvalidMealQuery)
+	}
+	if len(ids) > recipe.MaxMealRecipes {
+		return nil, time.Time{}, kitchen, fmt.Errorf("%w: at most %d recipes can be planned together", errInvalidMealQuery, recipe.MaxMealRecipes)
+	}
+	serveAt, err := time.Parse(time.RFC3339, values.Get("serve_at"))
+	if err != nil {
+		return nil, time.Time{}, kitchen, fmt.Errorf("%w: serve_at must be an RFC 3339 time", errInvalidMealQuery)
+	}
+	for _, v := range []struct {
+		key   string
+		count *int
+	}{{"ovens", &kitchen.Ovens}, {"burners", &kitchen.Burners}} {
+		if s := values.Get(v.key); s != "" {
+			if *v.count, err = strconv.Atoi(s); err != nil || *v.count < 1 {
+				return nil, time.Time{}, kitchen, fmt.Errorf("%w: %s must be a positive number", errInvalidMealQuery, v.key)
+			}
+		}
+	}
+	return ids, serveAt, kitchen, nil
+}
+
+func handlePlanMeal(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type mealStep struct {
+		RecipeID   string `json:"recipe_id"`
+		RecipeName string `json:"recipe_name"`
+		stepResponse
+		StartAt  string `json:"start_at"`
+		EndAt    string `json:"end_at"`
+		Critical bool   `json:"critical"`
+	}
+	type response struct {
+		StartAt        string     `json:"start_at"`
+		ServeAt        string     `json:"serve_at"`
+		ElapsedMinutes int        `json:"elapsed_minutes"`
+		HandsOnMinutes int        `json:"hands_on_minutes"`
+		Steps          []mealStep `json:"steps"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := r.Context()
+
+		ids, serveAt, kitchen, err := mealQuery(r.URL.Query())
+		if err != nil {
+			slog.ErrorContext(ctx, "invalid meal plan query", "query", r.URL.RawQuery, "err", err.Error())
+			statsCollection.BadRequestInc("plan_meal")
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40012, Msg: err.Error()})
+			return
+		}
+
+		meal, err := rs.PlanMeal(ctx, ids, serveAt, kitchen)
+		if errors.Is(err, recipe.ErrDuplicateRecipe) || errors.Is(err, recipe.ErrMealTooLarge) {
+			slog.ErrorContext(ctx, "invalid meal plan query", "query", r.URL.RawQuery, "err", err.Error())
+			statsCollection.BadRequestInc("plan_meal")
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40012, Msg: err.Error()})
+			return
+		}
+		if err != nil {
+			status, errRes := recipeErrResponse(ctx, statsCollection, "plan_meal", strings.Join(ids, ","), err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		res := response{
+			StartAt:        meal.StartAt.Format(time.RFC3339),
+			ServeAt:        meal.ServeAt.Format(time.RFC3339),
+			ElapsedMinutes: int(meal.Elapsed / time.Minute),
+			HandsOnMinutes: int(meal.HandsOn / time.Minute),
+			Steps:          make([]mealStep, 0, len(meal.Steps)),
+		}
+		for _, s := range meal.Steps {
+			res.Steps = append(res.Steps, mealStep{
+				RecipeID:     s.Recipe.ID().String(),
+				RecipeName:   s.Recipe.Name(),
+				stepResponse: newStepResponse(s.Step),
+				StartAt:      meal.StartAt.Add(s.Start).Format(time.RFC3339),
+				EndAt:        meal.StartAt.Add(s.End).Format(time.RFC3339),
+				Critical:     s.Critical,
+			})
+		}
+
+		statsCollection.StatusOkInc("plan_meal")
+		statsCollection.ResponseTime("plan_meal", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+func handleGetPairings(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type paired struct {
+		Item        itemResponse `json:"item"`
+		Description string       `json:"description,omitempty"`
+	}
+	type suggestion struct {
+		Item itemResponse `json:"item"`
+		Hops int          `json:"hops"`
+		// Via holds the ids of the recipe's own pairings that lead to
+		// the suggestion
+		Via []string `json:"via"`
+	}
+	type response struct {
+		Pairings    []paired     `json:"pairings"`
+		Suggestions []suggestion `json:"suggestions"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		var limit int
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > recipe.MaxPageSize {
+				slog.ErrorContext(ctx, "invalid pairings query", "recipe_id", id, "query", r.URL.RawQuery)
+				statsCollection.BadRequestInc("get_pairings")
+				encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40009, Msg: fmt.Sprintf("limit must be between 1 and %d", recipe.MaxPageSize)})
+				return
+			}
+		}
+
+		direct, suggested, err := rs.RecipePairings(ctx, id, limit)
+		if err != nil {
+			status, errRes := recipeErrResponse(ctx, statsCollection, "get_pairings", id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "get_pairings")
+		if !ok {
+			return
+		}
+
+		res := response{
+			Pairings:    make([]paired, 0, len(direct)),
+			Suggestions: make([]suggestion, 0, len(suggested)),
+		}
+		for _, p := range direct {
+			res.Pairings = append(res.Pairings, paired{Item: newItemResponse(p.Recipe, cuisines), Description: p.Description})
+		}
+		for _, p := range suggested {
+			s := suggestion{Item: newItemResponse(p.Recipe, cuisines), Hops: p.Hops, Via: make([]string, 0, len(p.Via))}
+			for _, v := range p.Via {
+				s.Via = append(s.Via, v.ID().String())
+			}
+			res.Suggestions = append(res.Suggestions, s)
+		}
+
+		statsCollection.StatusOkInc("get_pairings")
+		statsCollection.ResponseTime("get_pairings", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
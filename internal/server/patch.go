@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/cuisine"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+var errInvalidPatch = errors.New("invalid merge patch")
+
+// mergePatch converts a JSON Merge Patch (RFC 7396) document into a
+// recipe patch. Members that are absent stay unchanged and members
+// set to null are reset, which for the name means the patch is
+// rejected as a recipe cannot be nameless.
+func mergePatch(doc map[string]json.RawMessage, cuisines cuisine.Registry) (recipe.Patch, error) {
+	var patch recipe.Patch
+	for k, v := range doc {
+		null := string(v) == "null"
+		switch k {
+		case "name":
+			var name string
+			if !null {
+				if err := json.Unmarshal(v, &name); err != nil {
+					return patch, fmt.Errorf("%w: name must be a string", errInvalidPatch)
+				}
+			}
+			patch.Name = &name
+		case "description":
+			var description string
+			if !null {
+				if err := json.Unmarshal(v, &description); err != nil {
+					return patch, fmt.Errorf("%w: description must be a string", errInvalidPatch)
+				}
+			}
+			patch.Description = &description
+		case "cuisine":
+			if null {
+				return patch, fmt.Errorf("%w: cuisine cannot be removed", errInvalidPatch)
+			}
+			var name string
+			if err := json.Unmarshal(v, &name); err != nil {
+				return patch, fmt.Errorf("%w: cuisine must be a string", errInvalidPatch)
+			}
+			c, ok := cuisines.Lookup(name)
+			if !ok {
+				return patch, fmt.Errorf("%w: unknown cuisine", errInvalidPatch)
+			}
+			dc := c.ID()
+			patch.Cuisine = &dc
+		case "servings":
+			var servings int
+			if !null {
+				if err := json.Unmarshal(v, &servings); err != nil {
+					return patch, fmt.Errorf("%w: servings must be a number", errInvalidPatch)
+				}
+			}
+			patch.Servings = &servings
+		case "prep_minutes", "cook_minutes":
+			var minutes int
+			if !null {
+				if err := json.Unmarshal(v, &minutes); err != nil {
+					return patch, fmt.Errorf("%w: %s must be a whole number", errInvalidPatch, k)
+				}
+			}
+			d := time.Duration(minutes) * time.Minute
+			if k == "prep_minutes" {
+				patch.PrepTime = &d
+			} else {
+				patch.CookTime = &d
+			}
+		case "dietary_tags":
+			var tags []dietaryTag
+			if !null {
+				if err := json.Unmarshal(v, &tags); err != nil {
+					return patch, fmt.Errorf("%w: dietary_tags must be a list of known tags", errInvalidPatch)
+				}
+			}
+			dts := make([]domain.DietaryTag, 0, len(tags))
+			for _, t := range tags {
+				dts = append(dts, t.ToDomain())
+			}
+			patch.DietaryTags = &dts
+		default:
+			return patch, fmt.Errorf("%w: %s cannot be patched", errInvalidPatch, k)
+		}
+	}
+	return patch, nil
+}
+
+func handlePatchRecipe(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil ||
+			(mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			slog.ErrorContext(ctx, "unsupported content type for patch", "content_type", r.Header.Get("Content-Type"))
+			statsCollection.BadRequestInc("patch_recipe")
+			encode[errResponse](w, http.StatusUnsupportedMediaType, errResponse{ErrCode: 41501, Msg: "patch must be application/merge-patch+json"})
+			return
+		}
+
+		doc, err := decode[map[string]json.RawMessage](r)
+		if err != nil || doc == nil {
+			slog.ErrorContext(ctx, "parsing request object failed")
+			statsCollection.BadRequestInc("patch_recipe")
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40002, Msg: "Issue in parsing request body"})
+			return
+		}
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "patch_recipe")
+		if !ok {
+			return
+		}
+
+		patch, err := mergePatch(doc, cuisines)
+		if err != nil {
+			slog.ErrorContext(ctx, "invalid merge patch", "recipe_id", id, "err", err.Error())
+			statsCollection.BadRequestInc("patch_recipe")
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40008, Msg: err.Error()})
+			return
+		}
+
+		res, err := rs.UpdateRecipe(ctx, id, patch)
+		if err != nil {
+			status, errRes := recipeErrResponse(ctx, statsCollection, "patch_recipe", id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		statsCollection.StatusOkInc("patch_recipe")
+		statsCollection.ResponseTime("patch_recipe", time.Since(start).Milliseconds())
+		encode[recipeResponse](w, http.StatusOK, newRecipeResponse(res, cuisines))
+	})
+}
@@ -3,20 +3,63 @@ package server
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log/slog"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/catalogue"
+	"github.com/bento01dev/cookbook/internal/domain/cuisine"
 	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/domain/taxonomy"
+	"github.com/bento01dev/cookbook/internal/domain/units"
+	"github.com/bento01dev/cookbook/internal/search"
+	"github.com/bento01dev/cookbook/internal/services"
 	"github.com/bento01dev/cookbook/internal/stats"
 )
 
 type recipeService interface {
-	CreateRecipe(context.Context, string, string, domain.CuisineType) (recipe.Recipe, error)
+	CreateRecipe(context.Context, string, string, domain.CuisineType, int) (recipe.Recipe, error)
 	GetRecipe(context.Context, string) (recipe.Recipe, error)
+	UpdateRecipe(context.Context, string, recipe.Patch) (recipe.Recipe, error)
+	DeleteRecipe(context.Context, string) error
+	RestoreRecipe(context.Context, string) (recipe.Recipe, error)
+	PurgeDeleted(context.Context, time.Duration) (int, error)
+	ListRecipes(context.Context, recipe.Query) (recipe.Page, error)
+	SearchRecipes(context.Context, string, int, bool) ([]recipe.SearchHit, *recipe.Facets, error)
+	SuggestRecipes(context.Context, string, int) ([]search.Suggestion, error)
+	SimilarRecipes(context.Context, string, int) ([]recipe.Similarity, error)
+	RecipePairings(context.Context, string, int) ([]recipe.PairedRecipe, []recipe.PairedRecipe, error)
+	Close() error
+	AddIngredient(context.Context, string, domain.Ingredient, float64, string, string) (recipe.Recipe, error)
+	AddPrep(context.Context, string, string, string) (recipe.Recipe, error)
+	InsertPrep(context.Context, string, int, string, string) (recipe.Recipe, error)
+	MovePrep(context.Context, string, int, int) (recipe.Recipe, error)
+	RemovePrep(context.Context, string, int) (recipe.Recipe, error)
+	AddStep(context.Context, string, services.StepDetails) (recipe.Recipe, error)
+	InsertStep(context.Context, string, int, services.StepDetails) (recipe.Recipe, error)
+	MoveStep(context.Context, string, int, int) (recipe.Recipe, error)
+	RemoveStep(context.Context, string, int) (recipe.Recipe, error)
+	CreateVariation(context.Context, string, string, string, []services.IngredientSwap, []string) (recipe.Recipe, error)
+	GetResolvedRecipe(context.Context, string) (recipe.Recipe, error)
+	RecipeVariations(context.Context, string) ([]recipe.Recipe, error)
+	RecipeTimeline(context.Context, string) (recipe.Timeline, error)
+	PlanMeal(context.Context, []string, time.Time, recipe.Kitchen) (recipe.Meal, error)
+	AddPairing(context.Context, string, string, string) (recipe.Recipe, error)
+	Cuisines(context.Context) (cuisine.Registry, error)
+	CreateCuisine(context.Context, string, string, []string) (cuisine.Cuisine, error)
+	UpdateCuisine(context.Context, string, string, string, []string) (cuisine.Cuisine, error)
+	DeleteCuisine(context.Context, string) error
+	GetIngredient(context.Context, string) (catalogue.Entry, error)
+	ListIngredients(context.Context, string, int) ([]catalogue.Entry, error)
+	CreateIngredient(context.Context, domain.Ingredient, []string) (catalogue.Entry, error)
+	UpdateIngredient(context.Context, string, catalogue.Patch) (catalogue.Entry, error)
+	DeleteIngredient(context.Context, string) error
+	Taxonomy(context.Context) (taxonomy.Taxonomy, error)
+	CreateIngredientType(context.Context, string, string) (taxonomy.Category, error)
+	UpdateIngredientType(context.Context, string, string, string) (taxonomy.Category, error)
+	DeleteIngredientType(context.Context, string) error
 }
 
 type errResponse struct {
@@ -24,96 +67,157 @@ type errResponse struct {
 	Msg     string `json:"msg"`
 }
 
-type cuisine string
+type ingredientResponse struct {
+	ID       string  `json:"id,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Type     int     `json:"type,omitempty"`
+	Quantity float64 `json:"quantity,omitempty"`
+	Unit     string  `json:"unit,omitempty"`
+	Note     string  `json:"note,omitempty"`
+}
 
-func (c cuisine) MarshalText() ([]byte, error) {
-	switch c {
-	case japanese, french, spanish, indian, chinese, western:
-		return []byte(c), nil
-	default:
-		return nil, fmt.Errorf("unknown type: %v", c)
-	}
+type prepResponse struct {
+	Position     int    `json:"position"`
+	IngredientID string `json:"ingredient_id,omitempty"`
+	Action       string `json:"action,omitempty"`
 }
 
-func (c *cuisine) UnmarshalText(data []byte) error {
-	s := string(data)
-	switch strings.ToLower(s) {
-	case string(japanese):
-		*c = japanese
-		return nil
-	case string(french):
-		*c = french
-		return nil
-	case string(spanish):
-		*c = spanish
-		return nil
-	case string(indian):
-		*c = indian
-		return nil
-	case string(chinese):
-		*c = chinese
-		return nil
-	case string(western):
-		*c = western
-		return nil
-	default:
-		return fmt.Errorf("unknown type: %s", s)
-	}
+type stepResponse struct {
+	Position       int       `json:"position"`
+	IngredientID   string    `json:"ingredient_id,omitempty"`
+	Action         string    `json:"action,omitempty"`
+	Temperature    float64   `json:"temperature,omitempty"`
+	Unit           string    `json:"temperature_unit,omitempty"`
+	Method         method    `json:"method,omitempty"`
+	ActiveMinutes  int       `json:"active_minutes,omitempty"`
+	PassiveMinutes int       `json:"passive_minutes,omitempty"`
+	After          []int     `json:"after,omitempty"`
+	Equipment      equipment `json:"equipment,omitempty"`
 }
 
-func (c cuisine) ToDomain() domain.CuisineType {
-	var dc domain.CuisineType
-	switch c {
-	case japanese:
-		dc = domain.Japanese
-	case french:
-		dc = domain.French
-	case spanish:
-		dc = domain.Spanish
-	case indian:
-		dc = domain.Indian
-	case chinese:
-		dc = domain.Chinese
-	case western:
-		dc = domain.Western
-	default:
-		dc = domain.UnknownCuisine
+func newStepResponse(s domain.Step) stepResponse {
+	var e equipment
+	e.FromDomain(s.Equipment())
+	var m method
+	m.FromDomain(s.Method())
+	return stepResponse{
+		Unit:           s.Temperature().Unit.String(),
+		Method:         m,
+		Equipment:      e,
+		Position:       s.Index(),
+		IngredientID:   s.Ingredient(),
+		Action:         s.Action(),
+		Temperature:    s.Temperature().Value,
+		ActiveMinutes:  int(s.Active() / time.Minute),
+		PassiveMinutes: int(s.Passive() / time.Minute),
+		After:          s.After(),
 	}
-	return dc
 }
 
-func (c *cuisine) FromDomain(dc domain.CuisineType) {
-	switch dc {
-	case domain.Japanese:
-		*c = japanese
-	case domain.French:
-		*c = french
-	case domain.Spanish:
-		*c = spanish
-	case domain.Indian:
-		*c = indian
-	case domain.Chinese:
-		*c = chinese
-	case domain.Western:
-		*c = western
+type pairingResponse struct {
+	With        string `json:"with"`
+	Description string `json:"description,omitempty"`
+}
+
+type itemResponse struct {
+	ID           string       `json:"id,omitempty"`
+	Name         string       `json:"name,omitempty"`
+	Description  string       `json:"description,omitempty"`
+	Cuisine      string       `json:"cuisine,omitempty"`
+	Servings     int          `json:"servings,omitempty"`
+	PrepMinutes  int          `json:"prep_minutes,omitempty"`
+	CookMinutes  int          `json:"cook_minutes,omitempty"`
+	TotalMinutes int          `json:"total_minutes,omitempty"`
+	DietaryTags  []dietaryTag `json:"dietary_tags,omitempty"`
+	CreatedAt    string       `json:"created_at"`
+	UpdatedAt    string       `json:"updated_at,omitempty"`
+}
+
+// newItemResponse names the recipe's cuisine from cuisines. A cuisine
+// the registry no longer knows is left out.
+func newItemResponse(r recipe.Recipe, cuisines cuisine.Registry) itemResponse {
+	var tags []dietaryTag
+	for _, v := range r.DietaryTags() {
+		var t dietaryTag
+		t.FromDomain(v)
+		tags = append(tags, t)
+	}
+	return itemResponse{
+		ID:           r.ID().String(),
+		Name:         r.Name(),
+		Description:  r.Description(),
+		Cuisine:      cuisines.Name(r.Cuisine()),
+		Servings:     r.Servings(),
+		PrepMinutes:  int(r.PrepTime().Minutes()),
+		CookMinutes:  int(r.CookTime().Minutes()),
+		TotalMinutes: int(r.TotalTime().Minutes()),
+		DietaryTags:  tags,
+		CreatedAt:    r.CreatedAt(),
+		UpdatedAt:    r.UpdatedAt(),
 	}
 }
 
-const (
-	unknown  cuisine = "unknown"
-	japanese cuisine = "japanese"
-	french   cuisine = "french"
-	spanish  cuisine = "spanish"
-	indian   cuisine = "indian"
-	chinese  cuisine = "chinese"
-	western  cuisine = "western"
-)
+// overrideResponse names the parent ingredient a variation changes
+// and the ingredient swapped in for it, if it is not simply omitted.
+type overrideResponse struct {
+	IngredientID string `json:"ingredient_id"`
+	SwapFor      string `json:"swap_for,omitempty"`
+}
+
+type recipeResponse struct {
+	Item        itemResponse         `json:"item"`
+	ParentID    string               `json:"parent_id,omitempty"`
+	Overrides   []overrideResponse   `json:"overrides,omitempty"`
+	Ingredients []ingredientResponse `json:"ingredients,omitempty"`
+	Variations  []string             `json:"variations,omitempty"`
+	Prep        []prepResponse       `json:"prep,omitempty"`
+	Steps       []stepResponse       `json:"steps,omitempty"`
+	Pairings    []pairingResponse    `json:"pairings,omitempty"`
+}
+
+func newRecipeResponse(r recipe.Recipe, cuisines cuisine.Registry) recipeResponse {
+	var res recipeResponse
+	res.Item = newItemResponse(r, cuisines)
+	for _, v := range r.Ingredients() {
+		i := v.Ingredient()
+		res.Ingredients = append(res.Ingredients, ingredientResponse{
+			ID:       i.ID.String(),
+			Name:     i.Name,
+			Type:     int(i.Type),
+			Quantity: v.Quantity().Amount,
+			Unit:     v.Quantity().Unit.String(),
+			Note:     v.Note(),
+		})
+	}
+	if r.IsVariation() {
+		res.ParentID = r.Parent().String()
+	}
+	for _, o := range r.Overrides() {
+		override := overrideResponse{IngredientID: o.IngredientID().String()}
+		if l, ok := o.Line(); ok {
+			override.SwapFor = l.Ingredient().ID.String()
+		}
+		res.Overrides = append(res.Overrides, override)
+	}
+	res.Variations = r.Variations()
+	for _, p := range r.Prep() {
+		res.Prep = append(res.Prep, prepResponse{Position: p.Index(), IngredientID: p.Ingredient(), Action: p.Action()})
+	}
+	for _, s := range r.Steps() {
+		res.Steps = append(res.Steps, newStepResponse(s))
+	}
+	for _, p := range r.Pairings() {
+		res.Pairings = append(res.Pairings, pairingResponse{With: p.With().String(), Description: p.Description()})
+	}
+	return res
+}
 
 func handleCreateRecipe(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
 	type request struct {
-		Name        string  `json:"name"`
-		Description string  `json:"description"`
-		Cuisine     cuisine `json:"cuisine"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Cuisine     string `json:"cuisine"`
+		Servings    int    `json:"servings"`
 	}
 
 	type response struct {
@@ -133,9 +237,19 @@ func handleCreateRecipe(rs recipeService, statsCollection *stats.StatsCollection
 			return
 		}
 
-		cuisine := reqObj.Cuisine.ToDomain()
-		if cuisine == domain.UnknownCuisine {
-			slog.ErrorContext(ctx, "unknown cuisine in request", "cuisine", string(reqObj.Cuisine))
+		if reqObj.Servings < 0 {
+			slog.ErrorContext(ctx, "negative servings in request", "servings", reqObj.Servings)
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40005, Msg: "servings must be a positive number"})
+			return
+		}
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "create_recipe")
+		if !ok {
+			return
+		}
+		c, ok := cuisines.Lookup(reqObj.Cuisine)
+		if !ok {
+			slog.ErrorContext(ctx, "unknown cuisine in request", "cuisine", reqObj.Cuisine)
 			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40003, Msg: "Unknown cuisine"})
 			return
 		}
@@ -146,11 +260,12 @@ func handleCreateRecipe(rs recipeService, statsCollection *stats.StatsCollection
 			slog.Group("payload",
 				slog.String("name", reqObj.Name),
 				slog.String("description", reqObj.Description),
-				slog.String("cuisine", string(reqObj.Cuisine)),
+				slog.String("cuisine", c.Name()),
+				slog.Int("servings", reqObj.Servings),
 			),
 		)
 
-		recipe, err := rs.CreateRecipe(ctx, reqObj.Name, reqObj.Description, cuisine)
+		recipe, err := rs.CreateRecipe(ctx, reqObj.Name, reqObj.Description, c.ID(), reqObj.Servings)
 		if err != nil {
 			if errors.Is(err, context.DeadlineExceeded) {
 				encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 50001, Msg: "service time out"})
@@ -168,94 +283,63 @@ func handleCreateRecipe(rs recipeService, statsCollection *stats.StatsCollection
 }
 
 func handleGetRecipe(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
-
-	type ingredient struct {
-		ID   string `json:"id,omitempty"`
-		Name string `json:"name,omitempty"`
-		Type int    `json:"type,omitempty"`
-	}
-
-	type prep struct {
-		IngredientID string `json:"ingredient_id,omitempty"`
-		Action       string `json:"action,omitempty"`
-	}
-
-	type step struct {
-		IngredientID string  `json:"ingredient_id,omitempty"`
-		Action       string  `json:"action,omitempty"`
-		Temperature  float64 `json:"temperature,omitempty"`
-	}
-
-	type recipeResponse struct {
-		Item struct {
-			ID          string  `json:"id,omitempty"`
-			Name        string  `json:"name,omitempty"`
-			Description string  `json:"description,omitempty"`
-			Cuisine     cuisine `json:"cuisine,omitempty"`
-			CreatedAt   string  `json:"created_at"`
-		} `json:"item"`
-		Ingredients []ingredient `json:"ingredients,omitempty"`
-		Variations  []string     `json:"variations,omitempty"`
-		Prep        []prep       `json:"prep,omitempty"`
-		Steps       []step       `json:"steps,omitempty"`
-	}
-
-	convertResponse := func(r recipe.Recipe) recipeResponse {
-		var res recipeResponse
-		res.Item.ID = r.ID().String()
-		res.Item.Name = r.Name()
-		res.Item.Description = r.Description()
-		res.Item.CreatedAt = r.CreatedAt()
-		var c cuisine
-		c.FromDomain(r.Cuisine())
-		res.Item.Cuisine = c
-		for _, v := range r.Ingredients() {
-			res.Ingredients = append(res.Ingredients, ingredient{ID: v.ID.String(), Name: v.Name, Type: int(v.Type)})
-		}
-		res.Variations = r.Variations()
-		for _, p := range r.Prep() {
-			res.Prep = append(res.Prep, prep{IngredientID: p.Ingredient(), Action: p.Action()})
-		}
-		for _, s := range r.Steps() {
-			res.Steps = append(res.Steps, step{IngredientID: s.Ingredient(), Action: s.Action(), Temperature: s.Temperature()})
-		}
-		return res
-	}
-
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		id := r.PathValue("id")
 		ctx := r.Context()
-		recipeRes, err := rs.GetRecipe(ctx, id)
 
+		system, err := units.ParseSystem(r.URL.Query().Get("units"))
 		if err != nil {
-			var errRes errResponse
-			var status int
-			if errors.Is(err, context.DeadlineExceeded) {
-				slog.ErrorContext(ctx, "get recipe exceeded timeout", "recipe_id", id)
-				status = http.StatusGatewayTimeout
-				errRes = errResponse{ErrCode: 50001, Msg: "service time out"}
-			}
+			slog.ErrorContext(ctx, "unknown measurement system in query", "units", r.URL.Query().Get("units"))
+			statsCollection.BadRequestInc("get_recipe")
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40004, Msg: "units must be metric or imperial"})
+			return
+		}
 
-			if errors.Is(err, recipe.ErrRecipeNotFound) {
-				slog.ErrorContext(ctx, "recipe not found for given id", "recipe_id", id)
-				status = http.StatusNotFound
-				errRes = errResponse{ErrCode: 40401, Msg: fmt.Sprintf("recipe not found for id: %s", id)}
-			}
+		temperatureUnit, err := units.ParseTemperatureUnit(r.URL.Query().Get("temperature"))
+		if err != nil {
+			slog.ErrorContext(ctx, "unknown temperature unit in query", "temperature", r.URL.Query().Get("temperature"))
+			statsCollection.BadRequestInc("get_recipe")
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40013, Msg: "temperature must be C, F or gas_mark"})
+			return
+		}
 
-			if errors.Is(err, recipe.ErrInvalidID) {
-				slog.ErrorContext(ctx, "invalid id format", "recipe_id", id)
+		var servings int
+		if v := r.URL.Query().Get("servings"); v != "" {
+			servings, err = strconv.Atoi(v)
+			if err != nil || servings <= 0 {
+				slog.ErrorContext(ctx, "invalid servings in query", "servings", v)
 				statsCollection.BadRequestInc("get_recipe")
-				status = http.StatusBadRequest
-				errRes = errResponse{ErrCode: 40001, Msg: fmt.Sprintf("invalid format for id: %s", id)}
+				encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40005, Msg: "servings must be a positive number"})
+				return
 			}
+		}
 
+		recipeRes, err := rs.GetResolvedRecipe(ctx, id)
+		if err != nil {
+			status, errRes := recipeErrResponse(ctx, statsCollection, "get_recipe", id, err)
 			encode[errResponse](w, status, errRes)
 			return
 		}
 
+		if servings > 0 {
+			recipeRes, err = recipeRes.Scale(servings)
+			if err != nil {
+				slog.ErrorContext(ctx, "recipe cannot be scaled", "recipe_id", id, "err", err.Error())
+				statsCollection.BadRequestInc("get_recipe")
+				encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40006, Msg: "recipe does not state how many it serves"})
+				return
+			}
+		}
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "get_recipe")
+		if !ok {
+			return
+		}
+
 		statsCollection.StatusOkInc("get_recipe")
 		statsCollection.ResponseTime("get_recipe", time.Since(start).Milliseconds())
-		encode[recipeResponse](w, http.StatusOK, convertResponse(recipeRes))
+		recipeRes = recipeRes.InSystem(system).InTemperatureUnit(temperatureUnit)
+		encode[recipeResponse](w, http.StatusOK, newRecipeResponse(recipeRes, cuisines))
 	})
 }
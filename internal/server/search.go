@@ -0,0 +1,103 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/search"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+type highlightResponse struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+type searchHitResponse struct {
+	Item       itemResponse        `json:"item"`
+	Score      float64             `json:"score"`
+	Highlights []highlightResponse `json:"highlights"`
+}
+
+func handleSearchRecipes(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type response struct {
+		Items  []searchHitResponse `json:"items"`
+		Facets *facetsResponse     `json:"facets,omitempty"`
+	}
+
+	badRequest := func(w http.ResponseWriter, r *http.Request, err error) {
+		slog.ErrorContext(r.Context(), "invalid recipe search", "query", r.URL.RawQuery, "err", err.Error())
+		statsCollection.BadRequestInc("search_recipes")
+		encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40010, Msg: err.Error()})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := r.Context()
+
+		var withFacets bool
+		switch r.URL.Query().Get("facets") {
+		case "", "false":
+		case "true":
+			withFacets = true
+		default:
+			badRequest(w, r, errors.New("facets must be true or false"))
+			return
+		}
+
+		var limit int
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > recipe.MaxPageSize {
+				badRequest(w, r, fmt.Errorf("limit must be between 1 and %d", recipe.MaxPageSize))
+				return
+			}
+		}
+
+		hits, facets, err := rs.SearchRecipes(ctx, r.URL.Query().Get("q"), limit, withFacets)
+		if err != nil {
+			if errors.Is(err, search.ErrEmptyQuery) || errors.Is(err, recipe.ErrInvalidQuery) {
+				badRequest(w, r, err)
+				return
+			}
+			status, errRes := recipeErrResponse(ctx, statsCollection, "search_recipes", "", err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "search_recipes")
+		if !ok {
+			return
+		}
+
+		res := response{Items: make([]searchHitResponse, 0, len(hits))}
+		for _, h := range hits {
+			hit := searchHitResponse{
+				Item:       newItemResponse(h.Recipe, cuisines),
+				Score:      h.Score,
+				Highlights: make([]highlightResponse, 0, len(h.Highlights)),
+			}
+			for _, hl := range h.Highlights {
+				hit.Highlights = append(hit.Highlights, highlightResponse{Field: hl.Field, Snippet: hl.Snippet})
+			}
+			res.Items = append(res.Items, hit)
+		}
+		if facets != nil {
+			types, ok := loadTaxonomy(ctx, w, rs, statsCollection, "search_recipes")
+			if !ok {
+				return
+			}
+			res.Facets = newFacetsResponse(*facets, cuisines, types)
+		}
+
+		statsCollection.StatusOkInc("search_recipes")
+		statsCollection.ResponseTime("search_recipes", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+func handleSimilarRecipes(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type similar struct {
+		Item              itemResponse `json:"item"`
+		Score             float64      `json:"score"`
+		SameCuisine       bool         `json:"same_cuisine"`
+		SharedIngredients []string     `json:"shared_ingredients,omitempty"`
+		SharedTechniques  []string     `json:"shared_techniques,omitempty"`
+	}
+	type response struct {
+		Items []similar `json:"items"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		var limit int
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > recipe.MaxPageSize {
+				slog.ErrorContext(ctx, "invalid similar recipes query", "recipe_id", id, "query", r.URL.RawQuery)
+				statsCollection.BadRequestInc("similar_recipes")
+				encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40017, Msg: fmt.Sprintf("limit must be between 1 and %d", recipe.MaxPageSize)})
+				return
+			}
+		}
+
+		similarities, err := rs.SimilarRecipes(ctx, id, limit)
+		if err != nil {
+			status, errRes := recipeErrResponse(ctx, statsCollection, "similar_recipes", id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "similar_recipes")
+		if !ok {
+			return
+		}
+
+		res := response{Items: make([]similar, 0, len(similarities))}
+		for _, s := range similarities {
+			res.Items = append(res.Items, similar{
+				Item:              newItemResponse(s.Recipe, cuisines),
+				Score:             s.Score,
+				SameCuisine:       s.SameCuisine,
+				SharedIngredients: s.SharedIngredients,
+				SharedTechniques:  s.SharedTechniques,
+			})
+		}
+
+		statsCollection.StatusOkInc("similar_recipes")
+		statsCollection.ResponseTime("similar_recipes", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/domain/units"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+// temperatureUnit decodes the user facing names of temperature units.
+type temperatureUnit struct {
+	unit units.TemperatureUnit
+}
+
+func (t *temperatureUnit) UnmarshalText(data []byte) error {
+	unit, err := units.ParseTemperatureUnit(string(data))
+	if err != nil {
+		return err
+	}
+	t.unit = unit
+	return nil
+}
+
+type method string
+
+var methods = map[method]domain.CookingMethod{
+	"bake":      domain.Bake,
+	"roast":     domain.Roast,
+	"grill":     domain.Grill,
+	"fry":       domain.Fry,
+	"deep_fry":  domain.DeepFry,
+	"saute":     domain.Saute,
+	"boil":      domain.Boil,
+	"simmer":    domain.Simmer,
+	"poach":     domain.Poach,
+	"steam":     domain.Steam,
+	"braise":    domain.Braise,
+	"sous_vide": domain.SousVide,
+}
+
+func (m *method) UnmarshalText(data []byte) error {
+	s := method(strings.ToLower(string(data)))
+	if _, ok := methods[s]; !ok {
+		return fmt.Errorf("unknown cooking method: %s", data)
+	}
+	*m = s
+	return nil
+}
+
+// ToDomain maps the empty method onto domain.UnknownMethod.
+func (m method) ToDomain() domain.CookingMethod {
+	return methods[m]
+}
+
+func (m *method) FromDomain(dm domain.CookingMethod) {
+	for k, v := range methods {
+		if v == dm {
+			*m = k
+			return
+		}
+	}
+}
+
+type moveRequest struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+func handleMovePrep(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return handleRecipeChange(rs, statsCollection, "move_prep", func(ctx context.Context, id string, req moveRequest) (recipe.Recipe, error) {
+		return rs.MovePrep(ctx, id, req.From, req.To)
+	})
+}
+
+func handleMoveStep(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return handleRecipeChange(rs, statsCollection, "move_step", func(ctx context.Context, id string, req moveRequest) (recipe.Recipe, error) {
+		return rs.MoveStep(ctx, id, req.From, req.To)
+	})
+}
+
+func handleRemovePrep(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return handleRemoveAt(rs, statsCollection, "remove_prep", rs.RemovePrep)
+}
+
+func handleRemoveStep(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return handleRemoveAt(rs, statsCollection, "remove_step", rs.RemoveStep)
+}
+
+// handleRemoveAt removes the entry at the {pos} of the path, responding
+// with the recipe as it is afterwards.
+func handleRemoveAt(
+	rs recipeService,
+	statsCollection *stats.StatsCollection,
+	endpoint string,
+	remove func(ctx context.Context, id string, pos int) (recipe.Recipe, error),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		// a position that is not a number is no position at all
+		pos, err := strconv.Atoi(r.PathValue("pos"))
+		if err != nil {
+			pos = -1
+		}
+
+		res, err := remove(ctx, id, pos)
+		if err != nil {
+			status, errRes := recipeErrResponse(ctx, statsCollection, endpoint, id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, endpoint)
+		if !ok {
+			return
+		}
+
+		statsCollection.StatusOkInc(endpoint)
+		statsCollection.ResponseTime(endpoint, time.Since(start).Milliseconds())
+		encode[recipeResponse](w, http.StatusOK, newRecipeResponse(res, cuisines))
+	})
+}
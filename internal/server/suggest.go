@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+func handleSuggestRecipes(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type suggestion struct {
+		Text string `json:"text"`
+		Kind string `json:"kind"`
+	}
+	type response struct {
+		Items []suggestion `json:"items"`
+	}
+
+	badRequest := func(w http.ResponseWriter, r *http.Request, err error) {
+		slog.ErrorContext(r.Context(), "invalid suggest query", "query", r.URL.RawQuery, "err", err.Error())
+		statsCollection.BadRequestInc("suggest_recipes")
+		encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40011, Msg: err.Error()})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := r.Context()
+
+		var limit int
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > recipe.MaxPageSize {
+				badRequest(w, r, fmt.Errorf("limit must be between 1 and %d", recipe.MaxPageSize))
+				return
+			}
+		}
+
+		suggestions, err := rs.SuggestRecipes(ctx, r.URL.Query().Get("prefix"), limit)
+		if err != nil {
+			if errors.Is(err, recipe.ErrInvalidQuery) {
+				badRequest(w, r, errors.New("prefix must not be empty"))
+				return
+			}
+			status, errRes := recipeErrResponse(ctx, statsCollection, "suggest_recipes", "", err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		res := response{Items: make([]suggestion, 0, len(suggestions))}
+		for _, s := range suggestions {
+			res.Items = append(res.Items, suggestion{Text: s.Text, Kind: s.Kind})
+		}
+
+		statsCollection.StatusOkInc("suggest_recipes")
+		statsCollection.ResponseTime("suggest_recipes", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain/taxonomy"
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+// loadTaxonomy fetches the ingredient taxonomy that resolves ingredient
+// types in requests. On failure it writes the error response itself
+// and reports false.
+func loadTaxonomy(ctx context.Context, w http.ResponseWriter, rs recipeService, statsCollection *stats.StatsCollection, endpoint string) (taxonomy.Taxonomy, bool) {
+	tx, err := rs.Taxonomy(ctx)
+	if err != nil {
+		status, errRes := recipeErrResponse(ctx, statsCollection, endpoint, "", err)
+		encode[errResponse](w, status, errRes)
+		return taxonomy.Taxonomy{}, false
+	}
+	return tx, true
+}
+
+type ingredientTypeResponse struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	Parent   string   `json:"parent,omitempty"`
+	Path     []string `json:"path"`
+	Children []string `json:"children,omitempty"`
+}
+
+func newIngredientTypeResponse(c taxonomy.Category, tx taxonomy.Taxonomy) ingredientTypeResponse {
+	res := ingredientTypeResponse{
+		ID:     int(c.ID()),
+		Name:   c.Name(),
+		Parent: tx.Name(c.Parent()),
+		Path:   tx.Path(c.ID()),
+	}
+	for _, child := range tx.Children(c.ID()) {
+		res.Children = append(res.Children, child.Name())
+	}
+	return res
+}
+
+func taxonomyErrResponse(ctx context.Context, statsCollection *stats.StatsCollection, endpoint string, name string, err error) (int, errResponse) {
+	switch {
+	case errors.Is(err, taxonomy.ErrCategoryNotFound):
+		slog.ErrorContext(ctx, "ingredient type not found", "ingredient_type", name)
+		return http.StatusNotFound, errResponse{ErrCode: 40404, Msg: fmt.Sprintf("ingredient type not found: %s", name)}
+	case errors.Is(err, taxonomy.ErrInvalidCategory),
+		errors.Is(err, taxonomy.ErrCategoryExists),
+		errors.Is(err, taxonomy.ErrParentNotFound),
+		errors.Is(err, taxonomy.ErrCategoryCycle):
+		slog.ErrorContext(ctx, "invalid ingredient type change", "ingredient_type", name, "err", err.Error())
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusBadRequest, errResponse{ErrCode: 40016, Msg: err.Error()}
+	case errors.Is(err, taxonomy.ErrCategoryHasChildren),
+		errors.Is(err, taxonomy.ErrCategoryInUse):
+		slog.ErrorContext(ctx, "ingredient type still in use", "ingredient_type", name, "err", err.Error())
+		statsCollection.BadRequestInc(endpoint)
+		return http.StatusConflict, errResponse{ErrCode: 40904, Msg: err.Error()}
+	default:
+		return recipeErrResponse(ctx, statsCollection, endpoint, "", err)
+	}
+}
+
+func handleListIngredientTypes(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type response struct {
+		Items []ingredientTypeResponse `json:"items"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := r.Context()
+
+		tx, ok := loadTaxonomy(ctx, w, rs, statsCollection, "list_ingredient_types")
+		if !ok {
+			return
+		}
+
+		all := tx.All()
+		res := response{Items: make([]ingredientTypeResponse, 0, len(all))}
+		for _, c := range all {
+			res.Items = append(res.Items, newIngredientTypeResponse(c, tx))
+		}
+
+		statsCollection.StatusOkInc("list_ingredient_types")
+		statsCollection.ResponseTime("list_ingredient_types", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
+
+func handleGetIngredientType(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		name := r.PathValue("name")
+		ctx := r.Context()
+
+		tx, ok := loadTaxonomy(ctx, w, rs, statsCollection, "get_ingredient_type")
+		if !ok {
+			return
+		}
+		c, ok := tx.Lookup(name)
+		if !ok {
+			status, errRes := taxonomyErrResponse(ctx, statsCollection, "get_ingredient_type", name, taxonomy.ErrCategoryNotFound)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		statsCollection.StatusOkInc("get_ingredient_type")
+		statsCollection.ResponseTime("get_ingredient_type", time.Since(start).Milliseconds())
+		encode[ingredientTypeResponse](w, http.StatusOK, newIngredientTypeResponse(c, tx))
+	})
+}
+
+type ingredientTypeRequest struct {
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+}
+
+// handleIngredientTypeChange wires up the endpoints that create and
+// update ingredient types, which share their request and response.
+func handleIngredientTypeChange(
+	rs recipeService,
+	statsCollection *stats.StatsCollection,
+	endpoint string,
+	apply func(ctx context.Context, name string, req ingredientTypeRequest) (taxonomy.Category, error),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		name := r.PathValue("name")
+		ctx := r.Context()
+
+		reqObj, err := decode[ingredientTypeRequest](r)
+		if err != nil {
+			slog.ErrorContext(ctx, "parsing request object failed", "endpoint", endpoint)
+			statsCollection.BadRequestInc(endpoint)
+			encode[errResponse](w, http.StatusBadRequest, errResponse{ErrCode: 40002, Msg: "Issue in parsing request body"})
+			return
+		}
+
+		c, err := apply(ctx, name, reqObj)
+		if err != nil {
+			status, errRes := taxonomyErrResponse(ctx, statsCollection, endpoint, name, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+		tx, ok := loadTaxonomy(ctx, w, rs, statsCollection, endpoint)
+		if !ok {
+			return
+		}
+
+		statsCollection.StatusOkInc(endpoint)
+		statsCollection.ResponseTime(endpoint, time.Since(start).Milliseconds())
+		encode[ingredientTypeResponse](w, http.StatusOK, newIngredientTypeResponse(c, tx))
+	})
+}
+
+func handleCreateIngredientType(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return handleIngredientTypeChange(rs, statsCollection, "create_ingredient_type", func(ctx context.Context, _ string, req ingredientTypeRequest) (taxonomy.Category, error) {
+		return rs.CreateIngredientType(ctx, req.Name, req.Parent)
+	})
+}
+
+// handleUpdateIngredientType renames an ingredient type or moves it in
+// the tree. Leaving the name out keeps the current one; leaving the
+// parent out moves it to the top.
+func handleUpdateIngredientType(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return handleIngredientTypeChange(rs, statsCollection, "update_ingredient_type", func(ctx context.Context, name string, req ingredientTypeRequest) (taxonomy.Category, error) {
+		return rs.UpdateIngredientType(ctx, name, req.Name, req.Parent)
+	})
+}
+
+func handleDeleteIngredientType(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		name := r.PathValue("name")
+		ctx := r.Context()
+
+		if err := rs.DeleteIngredientType(ctx, name); err != nil {
+			status, errRes := taxonomyErrResponse(ctx, statsCollection, "delete_ingredient_type", name, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		slog.InfoContext(ctx, "ingredient type deleted", "ingredient_type", name)
+		statsCollection.StatusOkInc("delete_ingredient_type")
+		statsCollection.ResponseTime("delete_ingredient_type", time.Since(start).Milliseconds())
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+func handleGetTimeline(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type scheduledStep struct {
+		stepResponse
+		StartMinute int  `json:"start_minute"`
+		EndMinute   int  `json:"end_minute"`
+		Critical    bool `json:"critical"`
+	}
+	type response struct {
+		ElapsedMinutes int             `json:"elapsed_minutes"`
+		HandsOnMinutes int             `json:"hands_on_minutes"`
+		Steps          []scheduledStep `json:"steps"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		timeline, err := rs.RecipeTimeline(ctx, id)
+		if err != nil {
+			status, errRes := recipeErrResponse(ctx, statsCollection, "get_timeline", id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		res := response{
+			ElapsedMinutes: int(timeline.Elapsed / time.Minute),
+			HandsOnMinutes: int(timeline.HandsOn / time.Minute),
+			Steps:          make([]scheduledStep, 0, len(timeline.Steps)),
+		}
+		for _, s := range timeline.Steps {
+			res.Steps = append(res.Steps, scheduledStep{
+				stepResponse: newStepResponse(s.Step),
+				StartMinute:  int(s.Start / time.Minute),
+				EndMinute:    int(s.End / time.Minute),
+				Critical:     s.Critical,
+			})
+		}
+
+		statsCollection.StatusOkInc("get_timeline")
+		statsCollection.ResponseTime("get_timeline", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
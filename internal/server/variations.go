@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/stats"
+)
+
+func handleGetVariations(rs recipeService, statsCollection *stats.StatsCollection) http.Handler {
+	type response struct {
+		Items []recipeResponse `json:"items"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.PathValue("id")
+		ctx := r.Context()
+
+		variations, err := rs.RecipeVariations(ctx, id)
+		if err != nil {
+			status, errRes := recipeErrResponse(ctx, statsCollection, "get_variations", id, err)
+			encode[errResponse](w, status, errRes)
+			return
+		}
+
+		cuisines, ok := loadCuisines(ctx, w, rs, statsCollection, "get_variations")
+		if !ok {
+			return
+		}
+
+		res := response{Items: make([]recipeResponse, 0, len(variations))}
+		for _, v := range variations {
+			res.Items = append(res.Items, newRecipeResponse(v, cuisines))
+		}
+
+		statsCollection.StatusOkInc("get_variations")
+		statsCollection.ResponseTime("get_variations", time.Since(start).Milliseconds())
+		encode[response](w, http.StatusOK, res)
+	})
+}
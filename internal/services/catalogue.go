@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/db"
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/catalogue"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/search"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type catalogueRepository interface {
+	Get(context.Context, uuid.UUID) (catalogue.Entry, error)
+	FindByName(context.Context, string) (catalogue.Entry, error)
+	List(context.Context, string, int) ([]catalogue.Entry, error)
+	All(context.Context) ([]catalogue.Entry, error)
+	Add(context.Context, catalogue.Entry) error
+	Update(context.Context, catalogue.Entry) (catalogue.Entry, error)
+	Delete(context.Context, uuid.UUID) error
+	UsesType(context.Context, domain.IngredientType) (bool, error)
+}
+
+// WithMemoryCatalogue keeps the ingredient catalogue in memory. It is
+// filled from the ingredients of the recipes already stored, so
+// configure the recipe repository first: recipes kept across restarts
+// then keep pointing at catalogue entries.
+func WithMemoryCatalogue() RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		rs.catalogue = catalogue.NewMemoryRepository()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := rs.fillCatalogue(ctx); err != nil {
+			return fmt.Errorf("filling ingredient catalogue failed: %w", err)
+		}
+		return nil
+	}
+}
+
+// WithSQLiteCatalogue stores the ingredient catalogue in the given
+// SQLite database, migrating its schema first. Like WithMemoryCatalogue
+// it adds the ingredients of recipes stored before there was a
+// catalogue, so configure the recipe repository first.
+func WithSQLiteCatalogue(db *sql.DB) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		sr := catalogue.NewSQLiteRepository(db)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := sr.Migrate(ctx); err != nil {
+			return fmt.Errorf("migrating ingredient catalogue failed: %w", err)
+		}
+		rs.catalogue = sr
+		if err := rs.fillCatalogue(ctx); err != nil {
+			return fmt.Errorf("filling ingredient catalogue failed: %w", err)
+		}
+		return nil
+	}
+}
+
+// WithDurableMemoryCatalogue goes with WithDurableMemoryRepository: it
+// keeps the ingredient catalogue in a SQLite database in dir, so
+// entries and their aliases survive a restart along with the recipes.
+// Close closes the database.
+func WithDurableMemoryCatalogue(dir string) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		sqlDB, err := db.OpenSQLite(filepath.Join(dir, "catalogue.db"))
+		if err != nil {
+			return fmt.Errorf("opening ingredient catalogue failed: %w", err)
+		}
+		if err := WithSQLiteCatalogue(sqlDB)(rs); err != nil {
+			sqlDB.Close()
+			return err
+		}
+		rs.catalogue = closingCatalogue{catalogueRepository: rs.catalogue, db: sqlDB}
+		return nil
+	}
+}
+
+// closingCatalogue is a catalogue with a database of its own to close.
+type closingCatalogue struct {
+	catalogueRepository
+	db *sql.DB
+}
+
+func (c closingCatalogue) Close() error {
+	return c.db.Close()
+}
+
+// WithMongoCatalogue stores the ingredient catalogue in
+// INGREDIENT_COLLECTION, "ingredient" unless set, next to the recipes.
+func WithMongoCatalogue(client *mongo.Client, getEnv func(string) string) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		databaseName, collectionName, err := mongoCollection(getEnv, "INGREDIENT_COLLECTION", "ingredient")
+		if err != nil {
+			return err
+		}
+
+		mr := catalogue.NewMongoRepository(client, databaseName, collectionName)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mr.EnsureIndexes(ctx); err != nil {
+			return fmt.Errorf("creating ingredient indexes failed: %w", err)
+		}
+		rs.catalogue = mr
+		return nil
+	}
+}
+
+// fillCatalogue adds an entry for every ingredient of the stored
+// recipes the catalogue does not have yet, deleted ones included, as
+// restoring one brings its ingredients back. Where recipes written
+// before the catalogue gave the same name different ids, the first
+// one becomes the entry and the others stay recipe-local.
+func (rs RecipeService) fillCatalogue(ctx context.Context) error {
+	if rs.recipes == nil {
+		return nil
+	}
+	recipes, err := rs.listAll(ctx, recipe.Query{IncludeDeleted: true})
+	if err != nil {
+		return err
+	}
+	for _, r := range recipes {
+		for _, l := range r.Ingredients() {
+			e, err := catalogue.FromIngredient(l.Ingredient())
+			if err != nil {
+				continue
+			}
+			if err := rs.catalogue.Add(ctx, e); err != nil && !errors.Is(err, catalogue.ErrEntryExists) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (rs RecipeService) GetIngredient(ctx context.Context, uuidStr string) (catalogue.Entry, error) {
+	id, err := parseID(uuidStr)
+	if err != nil {
+		return catalogue.Entry{}, err
+	}
+	return rs.catalogue.Get(ctx, id)
+}
+
+// ListIngredients returns up to limit catalogue entries whose name
+// starts with prefix, by name.
+func (rs RecipeService) ListIngredients(ctx context.Context, prefix string, limit int) ([]catalogue.Entry, error) {
+	if limit == 0 {
+		limit = catalogue.DefaultPageSize
+	}
+	if limit < 0 || limit > catalogue.MaxPageSize {
+		return nil, catalogue.ErrInvalidQuery
+	}
+	return rs.catalogue.List(ctx, prefix, limit)
+}
+
+// CreateIngredient adds an ingredient to the catalogue along with the
+// other names it goes by.
+func (rs RecipeService) CreateIngredient(ctx context.Context, ingredient domain.Ingredient, aliases []string) (catalogue.Entry, error) {
+	if err := rs.checkIngredientType(ctx, ingredient.Type); err != nil {
+		return catalogue.Entry{}, err
+	}
+	e, err := catalogue.NewEntry(ingredient.Name, ingredient.Description, ingredient.Type, ingredient.Density, aliases)
+	if err != nil {
+		return catalogue.Entry{}, err
+	}
+	if err := rs.catalogue.Add(ctx, e); err != nil {
+		return catalogue.Entry{}, err
+	}
+	rs.lexicon.invalidate()
+	slog.InfoContext(ctx, "ingredient successfully added", "ingredient_id", e.ID().String())
+	return e, nil
+}
+
+// UpdateIngredient changes a catalogue entry and then every recipe
+// listing it, deleted ones included so they are current when restored.
+// Should refreshing a recipe fail, the entry is already changed;
+// repeating the update finishes the job.
+func (rs RecipeService) UpdateIngredient(ctx context.Context, uuidStr string, patch catalogue.Patch) (catalogue.Entry, error) {
+	e, err := rs.GetIngredient(ctx, uuidStr)
+	if err != nil {
+		return catalogue.Entry{}, err
+	}
+	if patch.Type != nil {
+		if err := rs.checkIngredientType(ctx, *patch.Type); err != nil {
+			return catalogue.Entry{}, err
+		}
+	}
+	if err := e.Apply(patch); err != nil {
+		return catalogue.Entry{}, err
+	}
+	e, err = rs.catalogue.Update(ctx, e)
+	if err != nil {
+		return catalogue.Entry{}, err
+	}
+	rs.lexicon.invalidate()
+	slog.InfoContext(ctx, "ingredient successfully updated", "ingredient_id", e.ID().String())
+
+	ids, err := rs.recipesUsing(ctx, e.ID())
+	if err != nil {
+		return catalogue.Entry{}, err
+	}
+	var refreshed int
+	for _, id := range ids {
+		ok, err := rs.refreshIngredient(ctx, id, e.Ingredient())
+		if err != nil {
+			return catalogue.Entry{}, fmt.Errorf("refreshing recipe %s failed: %w", id, err)
+		}
+		if ok {
+			refreshed++
+		}
+	}
+	if refreshed > 0 {
+		slog.InfoContext(ctx, "recipes refreshed with updated ingredient", "ingredient_id", e.ID().String(), "recipes", refreshed)
+	}
+	return e, nil
+}
+
+// maxRefreshAttempts bounds how often refreshing a recipe is retried
+// when other requests keep changing it.
+const maxRefreshAttempts = 3
+
+// errUnchanged stops write for a recipe that is current already.
+var errUnchanged = errors.New("recipe is unchanged")
+
+// refreshIngredient rewrites the stored recipe with the given id,
+// deleted or not, to the given version of one of its ingredients,
+// reporting whether it had to. The recipe is read fresh for every
+// attempt, so changes made since it was listed are kept.
+func (rs RecipeService) refreshIngredient(ctx context.Context, id uuid.UUID, ingredient domain.Ingredient) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		r, err := rs.recipes.Get(ctx, id)
+		if errors.Is(err, recipe.ErrRecipeNotFound) {
+			// purged since it was listed
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		_, err = rs.write(ctx, r, func(r *recipe.Recipe) error {
+			if !r.RefreshIngredient(ingredient) {
+				return errUnchanged
+			}
+			return nil
+		})
+		switch {
+		case errors.Is(err, errUnchanged):
+			return false, nil
+		case errors.Is(err, recipe.ErrRecipeConflict) && attempt < maxRefreshAttempts:
+			continue
+		case err != nil:
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+// recipesUsing lists the ids of the stored recipes with a line for
+// the ingredient, deleted ones included.
+func (rs RecipeService) recipesUsing(ctx context.Context, ingredientID uuid.UUID) ([]uuid.UUID, error) {
+	recipes, err := rs.listAll(ctx, recipe.Query{Ingredients: []uuid.UUID{ingredientID}, IncludeDeleted: true})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(recipes))
+	for _, r := range recipes {
+		ids = append(ids, r.ID())
+	}
+	return ids, nil
+}
+
+// DeleteIngredient removes a catalogue entry no recipe uses. Deleted
+// recipes count too, since restoring one brings its ingredients back
+// into use.
+func (rs RecipeService) DeleteIngredient(ctx context.Context, uuidStr string) error {
+	id, err := parseID(uuidStr)
+	if err != nil {
+		return err
+	}
+	if _, err := rs.catalogue.Get(ctx, id); err != nil {
+		return err
+	}
+	ids, err := rs.recipesUsing(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return catalogue.ErrEntryInUse
+	}
+	if err := rs.catalogue.Delete(ctx, id); err != nil {
+		return err
+	}
+	rs.lexicon.invalidate()
+	slog.InfoContext(ctx, "ingredient successfully deleted", "ingredient_id", id.String())
+	return nil
+}
+
+// catalogueIngredient resolves the ingredient a recipe line is for. An
+// ingredient with an id has to be in the catalogue and is taken as it
+// is there. One without is looked up by name, or any alias, and added
+// to the catalogue when it is new, so recipes share one entry per
+// ingredient whichever name it was added by.
+func (rs RecipeService) catalogueIngredient(ctx context.Context, ingredient domain.Ingredient) (domain.Ingredient, error) {
+	if ingredient.ID != uuid.Nil {
+		e, err := rs.catalogue.Get(ctx, ingredient.ID)
+		if err != nil {
+			return domain.Ingredient{}, err
+		}
+		return e.Ingredient(), nil
+	}
+	e, err := rs.catalogue.FindByName(ctx, ingredient.Name)
+	if err == nil {
+		return e.Ingredient(), nil
+	}
+	if !errors.Is(err, catalogue.ErrEntryNotFound) {
+		return domain.Ingredient{}, err
+	}
+	e, err = rs.CreateIngredient(ctx, ingredient, nil)
+	if errors.Is(err, catalogue.ErrEntryExists) {
+		// added by someone else since it was looked up
+		e, err = rs.catalogue.FindByName(ctx, ingredient.Name)
+	}
+	if err != nil {
+		return domain.Ingredient{}, err
+	}
+	return e.Ingredient(), nil
+}
+
+// maxSynonymWords bounds how many words in a row a search looks up as
+// one ingredient name.
+const maxSynonymWords = 4
+
+// lexicon caches the key of every catalogue name and alias along with
+// the name of its entry, which every search needs. Writes through the
+// service drop it.
+type lexicon struct {
+	mu       sync.Mutex
+	names    map[string]string
+	loadedAt time.Time
+}
+
+func (l *lexicon) invalidate() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.names = nil
+}
+
+// ingredientNames maps the keys of catalogue names and aliases to the
+// names of their entries, loading them when the cached ones are
+// missing or too old.
+func (rs RecipeService) ingredientNames(ctx context.Context) (map[string]string, error) {
+	rs.lexicon.mu.Lock()
+	defer rs.lexicon.mu.Unlock()
+	if rs.lexicon.names != nil && time.Since(rs.lexicon.loadedAt) < registryMaxAge {
+		return rs.lexicon.names, nil
+	}
+	entries, err := rs.catalogue.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(entries))
+	for _, e := range entries {
+		names[catalogue.Key(e.Name())] = e.Name()
+		for _, a := range e.Aliases() {
+			names[catalogue.Key(a)] = e.Name()
+		}
+	}
+	rs.lexicon.names = names
+	rs.lexicon.loadedAt = time.Now()
+	return names, nil
+}
+
+// canonicalQuery widens the words of q that name a catalogue
+// ingredient by another of its names to either one, so searching for
+// cilantro finds recipes listing coriander as well as those saying
+// cilantro. Longer runs of words are tried first; quoted phrases are
+// left as typed.
+func (rs RecipeService) canonicalQuery(ctx context.Context, q search.Query) (search.Query, error) {
+	names, err := rs.ingredientNames(ctx)
+	if err != nil {
+		return search.Query{}, err
+	}
+	terms := make([]search.Term, 0, len(q.Terms))
+	for i := 0; i < len(q.Terms); {
+		n := min(maxSynonymWords, len(q.Terms)-i)
+		for ; n > 0; n-- {
+			run := slices.Clone(q.Terms[i : i+n])
+			words := make([]string, 0, n)
+			for _, t := range run {
+				words = append(words, t.Word)
+			}
+			key := catalogue.Key(strings.Join(words, " "))
+			name, ok := names[key]
+			if !ok {
+				continue
+			}
+			if catalogue.Key(name) == key {
+				terms = append(terms, run...)
+				break
+			}
+			canonical, err := search.ParseQuery(name)
+			if err != nil {
+				// a name of nothing but stop words
+				continue
+			}
+			q.Synonyms = append(q.Synonyms, search.Synonyms{run, canonical.Terms})
+			break
+		}
+		if n == 0 {
+			terms = append(terms, q.Terms[i])
+			n = 1
+		}
+		i += n
+	}
+	q.Terms = terms
+	return q, nil
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/cuisine"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// registryMaxAge bounds how stale the cached cuisine registry can get
+// when cuisines are changed by another instance sharing the database.
+const registryMaxAge = time.Minute
+
+type cuisineRepository interface {
+	List(context.Context) ([]cuisine.Cuisine, error)
+	// NextID never hands out an id twice, not even one whose cuisine
+	// has been deleted, so links to a removed cuisine cannot come back
+	// pointing at a new one.
+	NextID(context.Context) (domain.CuisineType, error)
+	Add(context.Context, cuisine.Cuisine) error
+	Update(context.Context, cuisine.Cuisine) error
+	Delete(context.Context, domain.CuisineType) error
+}
+
+// registry caches the cuisine registry, which every recipe read needs
+// to name cuisines. Writes through the service drop it.
+type registry struct {
+	mu       sync.Mutex
+	registry *cuisine.Registry
+	loadedAt time.Time
+}
+
+func (r *registry) invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registry = nil
+}
+
+// WithMemoryCuisines keeps the cuisine registry in memory, starting
+// from seed. Changes are lost on restart, so seed is normally the
+// cuisines file or cuisine.Defaults.
+func WithMemoryCuisines(seed []cuisine.Cuisine) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		rs.cuisines = cuisine.NewMemoryRepository(seed...)
+		return nil
+	}
+}
+
+// WithMongoCuisines stores the cuisine registry in CUISINE_COLLECTION,
+// "cuisine" unless set, next to the recipes.
+func WithMongoCuisines(client *mongo.Client, getEnv func(string) string, seed []cuisine.Cuisine) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		databaseName, collectionName, err := mongoCollection(getEnv, "CUISINE_COLLECTION", "cuisine")
+		if err != nil {
+			return err
+		}
+
+		mr := cuisine.NewMongoRepository(client, databaseName, collectionName)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mr.Seed(ctx, seed); err != nil {
+			return fmt.Errorf("seeding cuisines failed: %w", err)
+		}
+		rs.cuisines = mr
+		return nil
+	}
+}
+
+// WithSQLiteCuisines stores the cuisine registry in the given SQLite
+// database, migrating its schema and seeding it when it is empty.
+func WithSQLiteCuisines(db *sql.DB, seed []cuisine.Cuisine) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		sr := cuisine.NewSQLiteRepository(db)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := sr.Migrate(ctx); err != nil {
+			return fmt.Errorf("migrating cuisines failed: %w", err)
+		}
+		if err := sr.Seed(ctx, seed); err != nil {
+			return fmt.Errorf("seeding cuisines failed: %w", err)
+		}
+		rs.cuisines = sr
+		return nil
+	}
+}
+
+// Cuisines returns the cuisine registry, loading it when the cached
+// one is missing or too old.
+func (rs RecipeService) Cuisines(ctx context.Context) (cuisine.Registry, error) {
+	rs.registry.mu.Lock()
+	defer rs.registry.mu.Unlock()
+	if rs.registry.registry != nil && time.Since(rs.registry.loadedAt) < registryMaxAge {
+		return *rs.registry.registry, nil
+	}
+	cuisines, err := rs.cuisines.List(ctx)
+	if err != nil {
+		return cuisine.Registry{}, err
+	}
+	// one bad cuisine must not take every recipe read down with it
+	reg, skipped := cuisine.LoadRegistry(cuisines...)
+	for _, c := range skipped {
+		slog.WarnContext(ctx, "skipping inconsistent stored cuisine", "id", int(c.ID()), "cuisine", c.Name())
+	}
+	rs.registry.registry = &reg
+	rs.registry.loadedAt = time.Now()
+	return reg, nil
+}
+
+// lookupParent resolves the name of a parent cuisine. An empty name
+// means no parent.
+func lookupParent(reg cuisine.Registry, name string) (domain.CuisineType, error) {
+	if name == "" {
+		return domain.UnknownCuisine, nil
+	}
+	p, ok := reg.Lookup(name)
+	if !ok {
+		return domain.UnknownCuisine, cuisine.ErrParentNotFound
+	}
+	return p.ID(), nil
+}
+
+// CreateCuisine adds a cuisine to the registry, as a region of parent
+// when parent is not empty.
+func (rs RecipeService) CreateCuisine(ctx context.Context, name string, parent string, aliases []string) (cuisine.Cuisine, error) {
+	reg, err := rs.Cuisines(ctx)
+	if err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	parentID, err := lookupParent(reg, parent)
+	if err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	id, err := rs.cuisines.NextID(ctx)
+	if err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	c, err := cuisine.NewCuisine(id, name, parentID, aliases)
+	if err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	if _, err := reg.With(c); err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	if err := rs.cuisines.Add(ctx, c); err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	rs.registry.invalidate()
+	slog.InfoContext(ctx, "cuisine successfully added", "cuisine", c.Name())
+	return c, nil
+}
+
+// UpdateCuisine renames, re-parents or re-aliases the cuisine found by
+// name. An empty newName keeps the current name. Recipes keep their
+// cuisine as they only store its id.
+func (rs RecipeService) UpdateCuisine(ctx context.Context, name string, newName string, parent string, aliases []string) (cuisine.Cuisine, error) {
+	reg, err := rs.Cuisines(ctx)
+	if err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	existing, ok := reg.Lookup(name)
+	if !ok {
+		return cuisine.Cuisine{}, cuisine.ErrCuisineNotFound
+	}
+	if newName == "" {
+		newName = existing.Name()
+	}
+	parentID, err := lookupParent(reg, parent)
+	if err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	c, err := cuisine.NewCuisine(existing.ID(), newName, parentID, aliases)
+	if err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	if _, err := reg.With(c); err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	if err := rs.cuisines.Update(ctx, c); err != nil {
+		return cuisine.Cuisine{}, err
+	}
+	rs.registry.invalidate()
+	slog.InfoContext(ctx, "cuisine successfully updated", "cuisine", c.Name())
+	return c, nil
+}
+
+// DeleteCuisine removes a cuisine no recipe and no regional cuisine
+// refers to anymore. Deleted recipes count too, since restoring one
+// brings its cuisine back into use.
+func (rs RecipeService) DeleteCuisine(ctx context.Context, name string) error {
+	reg, err := rs.Cuisines(ctx)
+	if err != nil {
+		return err
+	}
+	c, ok := reg.Lookup(name)
+	if !ok {
+		return cuisine.ErrCuisineNotFound
+	}
+	if _, err := reg.Without(c.ID()); err != nil {
+		return err
+	}
+	page, err := rs.recipes.List(ctx, recipe.Query{Cuisines: []domain.CuisineType{c.ID()}, IncludeDeleted: true, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(page.Recipes) > 0 {
+		return cuisine.ErrCuisineInUse
+	}
+	if err := rs.cuisines.Delete(ctx, c.ID()); err != nil {
+		return err
+	}
+	rs.registry.invalidate()
+	slog.InfoContext(ctx, "cuisine successfully deleted", "cuisine", c.Name())
+	return nil
+}
+
+// checkCuisine makes sure recipes are only given cuisines the registry
+// knows.
+func (rs RecipeService) checkCuisine(ctx context.Context, id domain.CuisineType) error {
+	reg, err := rs.Cuisines(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := reg.Get(id); !ok {
+		return cuisine.ErrCuisineNotFound
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+)
+
+// PlanMeal plans cooking the recipes with the given ids together, so
+// that they are all ready at serveAt, in the given kitchen.
+func (rs RecipeService) PlanMeal(ctx context.Context, uuidStrs []string, serveAt time.Time, kitchen recipe.Kitchen) (recipe.Meal, error) {
+	if len(uuidStrs) > recipe.MaxMealRecipes {
+		return recipe.Meal{}, recipe.ErrMealTooLarge
+	}
+	recipes := make([]recipe.Recipe, 0, len(uuidStrs))
+	for _, id := range uuidStrs {
+		r, err := rs.GetResolvedRecipe(ctx, id)
+		if err != nil {
+			return recipe.Meal{}, err
+		}
+		recipes = append(recipes, r)
+	}
+	return recipe.PlanMeal(ctx, recipes, serveAt, kitchen)
+}
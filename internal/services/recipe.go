@@ -2,11 +2,19 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"time"
 
 	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/catalogue"
+	"github.com/bento01dev/cookbook/internal/domain/cuisine"
 	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/domain/taxonomy"
+	"github.com/bento01dev/cookbook/internal/search"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
@@ -16,16 +24,36 @@ type recipeRepository interface {
 	Add(context.Context, recipe.Recipe) error
 	Update(context.Context, recipe.Recipe) (recipe.Recipe, error)
 	Delete(context.Context, uuid.UUID) error
+	Purge(context.Context, time.Time) (int, error)
+	List(context.Context, recipe.Query) (recipe.Page, error)
+	Search(context.Context, search.Query, int) ([]recipe.SearchHit, error)
+	SearchFacets(context.Context, search.Query) (recipe.Facets, error)
+	Facets(context.Context, recipe.Query) (recipe.Facets, error)
 }
 
 type RecipeService struct {
-	recipes recipeRepository
+	recipes     recipeRepository
+	cuisines    cuisineRepository
+	catalogue   catalogueRepository
+	types       taxonomyRepository
+	suggestions *suggestions
+	registry    *registry
+	tree        *tree
+	lexicon     *lexicon
 }
 
 type RecipeConfiguration func(rs *RecipeService) error
 
 func NewRecipeService(cfgs ...RecipeConfiguration) (RecipeService, error) {
-	rs := RecipeService{}
+	rs := RecipeService{
+		cuisines:    cuisine.NewMemoryRepository(cuisine.Defaults()...),
+		catalogue:   catalogue.NewMemoryRepository(),
+		types:       taxonomy.NewMemoryRepository(taxonomy.Defaults()...),
+		suggestions: &suggestions{},
+		registry:    &registry{},
+		tree:        &tree{},
+		lexicon:     &lexicon{},
+	}
 
 	for _, cfg := range cfgs {
 		err := cfg(&rs)
@@ -45,6 +73,19 @@ func WithMemoryRepository() RecipeConfiguration {
 	}
 }
 
+// WithDurableMemoryRepository keeps recipes in memory but journals every
+// change to dir so they survive a restart.
+func WithDurableMemoryRepository(dir string, compactEvery time.Duration) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		mr, err := recipe.NewDurableMemoryRepository(dir, compactEvery)
+		if err != nil {
+			return fmt.Errorf("opening memory journal failed: %w", err)
+		}
+		rs.recipes = mr
+		return nil
+	}
+}
+
 func WithMongoRepository(client *mongo.Client, getEnv func(string) string) RecipeConfiguration {
 	return func(rs *RecipeService) error {
 		databaseName := getEnv("MONGO_DB")
@@ -58,13 +99,61 @@ func WithMongoRepository(client *mongo.Client, getEnv func(string) string) Recip
 		}
 
 		mr := recipe.NewMongoRepository(client, databaseName, collectionName)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mr.EnsureIndexes(ctx); err != nil {
+			return fmt.Errorf("creating recipe indexes failed: %w", err)
+		}
 		rs.recipes = mr
 		return nil
 	}
 }
 
-func (rs RecipeService) CreateRecipe(ctx context.Context, name string, description string, cuisine domain.CuisineType) (recipe.Recipe, error) {
-	r, err := recipe.NewRecipe(name, description, cuisine)
+// mongoCollection reads the database from MONGO_DB and the collection
+// from the given variable, falling back to fallback when it is unset.
+func mongoCollection(getEnv func(string) string, variable string, fallback string) (string, string, error) {
+	databaseName := getEnv("MONGO_DB")
+	if databaseName == "" {
+		return "", "", errors.New("DB not set. Set env MONGO_DB")
+	}
+	collectionName := fallback
+	if v := getEnv(variable); v != "" {
+		collectionName = v
+	}
+	return databaseName, collectionName, nil
+}
+
+// WithSQLiteRepository stores recipes in the given SQLite database,
+// migrating its schema first.
+func WithSQLiteRepository(db *sql.DB) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		sr := recipe.NewSQLiteRepository(db)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := sr.Migrate(ctx); err != nil {
+			return fmt.Errorf("sqlite migration failed: %w", err)
+		}
+		rs.recipes = sr
+		return nil
+	}
+}
+
+// Close releases the repositories that hold resources of their own.
+func (rs RecipeService) Close() error {
+	var errs []error
+	for _, r := range []any{rs.recipes, rs.catalogue} {
+		if c, ok := r.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (rs RecipeService) CreateRecipe(ctx context.Context, name string, description string, cuisine domain.CuisineType, servings int) (recipe.Recipe, error) {
+	if err := rs.checkCuisine(ctx, cuisine); err != nil {
+		return recipe.Recipe{}, err
+	}
+	r, err := recipe.NewRecipe(name, description, cuisine, servings)
 	if err != nil {
 		return r, err
 	}
@@ -73,6 +162,7 @@ func (rs RecipeService) CreateRecipe(ctx context.Context, name string, descripti
 	if err != nil {
 		return r, err
 	}
+	rs.suggestions.put(r)
 	slog.InfoContext(ctx, "recipe successfully added", "recipe_id", r.ID().String())
 	return r, nil
 }
@@ -89,5 +179,451 @@ func (rs RecipeService) GetRecipe(ctx context.Context, uuidStr string) (recipe.R
 		return recipe.Recipe{}, err
 	}
 
+	if r.Deleted() {
+		return recipe.Recipe{}, recipe.ErrRecipeNotFound
+	}
+
+	return r, nil
+}
+
+// ListRecipes returns a page of the recipes matching q. A cuisine in q
+// matches its regional cuisines as well, and an ingredient type the
+// narrower types below it. Facets count a recipe for the cuisines and
+// types above its own too.
+func (rs RecipeService) ListRecipes(ctx context.Context, q recipe.Query) (recipe.Page, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return recipe.Page{}, err
+	}
+	q, err = rs.expandQuery(ctx, q)
+	if err != nil {
+		return recipe.Page{}, err
+	}
+	page, err := rs.recipes.List(ctx, q)
+	if err != nil || !q.Facets {
+		return page, err
+	}
+	facets, err := rs.recipes.Facets(ctx, q)
+	if err != nil {
+		return recipe.Page{}, err
+	}
+	facets, err = rs.rollUp(ctx, facets)
+	if err != nil {
+		return recipe.Page{}, err
+	}
+	page.Facets = &facets
+	return page, nil
+}
+
+// expandQuery widens the cuisines and ingredient types q filters on to
+// every cuisine and type below them.
+func (rs RecipeService) expandQuery(ctx context.Context, q recipe.Query) (recipe.Query, error) {
+	if len(q.Cuisines) > 0 {
+		reg, err := rs.Cuisines(ctx)
+		if err != nil {
+			return recipe.Query{}, err
+		}
+		var cuisines []domain.CuisineType
+		for _, c := range q.Cuisines {
+			cuisines = append(cuisines, reg.Expand(c)...)
+		}
+		q.Cuisines = cuisines
+	}
+	if len(q.IngredientTypes) > 0 {
+		tx, err := rs.Taxonomy(ctx)
+		if err != nil {
+			return recipe.Query{}, err
+		}
+		var types []domain.IngredientType
+		for _, t := range q.IngredientTypes {
+			types = append(types, tx.Expand(t)...)
+		}
+		q.IngredientTypes = types
+	}
+	return q, nil
+}
+
+// rollUp counts the recipes of f for the cuisines and ingredient types
+// above their own as well.
+func (rs RecipeService) rollUp(ctx context.Context, f recipe.Facets) (recipe.Facets, error) {
+	reg, err := rs.Cuisines(ctx)
+	if err != nil {
+		return recipe.Facets{}, err
+	}
+	tx, err := rs.Taxonomy(ctx)
+	if err != nil {
+		return recipe.Facets{}, err
+	}
+	return f.RollUp(reg.Ancestors, tx.Ancestors)
+}
+
+// listAll pages through every recipe matching q.
+func (rs RecipeService) listAll(ctx context.Context, q recipe.Query) ([]recipe.Recipe, error) {
+	var recipes []recipe.Recipe
+	q.Limit = recipe.MaxPageSize
+	for {
+		page, err := rs.recipes.List(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, page.Recipes...)
+		if page.NextCursor == "" {
+			return recipes, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// SimilarRecipes ranks other recipes by how alike they are to the
+// recipe with the given id. Variations are compared as resolved
+// against their parents.
+func (rs RecipeService) SimilarRecipes(ctx context.Context, uuidStr string, limit int) ([]recipe.Similarity, error) {
+	if limit == 0 {
+		limit = recipe.DefaultPageSize
+	}
+	if limit < 0 || limit > recipe.MaxPageSize {
+		return nil, recipe.ErrInvalidQuery
+	}
+	r, err := rs.GetResolvedRecipe(ctx, uuidStr)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []recipe.Recipe
+	if traits := r.Traits(); !traits.Empty() {
+		if candidates, err = rs.listAll(ctx, recipe.Query{Sharing: traits}); err != nil {
+			return nil, err
+		}
+	}
+	if r.Cuisine() == domain.UnknownCuisine {
+		return rs.similar(ctx, r, candidates, limit)
+	}
+
+	// recipes sharing nothing but the cuisine all score the same, so
+	// only the first limit of them by name can make it
+	seen := make(map[uuid.UUID]bool, len(candidates)+1)
+	seen[r.ID()] = true
+	for _, c := range candidates {
+		seen[c.ID()] = true
+	}
+	q := recipe.Query{Cuisines: []domain.CuisineType{r.Cuisine()}, Sort: recipe.SortByName, Limit: recipe.MaxPageSize}
+	for added := 0; added < limit; {
+		page, err := rs.recipes.List(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range page.Recipes {
+			if added == limit {
+				break
+			}
+			if !seen[c.ID()] {
+				candidates = append(candidates, c)
+				added++
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	return rs.similar(ctx, r, candidates, limit)
+}
+
+// similar ranks candidates with recipe.Similar once the variations
+// among them are resolved, so they are scored on what they inherit
+// too.
+func (rs RecipeService) similar(ctx context.Context, r recipe.Recipe, candidates []recipe.Recipe, limit int) ([]recipe.Similarity, error) {
+	for i, c := range candidates {
+		if !c.IsVariation() {
+			continue
+		}
+		ancestors, err := rs.ancestors(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if candidates[i], err = resolve(c, ancestors); err != nil {
+			return nil, err
+		}
+	}
+	return recipe.Similar(r, candidates, limit), nil
+}
+
+// RecipePairings returns the recipes paired with the recipe with the
+// given id and up to limit more suggested through their own pairings.
+func (rs RecipeService) RecipePairings(ctx context.Context, uuidStr string, limit int) ([]recipe.PairedRecipe, []recipe.PairedRecipe, error) {
+	if limit == 0 {
+		limit = recipe.DefaultPageSize
+	}
+	if limit < 0 || limit > recipe.MaxPageSize {
+		return nil, nil, recipe.ErrInvalidQuery
+	}
+	r, err := rs.GetRecipe(ctx, uuidStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	recipes, err := rs.pairingNeighbourhood(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	g := recipe.NewPairingGraph(recipes)
+	return g.Pairings(r.ID()), g.Suggestions(r.ID(), limit), nil
+}
+
+// pairingNeighbourhood returns r and the recipes within
+// recipe.MaxPairingHops pairings of it, either way round, which is all
+// of the graph its pairings and suggestions are drawn from.
+func (rs RecipeService) pairingNeighbourhood(ctx context.Context, r recipe.Recipe) ([]recipe.Recipe, error) {
+	recipes := []recipe.Recipe{r}
+	seen := map[uuid.UUID]bool{r.ID(): true}
+	frontier := []recipe.Recipe{r}
+	for hop := 0; hop < recipe.MaxPairingHops && len(frontier) > 0; hop++ {
+		var ids, paired []uuid.UUID
+		for _, f := range frontier {
+			ids = append(ids, f.ID())
+			for _, p := range f.Pairings() {
+				if !seen[p.With()] {
+					paired = append(paired, p.With())
+				}
+			}
+		}
+		var next []recipe.Recipe
+		for _, q := range []recipe.Query{{PairedWith: ids}, {IDs: paired}} {
+			if len(q.PairedWith) == 0 && len(q.IDs) == 0 {
+				continue
+			}
+			found, err := rs.listAll(ctx, q)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range found {
+				if !seen[c.ID()] {
+					seen[c.ID()] = true
+					next = append(next, c)
+				}
+			}
+		}
+		recipes = append(recipes, next...)
+		frontier = next
+	}
+	return recipes, nil
+}
+
+// SearchRecipes returns up to limit recipes matching the words and
+// "quoted phrases" of q, most relevant first. Ingredients searched for
+// by another of their names are searched for by their catalogue name.
+// With withFacets, the facets of every matching recipe come too, not
+// just of the hits returned.
+func (rs RecipeService) SearchRecipes(ctx context.Context, q string, limit int, withFacets bool) ([]recipe.SearchHit, *recipe.Facets, error) {
+	query, limit, err := recipe.SearchQuery(q, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	query, err = rs.canonicalQuery(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	hits, err := rs.recipes.Search(ctx, query, limit)
+	if err != nil || !withFacets {
+		return hits, nil, err
+	}
+	facets, err := rs.recipes.SearchFacets(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	facets, err = rs.rollUp(ctx, facets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hits, &facets, nil
+}
+
+// DeleteRecipe soft deletes a recipe. It disappears from reads but
+// can be restored until it is purged. A recipe its live variations
+// inherit from cannot be deleted, as they would lose what they inherit.
+func (rs RecipeService) DeleteRecipe(ctx context.Context, uuidStr string) error {
+	_, err := rs.modify(ctx, uuidStr, func(r *recipe.Recipe) error {
+		for _, id := range r.VariationIDs() {
+			child, err := rs.recipes.Get(ctx, id)
+			if errors.Is(err, recipe.ErrRecipeNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if !child.Deleted() {
+				return recipe.ErrHasVariations
+			}
+		}
+		return r.Delete()
+	})
+	return err
+}
+
+func (rs RecipeService) RestoreRecipe(ctx context.Context, uuidStr string) (recipe.Recipe, error) {
+	id, err := parseID(uuidStr)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+
+	r, err := rs.recipes.Get(ctx, id)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+
+	if err := r.Restore(); err != nil {
+		return recipe.Recipe{}, err
+	}
+	// a variation comes back only along with its parent
+	if r.IsVariation() {
+		parent, err := rs.recipes.Get(ctx, r.Parent())
+		if errors.Is(err, recipe.ErrRecipeNotFound) || err == nil && parent.Deleted() {
+			return recipe.Recipe{}, recipe.ErrParentDeleted
+		}
+		if err != nil {
+			return recipe.Recipe{}, err
+		}
+	}
+
+	r, err = rs.recipes.Update(ctx, r)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	rs.suggestions.put(r)
+	slog.InfoContext(ctx, "recipe successfully restored", "recipe_id", r.ID().String())
+	return r, nil
+}
+
+// PurgeDeleted hard deletes recipes that have been soft deleted for
+// longer than retention.
+func (rs RecipeService) PurgeDeleted(ctx context.Context, retention time.Duration) (int, error) {
+	return rs.recipes.Purge(ctx, time.Now().UTC().Add(-retention))
+}
+
+// modify loads a recipe, applies fn to it and writes the result back
+// through the repository.
+func (rs RecipeService) modify(ctx context.Context, uuidStr string, fn func(*recipe.Recipe) error) (recipe.Recipe, error) {
+	r, err := rs.GetRecipe(ctx, uuidStr)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	return rs.write(ctx, r, fn)
+}
+
+// write applies fn to r and writes the result back through the
+// repository, which fails with recipe.ErrRecipeConflict when the
+// recipe was changed since r was read.
+func (rs RecipeService) write(ctx context.Context, r recipe.Recipe, fn func(*recipe.Recipe) error) (recipe.Recipe, error) {
+	if err := fn(&r); err != nil {
+		return recipe.Recipe{}, err
+	}
+
+	r, err := rs.recipes.Update(ctx, r)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	rs.suggestions.put(r)
+	slog.InfoContext(ctx, "recipe successfully updated", "recipe_id", r.ID().String())
 	return r, nil
 }
+
+func parseID(s string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, recipe.ErrInvalidID
+	}
+	return id, nil
+}
+
+// ParamError reports a malformed id given in a request field rather
+// than in the path, naming the field.
+type ParamError struct {
+	Param string
+	Value string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid format for %s: %s", e.Param, e.Value)
+}
+
+// parseParam parses the id in the request field param.
+func parseParam(param string, s string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, &ParamError{Param: param, Value: s}
+	}
+	return id, nil
+}
+
+// parseOptionalParam treats an empty string as "no id".
+func parseOptionalParam(param string, s string) (uuid.UUID, error) {
+	if s == "" {
+		return uuid.Nil, nil
+	}
+	return parseParam(param, s)
+}
+
+func (rs RecipeService) UpdateRecipe(ctx context.Context, uuidStr string, patch recipe.Patch) (recipe.Recipe, error) {
+	if patch.Cuisine != nil {
+		if err := rs.checkCuisine(ctx, *patch.Cuisine); err != nil {
+			return recipe.Recipe{}, err
+		}
+	}
+	return rs.modify(ctx, uuidStr, func(r *recipe.Recipe) error {
+		return r.Apply(patch)
+	})
+}
+
+// AddIngredient adds a line for an ingredient from the catalogue: the
+// one with the ingredient's id, or else the one with its name, which
+// is created from the ingredient when there is none yet.
+func (rs RecipeService) AddIngredient(ctx context.Context, uuidStr string, ingredient domain.Ingredient, amount float64, unit string, note string) (recipe.Recipe, error) {
+	if _, err := rs.GetRecipe(ctx, uuidStr); err != nil {
+		return recipe.Recipe{}, err
+	}
+	ingredient, err := rs.catalogueIngredient(ctx, ingredient)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	line, err := domain.NewIngredientLine(ingredient, amount, unit, note)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	return rs.modify(ctx, uuidStr, func(r *recipe.Recipe) error {
+		r.AddIngredient(line)
+		return nil
+	})
+}
+
+func (rs RecipeService) AddPrep(ctx context.Context, uuidStr string, ingredientID string, action string) (recipe.Recipe, error) {
+	ingredient, err := parseParam("ingredient_id", ingredientID)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	p, err := domain.NewPrep(ingredient, action)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	return rs.modifySteps(ctx, uuidStr, func(r *recipe.Recipe, _ inheritedSteps) error {
+		return r.AddPrep(p)
+	})
+}
+
+// AddPairing pairs a recipe with another existing recipe.
+func (rs RecipeService) AddPairing(ctx context.Context, uuidStr string, withID string, description string) (recipe.Recipe, error) {
+	with, err := parseParam("with", withID)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	if _, err := rs.GetRecipe(ctx, withID); err != nil {
+		if errors.Is(err, recipe.ErrRecipeNotFound) {
+			return recipe.Recipe{}, recipe.ErrPairedNotFound
+		}
+		return recipe.Recipe{}, err
+	}
+	return rs.modify(ctx, uuidStr, func(r *recipe.Recipe) error {
+		p, err := domain.NewPairing(r.ID(), with, description)
+		if err != nil {
+			return err
+		}
+		return r.AddPairing(p)
+	})
+}
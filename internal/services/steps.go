@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/domain/units"
+)
+
+// inheritedSteps counts the prep and cooking steps a variation
+// inherits, which come before its own in the resolved recipe.
+type inheritedSteps struct {
+	prep  int
+	steps int
+}
+
+// ownPosition maps a position in the resolved recipe onto the
+// recipe's own steps. Inherited steps can only be changed through the
+// recipe they come from. Negative positions are passed on for the
+// recipe to reject.
+func ownPosition(pos int, inherited int) (int, error) {
+	switch {
+	case pos < 0:
+		return pos, nil
+	case pos < inherited:
+		return 0, recipe.ErrStepInherited
+	default:
+		return pos - inherited, nil
+	}
+}
+
+// modifySteps is modify for changes addressing prep and cooking steps
+// by position. Positions are those of the resolved recipe, as it is
+// read, and the recipe comes back resolved.
+func (rs RecipeService) modifySteps(ctx context.Context, uuidStr string, fn func(*recipe.Recipe, inheritedSteps) error) (recipe.Recipe, error) {
+	var ancestors []recipe.Recipe
+	r, err := rs.modify(ctx, uuidStr, func(r *recipe.Recipe) error {
+		var err error
+		if ancestors, err = rs.ancestors(ctx, *r); err != nil {
+			return err
+		}
+		resolved, err := resolve(*r, ancestors)
+		if err != nil {
+			return err
+		}
+		return fn(r, inheritedSteps{
+			prep:  len(resolved.Prep()) - len(r.Prep()),
+			steps: len(resolved.Steps()) - len(r.Steps()),
+		})
+	})
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	return resolve(r, ancestors)
+}
+
+// InsertPrep places a prep step at the given position of the recipe's
+// prep steps, shifting the ones from there on back.
+func (rs RecipeService) InsertPrep(ctx context.Context, uuidStr string, pos int, ingredientID string, action string) (recipe.Recipe, error) {
+	ingredient, err := parseParam("ingredient_id", ingredientID)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	p, err := domain.NewPrep(ingredient, action)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	return rs.modifySteps(ctx, uuidStr, func(r *recipe.Recipe, inherited inheritedSteps) error {
+		pos, err := ownPosition(pos, inherited.prep)
+		if err != nil {
+			return err
+		}
+		return r.InsertPrep(pos, p)
+	})
+}
+
+func (rs RecipeService) MovePrep(ctx context.Context, uuidStr string, from int, to int) (recipe.Recipe, error) {
+	return rs.modifySteps(ctx, uuidStr, func(r *recipe.Recipe, inherited inheritedSteps) error {
+		from, err := ownPosition(from, inherited.prep)
+		if err != nil {
+			return err
+		}
+		to, err := ownPosition(to, inherited.prep)
+		if err != nil {
+			return err
+		}
+		return r.MovePrep(from, to)
+	})
+}
+
+func (rs RecipeService) RemovePrep(ctx context.Context, uuidStr string, pos int) (recipe.Recipe, error) {
+	return rs.modifySteps(ctx, uuidStr, func(r *recipe.Recipe, inherited inheritedSteps) error {
+		pos, err := ownPosition(pos, inherited.prep)
+		if err != nil {
+			return err
+		}
+		return r.RemovePrep(pos)
+	})
+}
+
+// StepDetails describes a cooking step to add to a recipe.
+type StepDetails struct {
+	// IngredientID is empty for steps not acting on an ingredient
+	IngredientID string
+	Action       string
+	Temperature  units.Temperature
+	Method       domain.CookingMethod
+	Active       time.Duration
+	Passive      time.Duration
+	// After holds the positions of the steps it waits for, as in the
+	// resolved recipe
+	After     []int
+	Equipment domain.Equipment
+}
+
+func (d StepDetails) step() (domain.Step, error) {
+	ingredient, err := parseOptionalParam("ingredient_id", d.IngredientID)
+	if err != nil {
+		return domain.Step{}, err
+	}
+	s, err := domain.NewStep(ingredient, d.Action, d.Temperature)
+	if err != nil {
+		return domain.Step{}, err
+	}
+	if s, err = s.WithMethod(d.Method); err != nil {
+		return domain.Step{}, err
+	}
+	if s, err = s.WithDurations(d.Active, d.Passive); err != nil {
+		return domain.Step{}, err
+	}
+	if s, err = s.WithDependencies(d.After); err != nil {
+		return domain.Step{}, err
+	}
+	return s.WithEquipment(d.Equipment)
+}
+
+// ownDependencies points s at the recipe's own steps it waits for,
+// given by their position in the resolved recipe. A variation's own
+// steps can only wait for each other.
+func ownDependencies(s domain.Step, inherited int) (domain.Step, error) {
+	after := make([]int, 0, len(s.After()))
+	for _, v := range s.After() {
+		v, err := ownPosition(v, inherited)
+		if err != nil {
+			return domain.Step{}, err
+		}
+		after = append(after, v)
+	}
+	return s.WithDependencies(after)
+}
+
+func (rs RecipeService) AddStep(ctx context.Context, uuidStr string, details StepDetails) (recipe.Recipe, error) {
+	s, err := details.step()
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	return rs.modifySteps(ctx, uuidStr, func(r *recipe.Recipe, inherited inheritedSteps) error {
+		s, err := ownDependencies(s, inherited.steps)
+		if err != nil {
+			return err
+		}
+		return r.AddStep(s)
+	})
+}
+
+// InsertStep places a cooking step at the given position of the
+// recipe's steps, shifting the ones from there on back.
+func (rs RecipeService) InsertStep(ctx context.Context, uuidStr string, pos int, details StepDetails) (recipe.Recipe, error) {
+	s, err := details.step()
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	return rs.modifySteps(ctx, uuidStr, func(r *recipe.Recipe, inherited inheritedSteps) error {
+		pos, err := ownPosition(pos, inherited.steps)
+		if err != nil {
+			return err
+		}
+		s, err := ownDependencies(s, inherited.steps)
+		if err != nil {
+			return err
+		}
+		return r.InsertStep(pos, s)
+	})
+}
+
+func (rs RecipeService) MoveStep(ctx context.Context, uuidStr string, from int, to int) (recipe.Recipe, error) {
+	return rs.modifySteps(ctx, uuidStr, func(r *recipe.Recipe, inherited inheritedSteps) error {
+		from, err := ownPosition(from, inherited.steps)
+		if err != nil {
+			return err
+		}
+		to, err := ownPosition(to, inherited.steps)
+		if err != nil {
+			return err
+		}
+		return r.MoveStep(from, to)
+	})
+}
+
+func (rs RecipeService) RemoveStep(ctx context.Context, uuidStr string, pos int) (recipe.Recipe, error) {
+	return rs.modifySteps(ctx, uuidStr, func(r *recipe.Recipe, inherited inheritedSteps) error {
+		pos, err := ownPosition(pos, inherited.steps)
+		if err != nil {
+			return err
+		}
+		return r.RemoveStep(pos)
+	})
+}
+
+// RecipeTimeline schedules the cooking steps of the recipe with the
+// given id, including those a variation inherits.
+func (rs RecipeService) RecipeTimeline(ctx context.Context, uuidStr string) (recipe.Timeline, error) {
+	r, err := rs.GetResolvedRecipe(ctx, uuidStr)
+	if err != nil {
+		return recipe.Timeline{}, err
+	}
+	return r.Timeline(), nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/search"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultSuggestions is how many suggestions are returned when no
+	// limit is asked for.
+	DefaultSuggestions = 10
+	// suggestionsMaxAge bounds how stale suggestions can get when
+	// recipes are changed by another instance sharing the database.
+	suggestionsMaxAge = time.Minute
+	// syncOverlap is how far back each sync looks past the previous
+	// one, so writes from instances with a slightly different clock
+	// are not missed.
+	syncOverlap = 5 * time.Second
+)
+
+// suggestions keeps a completer over the names of every recipe that
+// is not deleted. It is built once, kept up to date by the writes
+// through the service and, for writes by other instances sharing the
+// database, synced with the recipes changed since it last looked.
+type suggestions struct {
+	// mu is only held while building or syncing
+	mu        sync.Mutex
+	completer *search.Completer
+	syncedAt  time.Time
+}
+
+// completions lists the name and the distinct ingredient names of r.
+func completions(r recipe.Recipe) []search.Completion {
+	completions := []search.Completion{{Text: r.Name(), Kind: "recipe"}}
+	seen := make(map[string]bool)
+	for _, l := range r.Ingredients() {
+		name := l.Ingredient().Name
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		completions = append(completions, search.Completion{Text: name, Kind: "ingredient"})
+	}
+	return completions
+}
+
+// put brings the completions of r up to date after it was written.
+// Nothing is kept before the completer is first built.
+func (s *suggestions) put(r recipe.Recipe) {
+	s.mu.Lock()
+	completer := s.completer
+	s.mu.Unlock()
+	if completer == nil {
+		return
+	}
+	if r.Deleted() {
+		completer.Remove(r.ID().String())
+		return
+	}
+	completer.Put(r.ID().String(), completions(r))
+}
+
+// remove drops the completions of a recipe that was hard deleted,
+// which syncing cannot see.
+func (s *suggestions) remove(id uuid.UUID) {
+	s.mu.Lock()
+	completer := s.completer
+	s.mu.Unlock()
+	if completer != nil {
+		completer.Remove(id.String())
+	}
+}
+
+// load returns the completer, building it on first use and syncing it
+// when it has not been synced for suggestionsMaxAge.
+func (s *suggestions) load(ctx context.Context, recipes recipeRepository) (*search.Completer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.completer != nil && time.Since(s.syncedAt) < suggestionsMaxAge {
+		return s.completer, nil
+	}
+
+	completer := s.completer
+	q := recipe.Query{Sort: recipe.SortByUpdatedAt, Limit: recipe.MaxPageSize}
+	if completer == nil {
+		completer = search.NewCompleter(nil)
+	} else {
+		// tombstones are listed so deletions are synced as well
+		q.UpdatedAfter = s.syncedAt.Add(-syncOverlap)
+		q.IncludeDeleted = true
+	}
+	started := time.Now()
+	for {
+		page, err := recipes.List(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page.Recipes {
+			if r.Deleted() {
+				completer.Remove(r.ID().String())
+				continue
+			}
+			completer.Put(r.ID().String(), completions(r))
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	s.completer = completer
+	s.syncedAt = started
+	return completer, nil
+}
+
+// SuggestRecipes completes prefix to recipe and ingredient names,
+// tolerating small typos.
+func (rs RecipeService) SuggestRecipes(ctx context.Context, prefix string, limit int) ([]search.Suggestion, error) {
+	if limit == 0 {
+		limit = DefaultSuggestions
+	}
+	if strings.TrimSpace(prefix) == "" || limit < 0 || limit > recipe.MaxPageSize {
+		return nil, recipe.ErrInvalidQuery
+	}
+
+	completer, err := rs.suggestions.load(ctx, rs.recipes)
+	if err != nil {
+		return nil, err
+	}
+	return completer.Complete(prefix, limit), nil
+}
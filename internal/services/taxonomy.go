@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+	"github.com/bento01dev/cookbook/internal/domain/taxonomy"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type taxonomyRepository interface {
+	List(context.Context) ([]taxonomy.Category, error)
+	NextID(context.Context) (domain.IngredientType, error)
+	Add(context.Context, taxonomy.Category) error
+	Update(context.Context, taxonomy.Category) error
+	Delete(context.Context, domain.IngredientType) error
+}
+
+// tree caches the ingredient taxonomy, which every listing filtered by
+// ingredient type walks. Writes through the service drop it.
+type tree struct {
+	mu       sync.Mutex
+	taxonomy *taxonomy.Taxonomy
+	loadedAt time.Time
+}
+
+func (t *tree) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.taxonomy = nil
+}
+
+// WithMemoryTaxonomy keeps the ingredient taxonomy in memory, starting
+// from seed.
+func WithMemoryTaxonomy(seed []taxonomy.Category) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		rs.types = taxonomy.NewMemoryRepository(seed...)
+		return nil
+	}
+}
+
+// WithMongoTaxonomy stores the ingredient taxonomy in
+// INGREDIENT_TYPE_COLLECTION, "ingredient_type" unless set, next to the
+// recipes.
+func WithMongoTaxonomy(client *mongo.Client, getEnv func(string) string, seed []taxonomy.Category) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		databaseName, collectionName, err := mongoCollection(getEnv, "INGREDIENT_TYPE_COLLECTION", "ingredient_type")
+		if err != nil {
+			return err
+		}
+
+		mr := taxonomy.NewMongoRepository(client, databaseName, collectionName)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mr.Seed(ctx, seed); err != nil {
+			return fmt.Errorf("seeding ingredient types failed: %w", err)
+		}
+		rs.types = mr
+		return nil
+	}
+}
+
+// WithSQLiteTaxonomy stores the ingredient taxonomy in the given SQLite
+// database, migrating its schema and seeding it when it is empty.
+func WithSQLiteTaxonomy(db *sql.DB, seed []taxonomy.Category) RecipeConfiguration {
+	return func(rs *RecipeService) error {
+		sr := taxonomy.NewSQLiteRepository(db)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := sr.Migrate(ctx); err != nil {
+			return fmt.Errorf("migrating ingredient types failed: %w", err)
+		}
+		if err := sr.Seed(ctx, seed); err != nil {
+			return fmt.Errorf("seeding ingredient types failed: %w", err)
+		}
+		rs.types = sr
+		return nil
+	}
+}
+
+// Taxonomy returns the ingredient taxonomy, loading it when the cached
+// one is missing or too old.
+func (rs RecipeService) Taxonomy(ctx context.Context) (taxonomy.Taxonomy, error) {
+	rs.tree.mu.Lock()
+	defer rs.tree.mu.Unlock()
+	if rs.tree.taxonomy != nil && time.Since(rs.tree.loadedAt) < registryMaxAge {
+		return *rs.tree.taxonomy, nil
+	}
+	categories, err := rs.types.List(ctx)
+	if err != nil {
+		return taxonomy.Taxonomy{}, err
+	}
+	// one bad category must not take every ingredient write down with
+	// it
+	tx, skipped := taxonomy.Load(categories...)
+	for _, c := range skipped {
+		slog.WarnContext(ctx, "skipping inconsistent stored ingredient type", "id", int(c.ID()), "ingredient_type", c.Name())
+	}
+	rs.tree.taxonomy = &tx
+	rs.tree.loadedAt = time.Now()
+	return tx, nil
+}
+
+// lookupBroader resolves the name of the category a new or moved one
+// goes below. An empty name puts it at the top of the tree.
+func lookupBroader(tx taxonomy.Taxonomy, name string) (domain.IngredientType, error) {
+	if name == "" {
+		return domain.UnknownIngredient, nil
+	}
+	p, ok := tx.Lookup(name)
+	if !ok {
+		return domain.UnknownIngredient, taxonomy.ErrParentNotFound
+	}
+	return p.ID(), nil
+}
+
+// CreateIngredientType adds a category to the taxonomy, below parent
+// when parent is not empty.
+func (rs RecipeService) CreateIngredientType(ctx context.Context, name string, parent string) (taxonomy.Category, error) {
+	tx, err := rs.Taxonomy(ctx)
+	if err != nil {
+		return taxonomy.Category{}, err
+	}
+	parentID, err := lookupBroader(tx, parent)
+	if err != nil {
+		return taxonomy.Category{}, err
+	}
+	id, err := rs.types.NextID(ctx)
+	if err != nil {
+		return taxonomy.Category{}, err
+	}
+	c, err := taxonomy.NewCategory(id, name, parentID)
+	if err != nil {
+		return taxonomy.Category{}, err
+	}
+	if _, err := tx.With(c); err != nil {
+		return taxonomy.Category{}, err
+	}
+	if err := rs.types.Add(ctx, c); err != nil {
+		return taxonomy.Category{}, err
+	}
+	rs.tree.invalidate()
+	slog.InfoContext(ctx, "ingredient type successfully added", "ingredient_type", c.Name())
+	return c, nil
+}
+
+// UpdateIngredientType renames or moves the category found by name. An
+// empty newName keeps the current name. Ingredients keep their type as
+// they only store its id.
+func (rs RecipeService) UpdateIngredientType(ctx context.Context, name string, newName string, parent string) (taxonomy.Category, error) {
+	tx, err := rs.Taxonomy(ctx)
+	if err != nil {
+		return taxonomy.Category{}, err
+	}
+	existing, ok := tx.Lookup(name)
+	if !ok {
+		return taxonomy.Category{}, taxonomy.ErrCategoryNotFound
+	}
+	if newName == "" {
+		newName = existing.Name()
+	}
+	parentID, err := lookupBroader(tx, parent)
+	if err != nil {
+		return taxonomy.Category{}, err
+	}
+	c, err := taxonomy.NewCategory(existing.ID(), newName, parentID)
+	if err != nil {
+		return taxonomy.Category{}, err
+	}
+	if _, err := tx.With(c); err != nil {
+		return taxonomy.Category{}, err
+	}
+	if err := rs.types.Update(ctx, c); err != nil {
+		return taxonomy.Category{}, err
+	}
+	rs.tree.invalidate()
+	slog.InfoContext(ctx, "ingredient type successfully updated", "ingredient_type", c.Name())
+	return c, nil
+}
+
+// DeleteIngredientType removes a category that has no narrower ones
+// and that neither a catalogue entry nor a recipe uses. Deleted
+// recipes count too, since restoring one brings its types back into
+// use.
+func (rs RecipeService) DeleteIngredientType(ctx context.Context, name string) error {
+	tx, err := rs.Taxonomy(ctx)
+	if err != nil {
+		return err
+	}
+	c, ok := tx.Lookup(name)
+	if !ok {
+		return taxonomy.ErrCategoryNotFound
+	}
+	if _, err := tx.Without(c.ID()); err != nil {
+		return err
+	}
+	used, err := rs.catalogue.UsesType(ctx, c.ID())
+	if err != nil {
+		return err
+	}
+	if used {
+		return taxonomy.ErrCategoryInUse
+	}
+	page, err := rs.recipes.List(ctx, recipe.Query{IngredientTypes: []domain.IngredientType{c.ID()}, IncludeDeleted: true, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(page.Recipes) > 0 {
+		return taxonomy.ErrCategoryInUse
+	}
+	if err := rs.types.Delete(ctx, c.ID()); err != nil {
+		return err
+	}
+	rs.tree.invalidate()
+	slog.InfoContext(ctx, "ingredient type successfully deleted", "ingredient_type", c.Name())
+	return nil
+}
+
+// checkIngredientType makes sure ingredients are only given types the
+// taxonomy knows. An ingredient may be left untyped.
+func (rs RecipeService) checkIngredientType(ctx context.Context, t domain.IngredientType) error {
+	if t == domain.UnknownIngredient {
+		return nil
+	}
+	tx, err := rs.Taxonomy(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := tx.Get(t); !ok {
+		return taxonomy.ErrCategoryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/bento01dev/cookbook/internal/domain"
+	"github.com/bento01dev/cookbook/internal/domain/recipe"
+)
+
+// IngredientSwap replaces one of the parent's ingredient lines in a
+// variation.
+type IngredientSwap struct {
+	IngredientID string
+	Ingredient   domain.Ingredient
+	Amount       float64
+	Unit         string
+	Note         string
+}
+
+// CreateVariation adds a recipe deriving from the recipe with the
+// given id, swapping and omitting some of the ingredients it inherits,
+// and records it among the parent's variations. The name defaults to
+// the parent's name followed by the description.
+func (rs RecipeService) CreateVariation(ctx context.Context, uuidStr string, name string, description string, swaps []IngredientSwap, omit []string) (recipe.Recipe, error) {
+	parent, err := rs.GetRecipe(ctx, uuidStr)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	ancestors, err := rs.ancestors(ctx, parent)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	if len(ancestors) >= recipe.MaxVariationDepth {
+		return recipe.Recipe{}, recipe.ErrVariationTooDeep
+	}
+	resolved, err := resolve(parent, ancestors)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("%s (%s)", parent.Name(), description)
+	}
+	child, err := recipe.NewVariation(resolved, name, description)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	v, err := domain.NewVariation(child.ID(), description)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	for _, s := range swaps {
+		id, err := parseID(s.IngredientID)
+		if err != nil {
+			return recipe.Recipe{}, recipe.ErrUnknownIngredient
+		}
+		ingredient, err := rs.catalogueIngredient(ctx, s.Ingredient)
+		if err != nil {
+			return recipe.Recipe{}, err
+		}
+		line, err := domain.NewIngredientLine(ingredient, s.Amount, s.Unit, s.Note)
+		if err != nil {
+			return recipe.Recipe{}, err
+		}
+		if err := child.Override(resolved, recipe.Swap(id, line)); err != nil {
+			return recipe.Recipe{}, err
+		}
+	}
+	for _, s := range omit {
+		id, err := parseID(s)
+		if err != nil {
+			return recipe.Recipe{}, recipe.ErrUnknownIngredient
+		}
+		if err := child.Override(resolved, recipe.Omit(id)); err != nil {
+			return recipe.Recipe{}, err
+		}
+	}
+
+	if err := rs.recipes.Add(ctx, child); err != nil {
+		return recipe.Recipe{}, err
+	}
+	// the child is only added if it can be linked from its parent, so
+	// it is taken out again should that fail, even past a timeout
+	if _, err := rs.modify(ctx, uuidStr, func(r *recipe.Recipe) error {
+		r.AddVariation(v)
+		return nil
+	}); err != nil {
+		if err := rs.recipes.Delete(context.WithoutCancel(ctx), child.ID()); err != nil {
+			slog.ErrorContext(ctx, "removing unlinked variation failed", "recipe_id", child.ID().String(), "err", err.Error())
+		}
+		rs.suggestions.remove(child.ID())
+		return recipe.Recipe{}, err
+	}
+	rs.suggestions.put(child)
+	slog.InfoContext(ctx, "variation successfully added", "recipe_id", child.ID().String(), "parent_id", parent.ID().String())
+	return child.Resolve(resolved)
+}
+
+// GetResolvedRecipe is GetRecipe for reading: a variation comes back
+// with everything it inherits from its parents.
+func (rs RecipeService) GetResolvedRecipe(ctx context.Context, uuidStr string) (recipe.Recipe, error) {
+	r, err := rs.GetRecipe(ctx, uuidStr)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	ancestors, err := rs.ancestors(ctx, r)
+	if err != nil {
+		return recipe.Recipe{}, err
+	}
+	return resolve(r, ancestors)
+}
+
+// RecipeVariations returns the variations of the recipe with the given
+// id, resolved against it.
+func (rs RecipeService) RecipeVariations(ctx context.Context, uuidStr string) ([]recipe.Recipe, error) {
+	parent, err := rs.GetResolvedRecipe(ctx, uuidStr)
+	if err != nil {
+		return nil, err
+	}
+	ids := parent.VariationIDs()
+	variations := make([]recipe.Recipe, 0, len(ids))
+	for _, id := range ids {
+		child, err := rs.recipes.Get(ctx, id)
+		if errors.Is(err, recipe.ErrRecipeNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if child.Deleted() {
+			continue
+		}
+		child, err = child.Resolve(parent)
+		if err != nil {
+			return nil, err
+		}
+		variations = append(variations, child)
+	}
+	return variations, nil
+}
+
+// ancestors returns the recipes r derives from, nearest first. Parents
+// with live variations cannot be deleted, but should one be gone
+// anyway the chain stops early and r resolves against what is left.
+func (rs RecipeService) ancestors(ctx context.Context, r recipe.Recipe) ([]recipe.Recipe, error) {
+	var ancestors []recipe.Recipe
+	for r.IsVariation() {
+		if len(ancestors) > recipe.MaxVariationDepth {
+			return nil, recipe.ErrVariationTooDeep
+		}
+		parent, err := rs.recipes.Get(ctx, r.Parent())
+		if errors.Is(err, recipe.ErrRecipeNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if parent.Deleted() {
+			break
+		}
+		ancestors = append(ancestors, parent)
+		r = parent
+	}
+	return ancestors, nil
+}
+
+func resolve(r recipe.Recipe, ancestors []recipe.Recipe) (recipe.Recipe, error) {
+	if len(ancestors) == 0 {
+		return r, nil
+	}
+	resolved := ancestors[len(ancestors)-1]
+	for i := len(ancestors) - 2; i >= 0; i-- {
+		var err error
+		if resolved, err = ancestors[i].Resolve(resolved); err != nil {
+			return recipe.Recipe{}, err
+		}
+	}
+	return r.Resolve(resolved)
+}